@@ -1,18 +1,30 @@
 package main
 
 import (
+	"context"
 	"log"
 
+	"proyecto1/root/internal/auth"
 	config "proyecto1/root/internal/config"
 	"proyecto1/root/internal/database"
 	httpserver "proyecto1/root/internal/http"
+	"proyecto1/root/internal/http/handlers"
+	"proyecto1/root/internal/http/session"
+	"proyecto1/root/internal/mailer"
+	messagingProviders "proyecto1/root/internal/messaging/providers"
+	"proyecto1/root/internal/outbox"
+	"proyecto1/root/internal/uploads"
+	"proyecto1/root/internal/videos"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
 	// Load configuration from environment variables
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
 
 	// Initialize database connection
 	db, err := database.Initialize(cfg)
@@ -32,8 +44,81 @@ func main() {
 	// Set Gin mode based on configuration
 	gin.SetMode(cfg.Server.Mode)
 
+	// Background tasks (outbox relay, session and upload janitors) stop when main returns
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Initialize the store of revoked access tokens, shared by every API instance
+	sessionStore, err := session.NewSessionStore(cfg, db)
+	if err != nil {
+		log.Fatalf("Failed to initialize session store: %v", err)
+	}
+	defer func() {
+		if err := sessionStore.Close(); err != nil {
+			log.Printf("Error closing session store: %v", err)
+		}
+	}()
+	go session.RunJanitor(ctx, sessionStore, cfg.Session.CleanupInterval)
+
+	// Initialize the JWT signing keys (reloaded to pick up added, removed and rotated keys)
+	tokenManager, err := auth.NewTokenManager(cfg.JWT)
+	if err != nil {
+		log.Fatalf("Failed to initialize token manager: %v", err)
+	}
+	if tokenManager.Keys != nil {
+		log.Printf("Signing tokens with %s key %s", tokenManager.Keys.Signer().Algorithm, tokenManager.Keys.Signer().ID)
+		go tokenManager.Keys.Run(ctx, cfg.JWT.KeyReloadInterval)
+	}
+
+	// Initialize the mailer of the verification and password reset emails
+	accountMailer, err := mailer.NewMailer(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	// Initialize the video service, shared by the handlers and the pending upload janitor
+	videoService, err := handlers.NewVideoService(db, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize video service: %v", err)
+	}
+
+	// Initialize the resumable upload store, shared by the tus handler and the upload janitor
+	uploadStore, err := uploads.NewFileStore(cfg.Uploads.Dir)
+	if err != nil {
+		log.Fatalf("Failed to initialize resumable upload store: %v", err)
+	}
+
 	// Create router with configuration and database
-	router := httpserver.NewRouter(cfg, db)
+	router, err := httpserver.NewRouter(cfg, db, sessionStore, tokenManager, accountMailer, videoService, uploadStore)
+	if err != nil {
+		log.Fatalf("Failed to initialize router: %v", err)
+	}
+
+	// Initialize message queue used by the outbox relay
+	messageQueue, err := messagingProviders.NewMessageQueue(cfg, db)
+	if err != nil {
+		log.Fatalf("Failed to initialize message queue: %v", err)
+	}
+	defer func() {
+		if err := messageQueue.Close(); err != nil {
+			log.Printf("Error closing message queue: %v", err)
+		}
+	}()
+
+	// Start outbox relay (publishes processing messages saved with each upload)
+	relay := outbox.NewRelay(db, messageQueue, outbox.RelayConfig{
+		PollInterval: cfg.Outbox.PollInterval,
+		BatchSize:    cfg.Outbox.BatchSize,
+		BaseDelay:    cfg.Outbox.BaseDelay,
+		MaxDelay:     cfg.Outbox.MaxDelay,
+	})
+	go relay.Run(ctx)
+
+	// Remove the resumable uploads abandoned on this instance
+	go uploads.RunJanitor(ctx, uploadStore, cfg.Uploads.Expiration, cfg.Uploads.CleanupInterval)
+
+	// Remove the videos whose presigned upload was never completed
+	go videos.RunPendingUploadJanitor(ctx, videoService, cfg.Uploads.PendingGrace, cfg.Uploads.CleanupInterval)
 
 	// Start server
 	addr := cfg.Server.Host + ":" + cfg.Server.Port
@@ -1,6 +1,13 @@
 package ObjectStorage
 
-import "proyecto1/root/internal/ObjectStorage/providers"
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"proyecto1/root/internal/ObjectStorage/providers"
+)
 
 // FileStorageManager manages file storage operations using a provider
 type FileStorageManager struct {
@@ -19,6 +26,62 @@ func (fsm *FileStorageManager) UploadFile(fileBuffer []byte, fileName string) er
 	return fsm.provider.UploadFile(fileBuffer, fileName)
 }
 
+// Upload streams the reader to storage using the configured provider
+func (fsm *FileStorageManager) Upload(ctx context.Context, fileName string, body io.Reader, size int64, contentType string) error {
+	return fsm.provider.Upload(ctx, fileName, body, size, contentType)
+}
+
+// Download opens a stream to the file using the configured provider. The caller must close it
+func (fsm *FileStorageManager) Download(ctx context.Context, fileName string) (io.ReadCloser, int64, error) {
+	return fsm.provider.Download(ctx, fileName)
+}
+
+// DownloadRange opens a stream to a byte range of the file using the configured provider. The caller must close it
+func (fsm *FileStorageManager) DownloadRange(ctx context.Context, fileName string, offset int64, length int64) (io.ReadCloser, error) {
+	return fsm.provider.DownloadRange(ctx, fileName, offset, length)
+}
+
+// UploadFromFile streams a local file to storage without loading it into memory
+func (fsm *FileStorageManager) UploadFromFile(ctx context.Context, fileName string, path string, contentType string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file %s: %w", path, err)
+	}
+
+	return fsm.provider.Upload(ctx, fileName, file, info.Size(), contentType)
+}
+
+// DownloadToFile streams a stored file to a local path and returns the number of bytes written
+func (fsm *FileStorageManager) DownloadToFile(ctx context.Context, fileName string, path string) (int64, error) {
+	body, _, err := fsm.provider.Download(ctx, fileName)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+
+	written, err := io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+
+	return written, nil
+}
+
 // GetSignedUrl gets a signed URL for the file using the configured provider
 func (fsm *FileStorageManager) GetSignedUrl(fileName string) (string, error) {
 	return fsm.provider.GetSignedUrl(fileName)
@@ -28,3 +91,13 @@ func (fsm *FileStorageManager) GetSignedUrl(fileName string) (string, error) {
 func (fsm *FileStorageManager) DeleteFile(fileName string) error {
 	return fsm.provider.DeleteFile(fileName)
 }
+
+// GetPresignedUpload gets a presigned direct upload for the file using the configured provider
+func (fsm *FileStorageManager) GetPresignedUpload(fileName string, contentType string, maxSizeBytes int64) (*providers.PresignedUpload, error) {
+	return fsm.provider.GetPresignedUpload(fileName, contentType, maxSizeBytes)
+}
+
+// GetFileInfo gets the stored file attributes using the configured provider
+func (fsm *FileStorageManager) GetFileInfo(fileName string) (*providers.FileInfo, error) {
+	return fsm.provider.GetFileInfo(fileName)
+}
@@ -0,0 +1,289 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"proyecto1/root/internal/config"
+)
+
+// FilesystemConfig holds the configuration for the filesystem provider
+type FilesystemConfig struct {
+	RootDir       string        // Directory where files are stored
+	BaseURL       string        // Base URL of the API route serving the files (e.g. http://localhost:8080/api/storage)
+	SigningKey    string        // HMAC key used to sign URLs
+	URLExpiration time.Duration // Lifetime of signed URLs
+}
+
+// FilesystemProvider implements IFileStorageProvider on a local directory. Files are served by
+// the API storage route using HMAC-signed expiring URLs instead of S3 presigned URLs
+type FilesystemProvider struct {
+	rootDir       string
+	baseURL       string
+	signingKey    []byte
+	urlExpiration time.Duration
+}
+
+// ErrInvalidSignature is returned when a signed URL does not match its signature
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// ErrURLExpired is returned when a signed URL is used after its expiration
+var ErrURLExpired = errors.New("signed URL expired")
+
+// NewFilesystemProvider creates a new filesystem provider instance
+func NewFilesystemProvider(cfg *FilesystemConfig) (*FilesystemProvider, error) {
+	// Anyone could forge upload and download URLs with a published key
+	if cfg.SigningKey == "" || cfg.SigningKey == config.DefaultStorageSigningKey {
+		return nil, fmt.Errorf("STORAGE_SIGNING_KEY is unset or the public default: set a random key shared by the API and the worker")
+	}
+
+	if err := os.MkdirAll(cfg.RootDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	expiration := cfg.URLExpiration
+	if expiration <= 0 {
+		expiration = 15 * time.Minute // Same default as S3 presigned URLs
+	}
+
+	return &FilesystemProvider{
+		rootDir:       cfg.RootDir,
+		baseURL:       strings.TrimSuffix(cfg.BaseURL, "/"),
+		signingKey:    []byte(cfg.SigningKey),
+		urlExpiration: expiration,
+	}, nil
+}
+
+// UploadFile writes a file buffer to disk
+func (p *FilesystemProvider) UploadFile(fileBuffer []byte, fileName string) error {
+	return p.Upload(context.TODO(), fileName, bytes.NewReader(fileBuffer), int64(len(fileBuffer)), "")
+}
+
+// Upload streams the reader to a temp file next to the destination and renames it into place,
+// so readers never see a partially written file
+func (p *FilesystemProvider) Upload(ctx context.Context, fileName string, body io.Reader, size int64, contentType string) error {
+	filePath, err := p.filePath(fileName)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	written, err := io.Copy(tmp, &contextReader{ctx: ctx, r: body})
+	if err == nil && size >= 0 && written != size {
+		err = fmt.Errorf("expected %d bytes, got %d", size, written)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return fmt.Errorf("failed to move file into place: %w", err)
+	}
+	return nil
+}
+
+// Download opens the file for reading. The caller must close the returned reader
+func (p *FilesystemProvider) Download(ctx context.Context, fileName string) (io.ReadCloser, int64, error) {
+	file, info, err := p.Open(fileName)
+	if err != nil {
+		return nil, 0, err
+	}
+	return file, info.Size(), nil
+}
+
+// DownloadRange opens the file positioned at offset and limited to length bytes. The caller must
+// close the returned reader
+func (p *FilesystemProvider) DownloadRange(ctx context.Context, fileName string, offset int64, length int64) (io.ReadCloser, error) {
+	file, _, err := p.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to seek file: %w", err)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, length), file}, nil
+}
+
+// Open opens the file and returns its attributes, used by the storage route to serve content
+func (p *FilesystemProvider) Open(fileName string) (*os.File, os.FileInfo, error) {
+	filePath, err := p.filePath(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, ErrFileNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, nil, ErrFileNotFound
+	}
+
+	return file, info, nil
+}
+
+// GetSignedUrl generates a signed expiring URL served by the API storage route
+func (p *FilesystemProvider) GetSignedUrl(fileName string) (string, error) {
+	if _, err := p.filePath(fileName); err != nil {
+		return "", err
+	}
+
+	expires := time.Now().Add(p.urlExpiration).Unix()
+	signature := p.sign(http.MethodGet, fileName, expires, 0)
+
+	return fmt.Sprintf("%s/%s?expires=%d&signature=%s", p.baseURL, escapeKey(fileName), expires, signature), nil
+}
+
+// DeleteFile deletes a file from disk. Deleting a missing file is not an error (same as S3)
+func (p *FilesystemProvider) DeleteFile(fileName string) error {
+	filePath, err := p.filePath(fileName)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// GetPresignedUpload generates a signed PUT URL to the API storage route. The signature covers
+// the maximum size, which the route enforces while writing the body
+func (p *FilesystemProvider) GetPresignedUpload(fileName string, contentType string, maxSizeBytes int64) (*PresignedUpload, error) {
+	if _, err := p.filePath(fileName); err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(p.urlExpiration)
+	signature := p.sign(http.MethodPut, fileName, expiresAt.Unix(), maxSizeBytes)
+
+	return &PresignedUpload{
+		URL: fmt.Sprintf("%s/%s?expires=%d&max_size=%d&signature=%s",
+			p.baseURL, escapeKey(fileName), expiresAt.Unix(), maxSizeBytes, signature),
+		Method:    http.MethodPut,
+		Fields:    map[string]string{"Content-Type": contentType},
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// GetFileInfo retrieves the file attributes without reading it
+func (p *FilesystemProvider) GetFileInfo(fileName string) (*FileInfo, error) {
+	file, info, err := p.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+
+	contentType := mime.TypeByExtension(filepath.Ext(fileName))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return &FileInfo{
+		Size:         info.Size(),
+		ContentType:  contentType,
+		ETag:         fmt.Sprintf("\"%x-%x\"", info.ModTime().UnixNano(), info.Size()),
+		LastModified: info.ModTime(),
+	}, nil
+}
+
+// VerifySignature checks a signed URL for the given method and key. maxSize is 0 for downloads
+func (p *FilesystemProvider) VerifySignature(method string, fileName string, expires int64, maxSize int64, signature string) error {
+	expected := p.sign(method, fileName, expires, maxSize)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	if time.Now().Unix() > expires {
+		return ErrURLExpired
+	}
+	return nil
+}
+
+// sign computes the hex HMAC-SHA256 of the request attributes
+func (p *FilesystemProvider) sign(method string, fileName string, expires int64, maxSize int64) string {
+	mac := hmac.New(sha256.New, p.signingKey)
+	fmt.Fprintf(mac, "%s\n%s\n%d\n%d", method, fileName, expires, maxSize)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// filePath maps a storage key to a path inside the root directory, rejecting keys that
+// would escape it
+func (p *FilesystemProvider) filePath(fileName string) (string, error) {
+	cleaned := path.Clean("/" + fileName)
+	if cleaned == "/" || cleaned != "/"+fileName {
+		return "", fmt.Errorf("invalid storage key: %q", fileName)
+	}
+	return filepath.Join(p.rootDir, filepath.FromSlash(cleaned)), nil
+}
+
+// escapeKey escapes each path segment of a storage key for use in a URL
+func escapeKey(fileName string) string {
+	segments := strings.Split(fileName, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// contextReader stops reading once the context is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(b []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(b)
+}
+
+// Ensure FilesystemProvider implements IFileStorageProvider
+var _ IFileStorageProvider = (*FilesystemProvider)(nil)
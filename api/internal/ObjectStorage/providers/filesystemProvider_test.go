@@ -0,0 +1,102 @@
+package providers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"proyecto1/root/internal/config"
+)
+
+func newTestFilesystemProvider(t *testing.T) *FilesystemProvider {
+	provider, err := NewFilesystemProvider(&FilesystemConfig{
+		RootDir:    t.TempDir(),
+		BaseURL:    "http://localhost:8080/api/storage/",
+		SigningKey: "test-key",
+	})
+	require.NoError(t, err)
+	return provider
+}
+
+func TestFilesystemProvider_UploadAndDownload(t *testing.T) {
+	provider := newTestFilesystemProvider(t)
+	ctx := context.Background()
+
+	err := provider.Upload(ctx, "original/1.mp4", strings.NewReader("video data"), 10, "video/mp4")
+	require.NoError(t, err)
+
+	body, size, err := provider.Download(ctx, "original/1.mp4")
+	require.NoError(t, err)
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), size)
+	assert.Equal(t, "video data", string(data))
+
+	ranged, err := provider.DownloadRange(ctx, "original/1.mp4", 6, 4)
+	require.NoError(t, err)
+	defer ranged.Close()
+	data, err = io.ReadAll(ranged)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+
+	// Size mismatch must not leave a partial file behind
+	err = provider.Upload(ctx, "original/2.mp4", strings.NewReader("short"), 10, "video/mp4")
+	assert.Error(t, err)
+	_, err = provider.GetFileInfo("original/2.mp4")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+
+	// Leftover temp files would be served as stored objects
+	entries, err := os.ReadDir(provider.rootDir + "/original")
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestFilesystemProvider_RejectsKeysOutsideRoot(t *testing.T) {
+	provider := newTestFilesystemProvider(t)
+
+	for _, key := range []string{"", "../secret", "original/../../secret", "/absolute", "original//1.mp4"} {
+		err := provider.UploadFile([]byte("x"), key)
+		assert.Error(t, err, "key %q", key)
+	}
+}
+
+func TestFilesystemProvider_SignedURL(t *testing.T) {
+	provider := newTestFilesystemProvider(t)
+
+	signedURL, err := provider.GetSignedUrl("processed/1.mp4")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signedURL, "http://localhost:8080/api/storage/processed/1.mp4?"))
+
+	parsed, err := url.Parse(signedURL)
+	require.NoError(t, err)
+	expires, err := strconv.ParseInt(parsed.Query().Get("expires"), 10, 64)
+	require.NoError(t, err)
+	signature := parsed.Query().Get("signature")
+
+	assert.NoError(t, provider.VerifySignature(http.MethodGet, "processed/1.mp4", expires, 0, signature))
+	assert.ErrorIs(t, provider.VerifySignature(http.MethodGet, "processed/2.mp4", expires, 0, signature), ErrInvalidSignature)
+	assert.ErrorIs(t, provider.VerifySignature(http.MethodPut, "processed/1.mp4", expires, 0, signature), ErrInvalidSignature)
+	assert.ErrorIs(t, provider.VerifySignature(http.MethodGet, "processed/1.mp4", expires+1, 0, signature), ErrInvalidSignature)
+
+	past := time.Now().Add(-time.Minute).Unix()
+	expired := provider.sign(http.MethodGet, "processed/1.mp4", past, 0)
+	assert.ErrorIs(t, provider.VerifySignature(http.MethodGet, "processed/1.mp4", past, 0, expired), ErrURLExpired)
+}
+
+func TestNewFilesystemProvider_RefusesDefaultSigningKey(t *testing.T) {
+	for _, key := range []string{"", config.DefaultStorageSigningKey} {
+		_, err := NewFilesystemProvider(&FilesystemConfig{RootDir: t.TempDir(), SigningKey: key})
+		assert.ErrorContains(t, err, "STORAGE_SIGNING_KEY")
+	}
+}
@@ -0,0 +1,129 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MemoryProvider implements IFileStorageProvider in memory. It is meant for tests and for
+// running a single process without external storage; files are lost on restart
+type MemoryProvider struct {
+	mu    sync.RWMutex
+	files map[string]memoryFile
+}
+
+type memoryFile struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+// NewMemoryProvider creates a new empty in-memory provider
+func NewMemoryProvider() *MemoryProvider {
+	return &MemoryProvider{
+		files: make(map[string]memoryFile),
+	}
+}
+
+// UploadFile stores a copy of the file buffer
+func (m *MemoryProvider) UploadFile(fileBuffer []byte, fileName string) error {
+	return m.Upload(context.TODO(), fileName, bytes.NewReader(fileBuffer), int64(len(fileBuffer)), "")
+}
+
+// Upload reads the whole reader and stores it under the given key
+func (m *MemoryProvider) Upload(ctx context.Context, fileName string, body io.Reader, size int64, contentType string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("failed to read file content: %w", err)
+	}
+	if size >= 0 && int64(len(data)) != size {
+		return fmt.Errorf("expected %d bytes, got %d", size, len(data))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[fileName] = memoryFile{data: data, contentType: contentType, modTime: time.Now()}
+	return nil
+}
+
+// Download returns a reader over the stored content
+func (m *MemoryProvider) Download(ctx context.Context, fileName string) (io.ReadCloser, int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	file, ok := m.files[fileName]
+	if !ok {
+		return nil, 0, ErrFileNotFound
+	}
+	return io.NopCloser(bytes.NewReader(file.data)), int64(len(file.data)), nil
+}
+
+// DownloadRange returns a reader over length bytes of the stored content starting at offset
+func (m *MemoryProvider) DownloadRange(ctx context.Context, fileName string, offset int64, length int64) (io.ReadCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	file, ok := m.files[fileName]
+	if !ok {
+		return nil, ErrFileNotFound
+	}
+
+	size := int64(len(file.data))
+	if offset < 0 || offset > size {
+		return nil, fmt.Errorf("range offset %d out of bounds (size %d)", offset, size)
+	}
+	end := min(offset+length, size)
+	return io.NopCloser(bytes.NewReader(file.data[offset:end])), nil
+}
+
+// GetSignedUrl returns a memory:// URL identifying the file
+func (m *MemoryProvider) GetSignedUrl(fileName string) (string, error) {
+	return "memory://" + fileName, nil
+}
+
+// DeleteFile removes the file if it exists
+func (m *MemoryProvider) DeleteFile(fileName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, fileName)
+	return nil
+}
+
+// GetPresignedUpload returns a memory:// upload target; callers upload with Upload directly
+func (m *MemoryProvider) GetPresignedUpload(fileName string, contentType string, maxSizeBytes int64) (*PresignedUpload, error) {
+	return &PresignedUpload{
+		URL:       "memory://" + fileName,
+		Method:    http.MethodPut,
+		Fields:    map[string]string{"Content-Type": contentType},
+		ExpiresAt: time.Now().Add(15 * time.Minute),
+	}, nil
+}
+
+// GetFileInfo retrieves the stored file attributes
+func (m *MemoryProvider) GetFileInfo(fileName string) (*FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	file, ok := m.files[fileName]
+	if !ok {
+		return nil, ErrFileNotFound
+	}
+	return &FileInfo{
+		Size:         int64(len(file.data)),
+		ContentType:  file.contentType,
+		ETag:         fmt.Sprintf("\"%x-%x\"", file.modTime.UnixNano(), len(file.data)),
+		LastModified: file.modTime,
+	}, nil
+}
+
+// Ensure MemoryProvider implements IFileStorageProvider
+var _ IFileStorageProvider = (*MemoryProvider)(nil)
@@ -3,13 +3,17 @@ package providers
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 // S3Config holds the configuration for S3 provider
@@ -19,12 +23,21 @@ type S3Config struct {
 	Region          string
 	BucketName      string
 	EndpointURL     string // For LocalStack
+	// PublicEndpointURL is the endpoint clients use to upload directly to the bucket.
+	// Only needed when it differs from EndpointURL (e.g. LocalStack inside docker-compose)
+	PublicEndpointURL string
 }
 
+// multipartPartSize is the part size used for multipart uploads. Objects smaller than one
+// part are sent with a single PutObject by the upload manager
+const multipartPartSize = 8 * 1024 * 1024 // 8MB
+
 // S3Provider implements IFileStorageProvider using AWS S3
 type S3Provider struct {
-	client     *s3.Client
-	bucketName string
+	client       *s3.Client
+	uploadClient *s3.Client // Client used to presign direct uploads (public endpoint)
+	uploader     *manager.Uploader
+	bucketName   string
 }
 
 // NewS3Provider creates a new S3 provider instance
@@ -66,18 +79,49 @@ func NewS3Provider(cfg *S3Config) (*S3Provider, error) {
 		client = s3.NewFromConfig(awsConfig)
 	}
 
+	// Presigned uploads are sent by the browser, so they must be signed for the public endpoint
+	uploadClient := client
+	if cfg.PublicEndpointURL != "" {
+		uploadClient = s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+			o.BaseEndpoint = aws.String(cfg.PublicEndpointURL)
+			o.UsePathStyle = true // Required for LocalStack
+		})
+	}
+
+	// Multipart upload manager streams large objects in parts instead of buffering them
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		u.PartSize = multipartPartSize
+	})
+
 	return &S3Provider{
-		client:     client,
-		bucketName: cfg.BucketName,
+		client:       client,
+		uploadClient: uploadClient,
+		uploader:     uploader,
+		bucketName:   cfg.BucketName,
 	}, nil
 }
 
 // UploadFile uploads a file buffer to S3
 func (s *S3Provider) UploadFile(fileBuffer []byte, fileName string) error {
-	_, err := s.client.PutObject(context.TODO(), &s3.PutObjectInput{
+	return s.Upload(context.TODO(), fileName, bytes.NewReader(fileBuffer), int64(len(fileBuffer)), "")
+}
+
+// Upload streams the reader to S3 using the multipart upload manager. The size is used to
+// pick a part size that stays within the S3 part limit (-1 if unknown)
+func (s *S3Provider) Upload(ctx context.Context, fileName string, body io.Reader, size int64, contentType string) error {
+	input := &s3.PutObjectInput{
 		Bucket: aws.String(s.bucketName),
 		Key:    aws.String(fileName),
-		Body:   bytes.NewReader(fileBuffer),
+		Body:   body,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	_, err := s.uploader.Upload(ctx, input, func(u *manager.Uploader) {
+		if partSize := size/int64(manager.MaxUploadParts) + 1; partSize > u.PartSize {
+			u.PartSize = partSize
+		}
 	})
 	if err != nil {
 		return fmt.Errorf("failed to upload file to S3: %w", err)
@@ -85,6 +129,42 @@ func (s *S3Provider) UploadFile(fileBuffer []byte, fileName string) error {
 	return nil
 }
 
+// Download opens a stream to the object content. The caller must close the returned reader
+func (s *S3Provider) Download(ctx context.Context, fileName string) (io.ReadCloser, int64, error) {
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(fileName),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, 0, ErrFileNotFound
+		}
+		return nil, 0, fmt.Errorf("failed to download file from S3: %w", err)
+	}
+
+	return result.Body, aws.ToInt64(result.ContentLength), nil
+}
+
+// DownloadRange opens a stream to length bytes of the object starting at offset (ranged GET).
+// The caller must close the returned reader
+func (s *S3Provider) DownloadRange(ctx context.Context, fileName string, offset int64, length int64) (io.ReadCloser, error) {
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(fileName),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to download file range from S3: %w", err)
+	}
+
+	return result.Body, nil
+}
+
 // GetSignedUrl generates a presigned URL for file access
 func (s *S3Provider) GetSignedUrl(fileName string) (string, error) {
 	presignClient := s3.NewPresignClient(s.client)
@@ -115,11 +195,92 @@ func (s *S3Provider) DeleteFile(fileName string) error {
 	return nil
 }
 
+// GetPresignedUpload generates a presigned POST that lets a client upload the file directly
+// to S3. The policy restricts the object size to 1..maxSizeBytes and pins the content type
+func (s *S3Provider) GetPresignedUpload(fileName string, contentType string, maxSizeBytes int64) (*PresignedUpload, error) {
+	presignClient := s3.NewPresignClient(s.uploadClient)
+	expires := 15 * time.Minute // Same lifetime as presigned downloads
+
+	request, err := presignClient.PresignPostObject(context.TODO(), &s3.PutObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(fileName),
+	}, func(opts *s3.PresignPostOptions) {
+		opts.Expires = expires
+		opts.Conditions = []interface{}{
+			[]interface{}{"content-length-range", 1, maxSizeBytes},
+			map[string]string{"Content-Type": contentType},
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create presigned upload: %w", err)
+	}
+
+	// The client has to send the Content-Type field to satisfy the policy condition
+	fields := make(map[string]string, len(request.Values)+1)
+	for key, value := range request.Values {
+		fields[key] = value
+	}
+	fields["Content-Type"] = contentType
+
+	return &PresignedUpload{
+		URL:       request.URL,
+		Method:    "POST",
+		Fields:    fields,
+		ExpiresAt: time.Now().Add(expires),
+	}, nil
+}
+
+// GetFileInfo retrieves the object header (size, content type, ETag) without downloading it
+func (s *S3Provider) GetFileInfo(fileName string) (*FileInfo, error) {
+	result, err := s.client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(fileName),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to get file info from S3: %w", err)
+	}
+
+	return &FileInfo{
+		Size:         aws.ToInt64(result.ContentLength),
+		ContentType:  aws.ToString(result.ContentType),
+		ETag:         aws.ToString(result.ETag),
+		LastModified: aws.ToTime(result.LastModified),
+	}, nil
+}
+
+// ErrFileNotFound is returned when the requested file does not exist in storage
+var ErrFileNotFound = errors.New("file not found in storage")
+
+// PresignedUpload describes how a client uploads a file directly to storage
+type PresignedUpload struct {
+	URL       string            // Upload endpoint
+	Method    string            // HTTP method to use (POST for form uploads)
+	Fields    map[string]string // Form fields that must precede the file field
+	ExpiresAt time.Time         // When the upload authorization expires
+}
+
+// FileInfo holds the stored object attributes
+type FileInfo struct {
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
 // IFileStorageProvider defines the interface for file storage operations
 type IFileStorageProvider interface {
 	UploadFile(fileBuffer []byte, fileName string) error
+	Upload(ctx context.Context, fileName string, body io.Reader, size int64, contentType string) error
+	Download(ctx context.Context, fileName string) (io.ReadCloser, int64, error)
+	DownloadRange(ctx context.Context, fileName string, offset int64, length int64) (io.ReadCloser, error)
 	GetSignedUrl(fileName string) (string, error)
 	DeleteFile(fileName string) error
+	GetPresignedUpload(fileName string, contentType string, maxSizeBytes int64) (*PresignedUpload, error)
+	GetFileInfo(fileName string) (*FileInfo, error)
 }
 
 // Ensure S3Provider implements IFileStorageProvider
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpaqueToken(t *testing.T) {
+	token, err := NewOpaqueToken()
+	require.NoError(t, err)
+	other, err := NewOpaqueToken()
+	require.NoError(t, err)
+
+	assert.Len(t, token, 43) // 32 bytes, base64 without padding
+	assert.NotEqual(t, token, other)
+
+	hash := HashToken(token)
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, HashToken(token))
+	assert.NotEqual(t, hash, HashToken(other))
+	assert.NotContains(t, hash, token)
+}
+
+func TestCreateTokenAddsJTI(t *testing.T) {
+	tokens := TokenManager{Secret: []byte("test-secret"), Issuer: "test"}
+
+	first, err := tokens.CreateToken("1", time.Minute, map[string]any{"user_id": 1})
+	require.NoError(t, err)
+	second, err := tokens.CreateToken("1", time.Minute, map[string]any{"user_id": 1})
+	require.NoError(t, err)
+
+	firstClaims, err := tokens.VerifyToken(first)
+	require.NoError(t, err)
+	secondClaims, err := tokens.VerifyToken(second)
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, firstClaims["jti"])
+	assert.NotEqual(t, firstClaims["jti"], secondClaims["jti"])
+}
+
+func TestRoleFromClaims(t *testing.T) {
+	tokens := TokenManager{Secret: []byte("test-secret"), Issuer: "test"}
+
+	token, err := tokens.CreateToken("1", time.Minute, map[string]any{"user_id": 1, "role": RoleModerator})
+	require.NoError(t, err)
+	claims, err := tokens.VerifyToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, RoleModerator, RoleFromClaims(claims))
+
+	// Tokens issued before roles existed, or with an unknown role, are players
+	assert.Equal(t, RolePlayer, RoleFromClaims(jwt.MapClaims{"user_id": 1}))
+	assert.Equal(t, RolePlayer, RoleFromClaims(jwt.MapClaims{"role": "superuser"}))
+}
@@ -2,17 +2,63 @@ package auth
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"proyecto1/root/internal/config"
 )
 
 // TokenManager encapsulates JWT signing and verification.
 type TokenManager struct {
-	// Secret is the HMAC secret for signing tokens. In production, load from configuration or a secret manager.
+	// Secret is the HMAC secret of HS256 tokens. It signs tokens only when Keys is nil.
 	Secret []byte
 	// Issuer identifies this service. Used in token claims.
 	Issuer string
+	// Keys signs tokens with RS256/EdDSA and a kid header when set.
+	Keys *KeySet
+	// AcceptHS256 keeps accepting tokens signed with Secret while Keys is set, so tokens issued
+	// before the switch to asymmetric keys stay valid until they expire.
+	AcceptHS256 bool
+	// HS256Cutover limits AcceptHS256 to tokens issued (iat) before it, so a leaked secret cannot
+	// mint new tokens after the switch.
+	HS256Cutover time.Time
+}
+
+// NewTokenManager creates the token manager of the JWT configuration: asymmetric keys when key
+// files or a key directory are configured, the HMAC secret otherwise. It refuses to use the
+// public default secret, which would let anyone forge tokens.
+func NewTokenManager(cfg config.JWTConfig) (*TokenManager, error) {
+	tokens := &TokenManager{
+		Secret:       []byte(cfg.Secret),
+		Issuer:       cfg.Issuer,
+		AcceptHS256:  cfg.AcceptHS256,
+		HS256Cutover: cfg.HS256Cutover,
+	}
+	usesSecret := len(cfg.KeyFiles) == 0 && cfg.KeyDir == ""
+	if (usesSecret || cfg.AcceptHS256) && (cfg.Secret == config.DefaultJWTSecret || cfg.Secret == "") {
+		return nil, errors.New("JWT_SECRET is unset or the public default: set a secret, or configure signing keys with JWT_ACCEPT_HS256=false")
+	}
+	if usesSecret {
+		return tokens, nil
+	}
+	if cfg.AcceptHS256 && cfg.HS256Cutover.IsZero() {
+		return nil, errors.New("JWT_HS256_CUTOVER is required with JWT_ACCEPT_HS256=true (time of the switch to signing keys, RFC 3339)")
+	}
+
+	keys, err := NewKeySet(KeySetConfig{
+		Files:            cfg.KeyFiles,
+		Dir:              cfg.KeyDir,
+		Overlap:          cfg.KeyOverlap,
+		RotationInterval: cfg.KeyRotationInterval,
+		Algorithm:        cfg.KeyAlgorithm,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing keys: %w", err)
+	}
+	tokens.Keys = keys
+	return tokens, nil
 }
 
 // RegisteredClaims returns standard JWT registered claims with configured issuer and sensible defaults.
@@ -26,7 +72,13 @@ func (t TokenManager) RegisteredClaims(subject string, ttl time.Duration) jwt.Re
 }
 
 // CreateToken signs a JWT with the provided custom claims map merged with registered claims.
+// Every token gets a unique jti, the identifier used to revoke it.
 func (t TokenManager) CreateToken(subject string, ttl time.Duration, customClaims map[string]any) (string, error) {
+	jti, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
 	claims := jwt.MapClaims{}
 	for k, v := range customClaims {
 		claims[k] = v
@@ -37,19 +89,23 @@ func (t TokenManager) CreateToken(subject string, ttl time.Duration, customClaim
 	claims["sub"] = reg.Subject
 	claims["iat"] = reg.IssuedAt.Unix()
 	claims["exp"] = reg.ExpiresAt.Unix()
+	claims["jti"] = jti
+
+	if t.Keys == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString(t.Secret)
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(t.Secret)
+	key := t.Keys.Signer()
+	token := jwt.NewWithClaims(signingMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.private)
 }
 
-// VerifyToken parses and validates a JWT and returns its claims if valid.
+// VerifyToken parses and validates a JWT and returns its claims if valid. Asymmetric tokens are
+// verified with the key named by their kid header.
 func (t TokenManager) VerifyToken(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return t.Secret, nil
-	})
+	token, err := jwt.Parse(tokenString, t.verificationKey)
 	if err != nil {
 		return nil, err
 	}
@@ -62,3 +118,40 @@ func (t TokenManager) VerifyToken(tokenString string) (jwt.MapClaims, error) {
 	}
 	return claims, nil
 }
+
+// verificationKey returns the key that must have signed the token, checking its algorithm
+func (t TokenManager) verificationKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if t.Keys != nil {
+			if !t.AcceptHS256 {
+				return nil, errors.New("HS256 tokens are no longer accepted")
+			}
+			issuedAt, err := token.Claims.GetIssuedAt()
+			if err != nil || issuedAt == nil || !issuedAt.Before(t.HS256Cutover) {
+				return nil, errors.New("HS256 token issued after the cutover")
+			}
+		}
+		return t.Secret, nil
+	}
+
+	if t.Keys == nil {
+		return nil, errors.New("unexpected signing method")
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := t.Keys.Lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.Public(), nil
+}
+
+// signingMethod returns the jwt signing method of a key algorithm
+func signingMethod(algorithm string) jwt.SigningMethod {
+	if algorithm == AlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
@@ -0,0 +1,404 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Signing algorithms of the asymmetric keys
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// minRSABits is the smallest RSA key accepted for signing
+const minRSABits = 2048
+
+// SigningKey is an asymmetric key of the key set, identified by its kid
+type SigningKey struct {
+	ID          string    // kid: RFC 7638 thumbprint of the public key
+	Algorithm   string    // RS256 or EdDSA
+	File        string    // PEM file the key was loaded from
+	ActivatesAt time.Time // Signing starts here: file modification time plus the overlap window
+	RetiresAt   time.Time // Verification stops here, zero while no newer key signs
+
+	private crypto.Signer
+}
+
+// Public returns the public key used to verify the key's signatures
+func (k *SigningKey) Public() crypto.PublicKey {
+	return k.private.Public()
+}
+
+// KeySetConfig selects the key files and the rotation schedule
+type KeySetConfig struct {
+	Files            []string      // PEM private keys (PKCS#8 RSA/Ed25519 or PKCS#1 RSA)
+	Dir              string        // Directory whose *.pem files are keys, also where rotated keys are written
+	Overlap          time.Duration // New keys are published this long before signing; old keys verify this long after
+	RotationInterval time.Duration // A new key is generated in Dir when the newest is this old (0 disables it)
+	Algorithm        string        // Algorithm of generated keys: RS256 or EdDSA
+}
+
+// KeySet holds the signing keys. A key is published in the JWKS as soon as it is loaded but only
+// signs once the overlap window has passed, so verifiers caching the JWKS already know it; a key
+// replaced by a newer one keeps verifying for the overlap window so its tokens stay valid until
+// they expire. The overlap must therefore be longer than the access token lifetime
+type KeySet struct {
+	config KeySetConfig
+	now    func() time.Time
+
+	mutex   sync.RWMutex
+	keys    []*SigningKey // Sorted by activation
+	removed map[string]*SigningKey
+}
+
+// NewKeySet loads the configured keys, generating the first one when rotation is enabled and
+// the directory is empty
+func NewKeySet(config KeySetConfig) (*KeySet, error) {
+	if len(config.Files) == 0 && config.Dir == "" {
+		return nil, errors.New("no signing key files or directory configured")
+	}
+	if config.RotationInterval > 0 && config.Dir == "" {
+		return nil, errors.New("key rotation needs a key directory")
+	}
+
+	set := &KeySet{config: config, now: time.Now, removed: make(map[string]*SigningKey)}
+	if err := set.Reload(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Run reloads the keys every interval until the context is cancelled, picking up keys added or
+// removed by hand and generating new ones when rotation is due
+func (s *KeySet) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.Reload(); err != nil {
+			log.Printf("Error reloading signing keys: %v", err)
+		}
+	}
+}
+
+// Reload reads the key files again and generates a new key when rotation is due
+func (s *KeySet) Reload() error {
+	keys, err := s.loadKeys()
+	if err != nil {
+		return err
+	}
+
+	if s.rotationDue(keys) {
+		file, err := generateKeyFile(s.config.Dir, s.config.Algorithm, s.now())
+		if err != nil {
+			return fmt.Errorf("failed to rotate signing key: %w", err)
+		}
+		log.Printf("Generated signing key %s, it signs after %v", file, s.config.Overlap)
+		if keys, err = s.loadKeys(); err != nil {
+			return err
+		}
+	}
+	if len(keys) == 0 {
+		return errors.New("no signing keys found")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Keys deleted from disk keep verifying for the overlap window
+	now := s.now()
+	loaded := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		loaded[key.ID] = true
+		delete(s.removed, key.ID)
+	}
+	for _, key := range s.keys {
+		if !loaded[key.ID] && s.removed[key.ID] == nil {
+			if retire := now.Add(s.config.Overlap); key.RetiresAt.IsZero() || key.RetiresAt.After(retire) {
+				key.RetiresAt = retire
+			}
+			s.removed[key.ID] = key
+		}
+	}
+	for id, key := range s.removed {
+		if !now.Before(key.RetiresAt) {
+			delete(s.removed, id)
+		}
+	}
+
+	// Each key retires an overlap window after its successor starts signing
+	for i := 0; i < len(keys)-1; i++ {
+		keys[i].RetiresAt = keys[i+1].ActivatesAt.Add(s.config.Overlap)
+	}
+	s.keys = keys
+	return nil
+}
+
+// loadKeys reads the key files sorted by activation
+func (s *KeySet) loadKeys() ([]*SigningKey, error) {
+	files := append([]string(nil), s.config.Files...)
+	if s.config.Dir != "" {
+		matches, err := filepath.Glob(filepath.Join(s.config.Dir, "*.pem"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list signing keys: %w", err)
+		}
+		files = append(files, matches...)
+	}
+
+	seen := make(map[string]bool)
+	var keys []*SigningKey
+	for _, file := range files {
+		key, err := loadKeyFile(file, s.config.Overlap)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[key.ID] {
+			seen[key.ID] = true
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ActivatesAt.Equal(keys[j].ActivatesAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].ActivatesAt.Before(keys[j].ActivatesAt)
+	})
+	return keys, nil
+}
+
+// rotationDue reports whether the newest key is older than the rotation interval
+func (s *KeySet) rotationDue(keys []*SigningKey) bool {
+	if s.config.RotationInterval <= 0 {
+		return false
+	}
+	if len(keys) == 0 {
+		return true
+	}
+	created := keys[len(keys)-1].ActivatesAt.Add(-s.config.Overlap)
+	return !s.now().Before(created.Add(s.config.RotationInterval))
+}
+
+// Signer returns the key that signs new tokens: the most recently activated one, or the oldest
+// key when none is active yet (e.g. the first key of a new deployment)
+func (s *KeySet) Signer() *SigningKey {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now := s.now()
+	signer := s.keys[0]
+	for _, key := range s.keys {
+		if !key.ActivatesAt.After(now) {
+			signer = key
+		}
+	}
+	return signer
+}
+
+// Lookup returns the key with the given kid if tokens signed by it are still accepted
+func (s *KeySet) Lookup(kid string) (*SigningKey, bool) {
+	for _, key := range s.Verifiers() {
+		if key.ID == kid {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// Verifiers returns the keys whose signatures are accepted: pending, signing and retiring keys
+func (s *KeySet) Verifiers() []*SigningKey {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now := s.now()
+	var keys []*SigningKey
+	for _, key := range s.keys {
+		if key.RetiresAt.IsZero() || now.Before(key.RetiresAt) {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range s.removed {
+		if now.Before(key.RetiresAt) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// JWK is the public part of a signing key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKSet is the document served at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys accepted for verification
+func (s *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range s.Verifiers() {
+		jwk := publicJWK(key.Public())
+		jwk.KeyID = key.ID
+		jwk.Use = "sig"
+		jwk.Algorithm = key.Algorithm
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
+
+// publicJWK encodes the members of a public key that define it
+func publicJWK(public crypto.PublicKey) JWK {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType: "RSA",
+			N:       base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return JWK{KeyType: "OKP", Curve: "Ed25519", X: base64.RawURLEncoding.EncodeToString(key)}
+	default:
+		return JWK{}
+	}
+}
+
+// thumbprint returns the RFC 7638 thumbprint of a public key: the SHA-256 of its required JWK
+// members in lexicographic order, so every instance derives the same kid from the same key
+func thumbprint(public crypto.PublicKey) string {
+	jwk := publicJWK(public)
+
+	var members string
+	if jwk.KeyType == "RSA" {
+		members = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N)
+	} else {
+		members = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":%q}`, jwk.X)
+	}
+
+	hash := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// loadKeyFile parses a PEM private key. It activates an overlap window after the file was written
+func loadKeyFile(path string, overlap time.Duration) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key %s is not PEM encoded", path)
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("signing key %s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s: %w", path, err)
+	}
+
+	key := &SigningKey{File: path, ActivatesAt: info.ModTime().Add(overlap)}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		if private.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("signing key %s: RSA keys must have at least %d bits", path, minRSABits)
+		}
+		key.Algorithm, key.private = AlgorithmRS256, private
+	case ed25519.PrivateKey:
+		key.Algorithm, key.private = AlgorithmEdDSA, private
+	default:
+		return nil, fmt.Errorf("signing key %s: only RSA and Ed25519 keys are supported", path)
+	}
+
+	key.ID = thumbprint(key.Public())
+	return key, nil
+}
+
+// generateKeyFile writes a new PKCS#8 private key to dir and returns its path. The key is
+// written to a temporary file and renamed, so a reload never reads a partial key
+func generateKeyFile(dir string, algorithm string, now time.Time) (string, error) {
+	var private any
+	var err error
+	switch algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, minRSABits)
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", fmt.Errorf("unsupported key algorithm %q (expected %s or %s)", algorithm, AlgorithmRS256, AlgorithmEdDSA)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	temp, err := os.CreateTemp(dir, ".key-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(temp.Name())
+
+	if err := pem.Encode(temp, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		temp.Close()
+		return "", err
+	}
+	if err := temp.Close(); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("key-%s.pem", now.UTC().Format("20060102T150405Z")))
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
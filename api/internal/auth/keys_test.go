@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"proyecto1/root/internal/config"
+)
+
+// writeKey generates a key in dir written at the given time
+func writeKey(t *testing.T, dir string, algorithm string, written time.Time) string {
+	path, err := generateKeyFile(dir, algorithm, written)
+	require.NoError(t, err)
+	require.NoError(t, os.Chtimes(path, written, written))
+	return path
+}
+
+func kids(keys []*SigningKey) []string {
+	var ids []string
+	for _, key := range keys {
+		ids = append(ids, key.ID)
+	}
+	return ids
+}
+
+func TestKeySetOverlap(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now()
+	writeKey(t, dir, AlgorithmEdDSA, start.Add(-3*time.Hour))    // Signing since 2h ago
+	writeKey(t, dir, AlgorithmRS256, start.Add(-30*time.Minute)) // Published, signs in 30m
+
+	set, err := NewKeySet(KeySetConfig{Dir: dir, Overlap: time.Hour})
+	require.NoError(t, err)
+	old, next := set.keys[0], set.keys[1]
+	assert.Equal(t, AlgorithmEdDSA, old.Algorithm)
+	assert.Equal(t, AlgorithmRS256, next.Algorithm)
+
+	// The new key is already published but the old one signs
+	assert.Equal(t, old.ID, set.Signer().ID)
+	assert.ElementsMatch(t, []string{old.ID, next.ID}, kids(set.Verifiers()))
+	jwks := set.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	for _, jwk := range jwks.Keys {
+		if jwk.KeyID == next.ID {
+			assert.Equal(t, "RSA", jwk.KeyType)
+			assert.Equal(t, "AQAB", jwk.E)
+		} else {
+			assert.Equal(t, "OKP", jwk.KeyType)
+			assert.Equal(t, "Ed25519", jwk.Curve)
+		}
+	}
+
+	// After the activation the new key signs and the old one verifies for the overlap window
+	set.now = func() time.Time { return start.Add(time.Hour) }
+	assert.Equal(t, next.ID, set.Signer().ID)
+	assert.Len(t, set.Verifiers(), 2)
+
+	set.now = func() time.Time { return start.Add(2 * time.Hour) }
+	assert.Equal(t, []string{next.ID}, kids(set.Verifiers()))
+
+	// A key deleted from disk also verifies for the overlap window
+	require.NoError(t, os.Remove(next.File))
+	writeKey(t, dir, AlgorithmEdDSA, start.Add(-2*time.Hour))
+	require.NoError(t, set.Reload())
+	_, ok := set.Lookup(next.ID)
+	assert.True(t, ok)
+	set.now = func() time.Time { return start.Add(3*time.Hour + time.Second) }
+	_, ok = set.Lookup(next.ID)
+	assert.False(t, ok)
+}
+
+func TestKeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+	set, err := NewKeySet(KeySetConfig{Dir: dir, Overlap: time.Hour, RotationInterval: 24 * time.Hour, Algorithm: AlgorithmEdDSA})
+	require.NoError(t, err)
+
+	// The first key is generated and signs straight away, as the only key
+	files, _ := filepath.Glob(filepath.Join(dir, "*.pem"))
+	require.Len(t, files, 1)
+	first := set.Signer().ID
+
+	// Reloading keeps the kid, rotation only happens once the interval has passed
+	require.NoError(t, set.Reload())
+	assert.Equal(t, first, set.Signer().ID)
+
+	set.now = func() time.Time { return time.Now().Add(25 * time.Hour) }
+	require.NoError(t, set.Reload())
+	files, _ = filepath.Glob(filepath.Join(dir, "*.pem"))
+	assert.Len(t, files, 2)
+}
+
+func TestTokenManagerKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, AlgorithmEdDSA, time.Now().Add(-2*time.Hour))
+	set, err := NewKeySet(KeySetConfig{Dir: dir, Overlap: time.Hour})
+	require.NoError(t, err)
+
+	legacy := TokenManager{Secret: []byte("test-secret"), Issuer: "test"}
+	tokens := TokenManager{Secret: legacy.Secret, Issuer: "test", Keys: set, AcceptHS256: true, HS256Cutover: time.Now().Add(time.Minute)}
+
+	signed, err := tokens.CreateToken("1", time.Minute, map[string]any{"user_id": 1})
+	require.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(signed, jwt.MapClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, "EdDSA", parsed.Method.Alg())
+	assert.Equal(t, set.Signer().ID, parsed.Header["kid"])
+
+	_, err = tokens.VerifyToken(signed)
+	assert.NoError(t, err)
+	_, err = legacy.VerifyToken(signed)
+	assert.Error(t, err, "asymmetric tokens need the key set")
+
+	// HS256 tokens issued before the cutover are accepted until AcceptHS256 is turned off
+	hs256, err := legacy.CreateToken("1", time.Minute, nil)
+	require.NoError(t, err)
+	_, err = tokens.VerifyToken(hs256)
+	assert.NoError(t, err)
+	tokens.HS256Cutover = time.Now().Add(-time.Minute)
+	_, err = tokens.VerifyToken(hs256)
+	assert.ErrorContains(t, err, "HS256 token issued after the cutover")
+	tokens.AcceptHS256 = false
+	_, err = tokens.VerifyToken(hs256)
+	assert.ErrorContains(t, err, "HS256 tokens are no longer accepted")
+
+	// Tokens naming an unknown key are rejected
+	parsed.Header["kid"] = "unknown"
+	forged, err := parsed.SignedString(set.Signer().private)
+	require.NoError(t, err)
+	_, err = tokens.VerifyToken(forged)
+	assert.ErrorContains(t, err, `unknown signing key "unknown"`)
+}
+
+func TestNewTokenManagerRefusesDefaultSecret(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, AlgorithmEdDSA, time.Now().Add(-2*time.Hour))
+
+	_, err := NewTokenManager(config.JWTConfig{Secret: config.DefaultJWTSecret})
+	assert.Error(t, err, "signing with the default secret")
+
+	_, err = NewTokenManager(config.JWTConfig{Secret: config.DefaultJWTSecret, KeyDir: dir, AcceptHS256: true, HS256Cutover: time.Now()})
+	assert.Error(t, err, "accepting HS256 with the default secret")
+
+	_, err = NewTokenManager(config.JWTConfig{Secret: "secret", KeyDir: dir, AcceptHS256: true})
+	assert.ErrorContains(t, err, "JWT_HS256_CUTOVER")
+
+	tokens, err := NewTokenManager(config.JWTConfig{Secret: config.DefaultJWTSecret, KeyDir: dir})
+	require.NoError(t, err, "the secret is unused with keys and HS256 off")
+	assert.NotNil(t, tokens.Keys)
+}
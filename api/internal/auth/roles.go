@@ -0,0 +1,29 @@
+package auth
+
+import "github.com/golang-jwt/jwt/v5"
+
+// User roles, carried in the role claim of access tokens
+const (
+	RolePlayer    = "player"    // Uploads videos and votes
+	RoleModerator = "moderator" // Also hides videos, removes fraudulent votes and refreshes rankings
+	RoleAdmin     = "admin"     // Also disables accounts and changes roles
+)
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role string) bool {
+	switch role {
+	case RolePlayer, RoleModerator, RoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// RoleFromClaims returns the role claim of a token. Tokens issued before roles existed, or
+// with an unknown role, get the player role
+func RoleFromClaims(claims jwt.MapClaims) string {
+	if role, ok := claims["role"].(string); ok && ValidRole(role) {
+		return role
+	}
+	return RolePlayer
+}
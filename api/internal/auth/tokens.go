@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// opaqueTokenBytes is the entropy of opaque tokens and token families
+const opaqueTokenBytes = 32
+
+// NewOpaqueToken generates a random token for refresh tokens and the links sent by email. Only
+// its hash (see HashToken) is stored, so a database leak does not expose usable tokens
+func NewOpaqueToken() (string, error) {
+	return randomToken()
+}
+
+// NewTokenFamily generates the identifier shared by a refresh token and all its rotations
+func NewTokenFamily() (string, error) {
+	return randomToken()
+}
+
+// HashToken returns the SHA-256 hash stored for an opaque token. A fast hash is enough because
+// the tokens are random, unlike passwords
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// randomToken returns opaqueTokenBytes random bytes encoded for URLs and JSON
+func randomToken() (string, error) {
+	buf := make([]byte, opaqueTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
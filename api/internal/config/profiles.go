@@ -0,0 +1,186 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProfileName is the profile of the built-in defaults, used when no profiles file is configured
+const DefaultProfileName = "standard"
+
+// ProfilesConfig holds the named upload profiles. The worker reads the processing settings of the
+// same file and the profile name is stored on each video, so a video is processed with the
+// profile it was validated against
+type ProfilesConfig struct {
+	File     string                       // YAML or JSON profiles file (empty uses the built-in profile)
+	Default  string                       // Profile applied when an upload does not name one
+	Profiles map[string]ValidationProfile // Upload validation rules by profile name
+}
+
+// ValidationProfile holds the upload limits of a profile
+type ValidationProfile struct {
+	MaxSizeMB          int64    `yaml:"max_size_mb"`
+	MinDurationSeconds float64  `yaml:"min_duration_seconds"`
+	MaxDurationSeconds float64  `yaml:"max_duration_seconds"`
+	MinWidth           int      `yaml:"min_width"`
+	MinHeight          int      `yaml:"min_height"`
+	AllowedContainers  []string `yaml:"allowed_containers"`   // mp4, mov, webm, mkv (detected from the content)
+	AllowedVideoCodecs []string `yaml:"allowed_video_codecs"` // ffprobe codec names, e.g. "h264"
+}
+
+// BuiltinValidationProfile returns the rules of the built-in standard profile. Profiles read
+// from the file start from these values, so they only need the settings they change
+func BuiltinValidationProfile() ValidationProfile {
+	return ValidationProfile{
+		MaxSizeMB:          100,
+		MinDurationSeconds: 20,
+		MaxDurationSeconds: 60,
+		MinWidth:           1920,
+		MinHeight:          1080,
+		AllowedContainers:  []string{"mp4", "mov", "webm", "mkv"},
+		AllowedVideoCodecs: []string{"h264", "hevc", "vp9", "av1"},
+	}
+}
+
+// profilesFile is the layout of the profiles file shared with the worker. Each profile has a
+// validation section (read here) and a processing section (read by the worker)
+type profilesFile struct {
+	Default  string               `yaml:"default"`
+	Profiles map[string]yaml.Node `yaml:"profiles"`
+}
+
+// loadProfiles reads the profiles file, if any, and applies the environment overrides
+func loadProfiles(path string, defaultName string) (ProfilesConfig, error) {
+	profiles := ProfilesConfig{
+		File:     path,
+		Default:  DefaultProfileName,
+		Profiles: map[string]ValidationProfile{DefaultProfileName: BuiltinValidationProfile()},
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return profiles, fmt.Errorf("failed to read profiles file: %w", err)
+		}
+
+		// YAML is a superset of JSON, so one decoder reads both formats
+		var file profilesFile
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return profiles, fmt.Errorf("failed to parse profiles file %s: %w", path, err)
+		}
+		if len(file.Profiles) == 0 {
+			return profiles, fmt.Errorf("profiles file %s does not define any profile", path)
+		}
+
+		profiles.Profiles = make(map[string]ValidationProfile, len(file.Profiles))
+		for name, node := range file.Profiles {
+			section := struct {
+				Validation ValidationProfile `yaml:"validation"`
+			}{Validation: BuiltinValidationProfile()}
+			if err := node.Decode(&section); err != nil {
+				return profiles, fmt.Errorf("invalid profile %q: %w", name, err)
+			}
+			profiles.Profiles[name] = section.Validation
+		}
+		if file.Default != "" {
+			profiles.Default = file.Default
+		}
+	}
+
+	if defaultName != "" {
+		profiles.Default = defaultName
+	}
+
+	for name, profile := range profiles.Profiles {
+		applyValidationOverrides(name, &profile)
+		if err := validateProfile(name, profile); err != nil {
+			return profiles, err
+		}
+		profiles.Profiles[name] = profile
+	}
+
+	if _, ok := profiles.Profiles[profiles.Default]; !ok {
+		return profiles, fmt.Errorf("default profile %q is not defined", profiles.Default)
+	}
+
+	return profiles, nil
+}
+
+// applyValidationOverrides applies the PROFILE_<NAME>_<SETTING> environment variables of a profile
+func applyValidationOverrides(name string, profile *ValidationProfile) {
+	prefix := profileEnvPrefix(name)
+
+	profile.MaxSizeMB = int64(getEnvInt(prefix+"MAX_SIZE_MB", int(profile.MaxSizeMB)))
+	profile.MinDurationSeconds = getEnvFloat(prefix+"MIN_DURATION_SECONDS", profile.MinDurationSeconds)
+	profile.MaxDurationSeconds = getEnvFloat(prefix+"MAX_DURATION_SECONDS", profile.MaxDurationSeconds)
+	profile.MinWidth = getEnvInt(prefix+"MIN_WIDTH", profile.MinWidth)
+	profile.MinHeight = getEnvInt(prefix+"MIN_HEIGHT", profile.MinHeight)
+	profile.AllowedContainers = getEnvList(prefix+"ALLOWED_CONTAINERS", profile.AllowedContainers)
+	profile.AllowedVideoCodecs = getEnvList(prefix+"ALLOWED_VIDEO_CODECS", profile.AllowedVideoCodecs)
+}
+
+// validateProfile rejects limits no upload could satisfy
+func validateProfile(name string, profile ValidationProfile) error {
+	switch {
+	case profile.MaxSizeMB <= 0:
+		return fmt.Errorf("profile %q: max_size_mb must be greater than zero", name)
+	case profile.MinDurationSeconds > profile.MaxDurationSeconds:
+		return fmt.Errorf("profile %q: min_duration_seconds is greater than max_duration_seconds", name)
+	case len(profile.AllowedContainers) == 0:
+		return fmt.Errorf("profile %q: allowed_containers is empty", name)
+	case !allKnown(profile.AllowedContainers, supportedContainers):
+		return fmt.Errorf("profile %q: allowed_containers must only list %s", name, strings.Join(supportedContainers, ", "))
+	case len(profile.AllowedVideoCodecs) == 0:
+		return fmt.Errorf("profile %q: allowed_video_codecs is empty", name)
+	}
+	return nil
+}
+
+// supportedContainers are the input containers the API can detect and the worker can read
+var supportedContainers = []string{"mp4", "mov", "webm", "mkv"}
+
+// allKnown reports whether every value is one of known
+func allKnown(values []string, known []string) bool {
+	for _, value := range values {
+		if !slices.Contains(known, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// profileEnvPrefix returns the environment variable prefix of a profile ("short-form" -> "PROFILE_SHORT_FORM_")
+func profileEnvPrefix(name string) string {
+	return "PROFILE_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name)) + "_"
+}
+
+// getEnvFloat gets an environment variable as float with a fallback default
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList gets a comma separated environment variable with a fallback default
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
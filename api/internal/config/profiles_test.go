@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProfiles(t *testing.T) {
+	profiles, err := loadProfiles("", "")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultProfileName, profiles.Default)
+	assert.Equal(t, BuiltinValidationProfile(), profiles.Profiles[DefaultProfileName])
+
+	path := filepath.Join(t.TempDir(), "profiles.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+default: standard
+profiles:
+  standard: {}
+  short-form:
+    validation:
+      max_size_mb: 50
+      min_duration_seconds: 5
+      max_duration_seconds: 15
+      allowed_video_codecs: [h264]
+    processing:
+      max_output_seconds: 15
+`), 0644))
+
+	t.Setenv("PROFILE_SHORT_FORM_MIN_HEIGHT", "720")
+	profiles, err = loadProfiles(path, "short-form")
+	require.NoError(t, err)
+
+	assert.Equal(t, "short-form", profiles.Default)
+	assert.Len(t, profiles.Profiles, 2)
+	assert.Equal(t, BuiltinValidationProfile(), profiles.Profiles["standard"])
+
+	short := profiles.Profiles["short-form"]
+	assert.Equal(t, int64(50), short.MaxSizeMB)
+	assert.Equal(t, 15.0, short.MaxDurationSeconds)
+	assert.Equal(t, 720, short.MinHeight) // Environment override
+	assert.Equal(t, 1920, short.MinWidth) // Built-in default
+	assert.Equal(t, []string{"h264"}, short.AllowedVideoCodecs)
+
+	_, err = loadProfiles(path, "missing")
+	assert.ErrorContains(t, err, `default profile "missing" is not defined`)
+}
@@ -81,6 +81,26 @@ func (db *DB) HealthCheck() error {
 	return nil
 }
 
+// WithTx runs fn inside a transaction, committing if it returns nil and rolling back otherwise
+func (db *DB) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("Error rolling back transaction: %v", rollbackErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
 // IsUniqueViolation checks if the error is a PostgreSQL unique constraint violation
 func IsUniqueViolation(err error) bool {
 	if err == nil {
@@ -0,0 +1,58 @@
+package dto
+
+import "time"
+
+// OutboxEntryResponse represents an outbox message that has not been published yet
+type OutboxEntryResponse struct {
+	ID            int64     `json:"id"`
+	VideoID       int       `json:"video_id"`
+	MessageType   string    `json:"message_type"`
+	Payload       string    `json:"payload"`
+	Attempts      int       `json:"attempts"`
+	LastError     *string   `json:"last_error"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// AdminUserResponse represents a user in the admin listing
+type AdminUserResponse struct {
+	ID            int        `json:"id"`
+	FirstName     string     `json:"first_name"`
+	LastName      string     `json:"last_name"`
+	Email         string     `json:"email"`
+	City          string     `json:"city"`
+	Country       string     `json:"country"`
+	Role          string     `json:"role"`
+	EmailVerified bool       `json:"email_verified"`
+	DisabledAt    *time.Time `json:"disabled_at"` // null while the account is active
+}
+
+// AdminUsersResponse represents a page of the admin user listing
+type AdminUsersResponse struct {
+	Users      []AdminUserResponse `json:"users"`
+	Pagination PaginationResponse  `json:"pagination"`
+}
+
+// SetRoleRequest represents the payload to change the role of a user
+type SetRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=player moderator admin"`
+}
+
+// HideVideoRequest represents the optional payload to hide a video
+type HideVideoRequest struct {
+	Reason string `json:"reason" binding:"max=500"` // Shown to the owner of the video
+}
+
+// AdminVoteResponse represents a vote cast for a video
+type AdminVoteResponse struct {
+	ID        int       `json:"id"`
+	VideoID   int       `json:"video_id"`
+	UserID    int       `json:"user_id"`
+	UserEmail string    `json:"user_email"`
+	VotedAt   time.Time `json:"voted_at"`
+}
+
+// RemovedVotesResponse reports how many votes a moderation action removed
+type RemovedVotesResponse struct {
+	Removed int64 `json:"removed"`
+}
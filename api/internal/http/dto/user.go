@@ -29,18 +29,55 @@ type LoginRequest struct {
 
 // UserResponse is the representation of a user
 type UserResponse struct {
-	ID        int    `json:"id"`
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-	Email     string `json:"email"`
-	City      string `json:"city"`
-	Country   string `json:"country"`
+	ID            int    `json:"id"`
+	FirstName     string `json:"first_name"`
+	LastName      string `json:"last_name"`
+	Email         string `json:"email"`
+	City          string `json:"city"`
+	Country       string `json:"country"`
+	EmailVerified bool   `json:"email_verified"`
+	Role          string `json:"role"` // player, moderator or admin
 }
 
 // LoginResponse represents the response for successful login
 type LoginResponse struct {
-	Token string       `json:"token"`
-	User  UserResponse `json:"user"`
+	TokenResponse
+	User UserResponse `json:"user"`
+}
+
+// TokenResponse holds a short-lived access token and the refresh token that renews it
+type TokenResponse struct {
+	Token        string `json:"token"`         // Access token sent as "Authorization: Bearer <token>"
+	ExpiresIn    int    `json:"expires_in"`    // Seconds until the access token expires
+	RefreshToken string `json:"refresh_token"` // Single-use, exchanged at /api/auth/refresh
+}
+
+// RefreshRequest represents the payload to rotate a refresh token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest represents the optional payload of logout. With a refresh token its whole
+// family is revoked, logging out every token rotated from the same login
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// VerifyEmailRequest represents the payload to verify an email with the token sent at signup
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ForgotPasswordRequest represents the payload to request a password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest represents the payload to set a new password with a reset token
+type ResetPasswordRequest struct {
+	Token     string `json:"token" binding:"required"`
+	Password1 string `json:"password1" binding:"required,min=6"`
+	Password2 string `json:"password2" binding:"required"`
 }
 
 // ErrorResponse represents error response
@@ -11,6 +11,30 @@ type VideoUploadResponse struct {
 	UploadedAt time.Time `json:"uploaded_at"`
 	UserID     int       `json:"user_id"`
 	S3Key      string    `json:"s3_key,omitempty"` // S3 storage key
+	Profile    string    `json:"profile"`          // Upload profile the video was validated against
+}
+
+// PresignedUploadRequest represents the payload to start a direct-to-storage upload
+type PresignedUploadRequest struct {
+	Title       string `json:"title" binding:"required"`
+	IsPublic    *bool  `json:"is_public" binding:"required"`
+	Filename    string `json:"filename" binding:"required"`
+	ContentType string `json:"content_type"` // Defaults to the content type of the filename extension
+	Size        int64  `json:"size" binding:"required,min=1"`
+	Profile     string `json:"profile"` // Upload profile, defaults to the configured default profile
+
+	StartOffset *float64 `json:"start_offset"` // Start of the processed clip in seconds, picked automatically when null
+}
+
+// PresignedUploadResponse tells the client how to upload the file directly to storage
+type PresignedUploadResponse struct {
+	VideoID     int               `json:"video_id"`
+	Status      string            `json:"status"`
+	UploadURL   string            `json:"upload_url"`
+	Method      string            `json:"method"`
+	Fields      map[string]string `json:"fields"` // Form fields to send before the file field
+	ExpiresAt   time.Time         `json:"expires_at"`
+	CompleteURL string            `json:"complete_url"` // Endpoint to call once the upload finished
 }
 
 // VideoResponse represents the response for video details
@@ -24,6 +48,19 @@ type VideoResponse struct {
 	OriginalURL  string     `json:"original_url"`
 	ProcessedURL string     `json:"processed_url"`
 	Votes        int        `json:"votes"`
+
+	// Processing lifecycle: why the last attempt failed, how many attempts were started and
+	// how long the last finished one took
+	FailureReason        *string    `json:"failure_reason,omitempty"`
+	Attempts             int        `json:"attempts"`
+	ProcessingStartedAt  *time.Time `json:"processing_started_at,omitempty"`
+	ProcessingDurationMs *int64     `json:"processing_duration_ms,omitempty"`
+
+	// Set while a moderator keeps the video hidden from the public listing and playback
+	HiddenAt     *time.Time `json:"hidden_at,omitempty"`
+	HiddenReason *string    `json:"hidden_reason,omitempty"`
+
+	VideoImages
 }
 
 // PublicVideoResponse represents the response for public video details (without original URL)
@@ -36,6 +73,50 @@ type PublicVideoResponse struct {
 	ProcessedAt  *time.Time `json:"processed_at,omitempty"`
 	ProcessedURL string     `json:"processed_url"`
 	Votes        int        `json:"votes"`
+
+	VideoImages
+}
+
+// VideoImages holds the signed URLs of the images generated by the worker. Empty until the
+// video is processed
+type VideoImages struct {
+	PosterURL        string   `json:"poster_url,omitempty"`
+	ThumbnailURLs    []string `json:"thumbnail_urls,omitempty"`
+	PreviewSpriteURL string   `json:"preview_sprite_url,omitempty"`
+	PreviewVTTURL    string   `json:"preview_vtt_url,omitempty"` // WebVTT for scrubbing previews, only for public videos
+}
+
+// VideoMetadataResponse holds the probed details of the uploaded file and of the processed output
+type VideoMetadataResponse struct {
+	VideoID   int                    `json:"video_id"`
+	Source    *MediaMetadataResponse `json:"source"`    // null for videos uploaded before metadata was recorded
+	Processed *MediaMetadataResponse `json:"processed"` // null until the worker processed the video
+}
+
+// MediaMetadataResponse describes one probed video file
+type MediaMetadataResponse struct {
+	Container       string    `json:"container"`
+	VideoCodec      string    `json:"video_codec"`
+	AudioCodec      string    `json:"audio_codec,omitempty"`
+	HasAudio        bool      `json:"has_audio"`
+	Width           int       `json:"width"`
+	Height          int       `json:"height"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Bitrate         int64     `json:"bitrate,omitempty"` // bits per second
+	FPS             float64   `json:"fps,omitempty"`
+	Rotation        int       `json:"rotation"` // clockwise degrees
+	SizeBytes       int64     `json:"size_bytes"`
+	ChecksumSHA256  string    `json:"checksum_sha256,omitempty"`
+	ProbedAt        time.Time `json:"probed_at"`
+
+	Trim *TrimWindowResponse `json:"trim,omitempty"` // Processed variant: part of the source that was kept
+}
+
+// TrimWindowResponse is the part of the source kept in the processed video
+type TrimWindowResponse struct {
+	StartSeconds float64 `json:"start_seconds"`
+	EndSeconds   float64 `json:"end_seconds"`
+	Selection    string  `json:"selection"` // full, auto (best scored window) or user (start_offset)
 }
 
 // PlayerRankingResponse represents a single player in the rankings
@@ -64,3 +145,21 @@ type PaginationResponse struct {
 	TotalItems  int64 `json:"total_items"`
 	TotalPages  int   `json:"total_pages"`
 }
+
+// UploadRulesResponse lists the upload limits of every configured profile
+type UploadRulesResponse struct {
+	DefaultProfile string                  `json:"default_profile"`
+	Profiles       []UploadProfileResponse `json:"profiles"`
+}
+
+// UploadProfileResponse holds the limits an upload must meet to be accepted with a profile
+type UploadProfileResponse struct {
+	Name               string   `json:"name"`
+	MaxSizeBytes       int64    `json:"max_size_bytes"`
+	MinDurationSeconds float64  `json:"min_duration_seconds"`
+	MaxDurationSeconds float64  `json:"max_duration_seconds"`
+	MinWidth           int      `json:"min_width"`
+	MinHeight          int      `json:"min_height"`
+	AllowedContainers  []string `json:"allowed_containers"`
+	AllowedVideoCodecs []string `json:"allowed_video_codecs"`
+}
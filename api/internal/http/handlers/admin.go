@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"proyecto1/root/internal/auth"
+	"proyecto1/root/internal/config"
+	"proyecto1/root/internal/database"
+	"proyecto1/root/internal/http/dto"
+	"proyecto1/root/internal/mailer"
+	"proyecto1/root/internal/rankings"
+	"proyecto1/root/internal/users"
+	"proyecto1/root/internal/videos"
+	"proyecto1/root/internal/votes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler serves the moderation and account management endpoints. Access is checked by
+// RequireRole in the router
+type AdminHandler struct {
+	userService    *users.Service
+	videoService   *videos.Service
+	voteService    *votes.Service
+	rankingService *rankings.Service
+}
+
+// NewAdminHandler creates an AdminHandler sharing the video service of the video handler
+func NewAdminHandler(db *database.DB, cfg *config.Config, tokens *auth.TokenManager, mailer mailer.Mailer, videoHandler *VideoHandler) *AdminHandler {
+	return &AdminHandler{
+		userService:    users.NewService(users.NewRepository(db), cfg, tokens, mailer),
+		videoService:   videoHandler.videoService,
+		voteService:    votes.NewService(votes.NewRepository(db)),
+		rankingService: rankings.NewService(rankings.NewRepository(db)),
+	}
+}
+
+// ListUsers lists the users, filtered by ?role=, ?email= (partial) and ?disabled=
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	var params users.ListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+
+	response, err := h.userService.ListUsers(params)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, users.ErrInvalidRole) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DisableUser disables an account: its access tokens are rejected right away, it cannot log in
+// or refresh its tokens anymore, and its votes no longer count in the rankings
+func (h *AdminHandler) DisableUser(c *gin.Context) {
+	h.setDisabled(c, true)
+}
+
+// EnableUser re-enables a disabled account
+func (h *AdminHandler) EnableUser(c *gin.Context) {
+	h.setDisabled(c, false)
+}
+
+func (h *AdminHandler) setDisabled(c *gin.Context, disabled bool) {
+	userID, ok := pathID(c, "user_id", "Invalid user ID format")
+	if !ok {
+		return
+	}
+
+	if err := h.userService.SetDisabled(c.GetInt("userID"), userID, disabled); err != nil {
+		respondUserError(c, err)
+		return
+	}
+	h.refreshRankings()
+
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "disabled": disabled})
+}
+
+// SetUserRole changes the role of a user. It applies to the access tokens issued from the next
+// login or refresh
+func (h *AdminHandler) SetUserRole(c *gin.Context) {
+	userID, ok := pathID(c, "user_id", "Invalid user ID format")
+	if !ok {
+		return
+	}
+
+	var req dto.SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.userService.SetRole(c.GetInt("userID"), userID, req.Role); err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "role": req.Role})
+}
+
+// RemoveUserVotes removes every vote cast by a user, e.g. an account created to inflate votes
+func (h *AdminHandler) RemoveUserVotes(c *gin.Context) {
+	userID, ok := pathID(c, "user_id", "Invalid user ID format")
+	if !ok {
+		return
+	}
+
+	removed, err := h.voteService.RemoveUserVotes(userID)
+	if err != nil {
+		log.Printf("Failed to remove votes of user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to remove votes",
+		})
+		return
+	}
+	if removed > 0 {
+		h.refreshRankings()
+	}
+
+	log.Printf("User %d removed %d votes cast by user %d", c.GetInt("userID"), removed, userID)
+	c.JSON(http.StatusOK, dto.RemovedVotesResponse{Removed: removed})
+}
+
+// HideVideo hides a video from the public listing, playback and rankings, with an optional
+// reason shown to its owner
+func (h *AdminHandler) HideVideo(c *gin.Context) {
+	videoID, ok := pathID(c, "video_id", "Invalid video ID format")
+	if !ok {
+		return
+	}
+
+	var req dto.HideVideoRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid request format",
+		})
+		return
+	}
+
+	h.setHidden(c, videoID, true, req.Reason)
+}
+
+// RestoreVideo makes a hidden video visible again
+func (h *AdminHandler) RestoreVideo(c *gin.Context) {
+	videoID, ok := pathID(c, "video_id", "Invalid video ID format")
+	if !ok {
+		return
+	}
+
+	h.setHidden(c, videoID, false, "")
+}
+
+func (h *AdminHandler) setHidden(c *gin.Context, videoID int, hidden bool, reason string) {
+	if err := h.videoService.SetHidden(videoID, hidden, reason); err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	h.refreshRankings()
+
+	log.Printf("User %d set hidden=%t for video %d", c.GetInt("userID"), hidden, videoID)
+	c.JSON(http.StatusOK, gin.H{"video_id": videoID, "hidden": hidden})
+}
+
+// ListVideoVotes lists the votes of a video with the email of each voter
+func (h *AdminHandler) ListVideoVotes(c *gin.Context) {
+	videoID, ok := pathID(c, "video_id", "Invalid video ID format")
+	if !ok {
+		return
+	}
+
+	videoVotes, err := h.voteService.ListVideoVotes(videoID)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	response := make([]dto.AdminVoteResponse, 0, len(videoVotes))
+	for _, vote := range videoVotes {
+		response = append(response, dto.AdminVoteResponse{
+			ID:        vote.ID,
+			VideoID:   vote.VideoID,
+			UserID:    vote.UserID,
+			UserEmail: vote.UserEmail,
+			VotedAt:   vote.VotedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RemoveVote removes a fraudulent vote
+func (h *AdminHandler) RemoveVote(c *gin.Context) {
+	voteID, ok := pathID(c, "vote_id", "Invalid vote ID format")
+	if !ok {
+		return
+	}
+
+	if err := h.voteService.RemoveVoteByID(voteID); err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	h.refreshRankings()
+
+	log.Printf("User %d removed vote %d", c.GetInt("userID"), voteID)
+	c.JSON(http.StatusOK, dto.RemovedVotesResponse{Removed: 1})
+}
+
+// RefreshRankings refreshes the player rankings now instead of waiting for the scheduled refresh
+func (h *AdminHandler) RefreshRankings(c *gin.Context) {
+	if err := h.rankingService.RefreshRankings(); err != nil {
+		log.Printf("Failed to refresh rankings: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to refresh rankings",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "rankings refreshed"})
+}
+
+// refreshRankings applies a moderation action to the rankings right away, like a vote does
+func (h *AdminHandler) refreshRankings() {
+	if err := h.rankingService.RefreshRankings(); err != nil {
+		// Log error but don't fail the response since the action succeeded
+		log.Printf("Failed to refresh rankings after moderation: %v", err)
+	}
+}
+
+// pathID parses a numeric path parameter, answering 400 with message when it is invalid
+func pathID(c *gin.Context, name string, message string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: message})
+		return 0, false
+	}
+	return id, true
+}
+
+// respondUserError maps the errors of the account actions: ErrUserNotFound to 404, invalid
+// roles and acting on your own account to 400 and the rest to 500
+func respondUserError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, users.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, users.ErrInvalidRole), errors.Is(err, users.ErrDisableSelf), errors.Is(err, users.ErrChangeOwnRole):
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+	default:
+		log.Printf("Admin action failed: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to complete the action"})
+	}
+}
+
+// respondAdminError maps the errors of the video and vote moderation actions: missing videos
+// and votes to 404 and the rest to 500
+func respondAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, videos.ErrVideoMissing), errors.Is(err, votes.ErrVideoNotFound), errors.Is(err, votes.ErrVoteNotFound):
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: err.Error()})
+	default:
+		log.Printf("Admin action failed: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to complete the action"})
+	}
+}
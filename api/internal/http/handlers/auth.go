@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
-	"time"
 
+	"proyecto1/root/internal/auth"
 	"proyecto1/root/internal/config"
 	"proyecto1/root/internal/database"
 	"proyecto1/root/internal/http/dto"
 	"proyecto1/root/internal/http/session"
+	"proyecto1/root/internal/mailer"
 	"proyecto1/root/internal/users"
 
 	"github.com/gin-gonic/gin"
@@ -15,16 +18,20 @@ import (
 
 type AuthHandler struct {
 	userService *users.Service
-	sessions    *session.InMemorySessionStore
+	sessions    session.SessionStore
+	tokens      *auth.TokenManager
 }
 
-// NewAuthHandler creates an AuthHandler with a shared session store
-func NewAuthHandler(db *database.DB, cfg *config.Config, sessionStore *session.InMemorySessionStore) *AuthHandler {
+// NewAuthHandler creates an AuthHandler with a shared session store. The token manager reads
+// the jti and expiry of the access tokens revoked on logout, and the mailer sends the
+// verification and password reset emails
+func NewAuthHandler(db *database.DB, cfg *config.Config, sessionStore session.SessionStore, tokens *auth.TokenManager, mailer mailer.Mailer) *AuthHandler {
 	repo := users.NewRepository(db)
-	service := users.NewService(repo, cfg)
+	service := users.NewService(repo, cfg, tokens, mailer)
 	return &AuthHandler{
 		userService: service,
 		sessions:    sessionStore,
+		tokens:      tokens,
 	}
 }
 
@@ -67,7 +74,11 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	// Call service layer for business logic
 	response, err := h.userService.Login(req)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+		status := http.StatusUnauthorized
+		if errors.Is(err, users.ErrEmailNotVerified) || errors.Is(err, users.ErrAccountDisabled) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, dto.ErrorResponse{
 			Error: err.Error(),
 		})
 		return
@@ -76,29 +87,157 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// Logout invalidates the provided JWT by adding it to a revoked set (simulated server-side invalidation).
+// Refresh rotates a refresh token, returning a new access token and a new refresh token
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req dto.RefreshRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid request format",
+		})
+		return
+	}
+
+	response, err := h.userService.Refresh(req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, users.ErrInvalidRefreshToken) || errors.Is(err, users.ErrAccountDisabled) {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// VerifyEmail confirms the email of an account with the token sent at signup
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req dto.VerifyEmailRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.userService.VerifyEmail(req); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, users.ErrInvalidToken) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email verified"})
+}
+
+// ForgotPassword emails a password reset link. The answer is the same whether the email has an
+// account or not
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req dto.ForgotPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.userService.ForgotPassword(req); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the email has an account, a password reset link was sent"})
+}
+
+// ResetPassword sets a new password with the token of a reset email
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req dto.ResetPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.userService.ResetPassword(req); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, users.ErrInvalidToken) || errors.Is(err, users.ErrPasswordMismatch) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password updated"})
+}
+
+// Logout revokes the refresh token family sent in the body, so the session cannot be renewed,
+// and adds the access token of the Authorization header, if any, to the session store until it
+// expires. At least one of them is required
 func (h *AuthHandler) Logout(c *gin.Context) {
-	// Typical logout for stateless JWT is performed on client by discarding the token.
-	// When server-side invalidation is required, a token blacklist/denylist is necessary.
-	// Here we simulate that using an in-memory map.
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "authorization header required"})
+	var req dto.LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "Invalid request format"})
 		return
 	}
 
-	// Expecting format: "Bearer <token>"
-	const prefix = "Bearer "
-	if len(authHeader) <= len(prefix) || authHeader[:len(prefix)] != prefix {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid authorization header"})
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" && req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorization header or refresh_token required"})
 		return
 	}
 
-	token := authHeader[len(prefix):]
-	h.sessions.RevokeToken(token, time.Now().Add(24*time.Hour))
+	if authHeader != "" {
+		// Expecting format: "Bearer <token>"
+		const prefix = "Bearer "
+		if len(authHeader) <= len(prefix) || authHeader[:len(prefix)] != prefix {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid authorization header"})
+			return
+		}
+
+		if err := h.revokeAccessToken(c, authHeader[len(prefix):]); err != nil {
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "failed to revoke token"})
+			return
+		}
+	}
+
+	if req.RefreshToken != "" {
+		if err := h.userService.Logout(req.RefreshToken); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, users.ErrInvalidRefreshToken) {
+				status = http.StatusUnauthorized
+			}
+			c.JSON(status, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
 }
 
+// revokeAccessToken revokes an access token until its exp. Invalid and expired tokens are
+// already rejected, and tokens issued without a jti expire on their own
+func (h *AuthHandler) revokeAccessToken(c *gin.Context, token string) error {
+	claims, err := h.tokens.VerifyToken(token)
+	if err != nil {
+		return nil
+	}
+
+	jti, ok := claims["jti"].(string)
+	expiresAt, err := claims.GetExpirationTime()
+	if !ok || err != nil || expiresAt == nil {
+		return nil
+	}
+
+	return h.sessions.RevokeToken(c.Request.Context(), jti, expiresAt.Time)
+}
+
 // Profile returns the currently authenticated user's profile
 func (h *AuthHandler) Profile(c *gin.Context) {
 	userIDVal, exists := c.Get("userID")
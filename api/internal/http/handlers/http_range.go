@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errUnsatisfiableRange means the Range header cannot be served (416)
+var errUnsatisfiableRange = errors.New("requested range not satisfiable")
+
+// byteRange is a single resolved byte range of a file
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+// parseRange resolves a Range header against the file size. It returns nil when the header is
+// empty or not a bytes range (the full file is served). Multiple ranges are rejected: streaming
+// players only request one and multipart responses would need every part fetched separately
+func parseRange(header string, size int64) (*byteRange, error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return nil, nil // Unknown range units are ignored (RFC 9110)
+	}
+	if strings.Contains(spec, ",") {
+		return nil, errUnsatisfiableRange
+	}
+
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil, errUnsatisfiableRange
+	}
+
+	// Suffix range: the last N bytes
+	if startStr == "" {
+		suffix, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || suffix <= 0 || size == 0 {
+			return nil, errUnsatisfiableRange
+		}
+		suffix = min(suffix, size)
+		return &byteRange{start: size - suffix, length: suffix}, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return nil, errUnsatisfiableRange
+	}
+
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return nil, errUnsatisfiableRange
+		}
+		end = min(end, size-1)
+	}
+
+	return &byteRange{start: start, length: end - start + 1}, nil
+}
+
+// notModified evaluates If-None-Match and If-Modified-Since (ignored when If-None-Match is sent)
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagListMatches(inm, etag)
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		return err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// rangeStillValid evaluates If-Range: the Range header only applies while the representation is
+// unchanged, otherwise the full file is served. Only strong ETags and exact dates validate
+func rangeStillValid(r *http.Request, etag string, lastModified time.Time) bool {
+	ifRange := r.Header.Get("If-Range")
+	if ifRange == "" {
+		return true
+	}
+
+	if strings.HasPrefix(ifRange, `"`) {
+		return etag != "" && !strings.HasPrefix(etag, "W/") && ifRange == etag
+	}
+
+	date, err := http.ParseTime(ifRange)
+	return err == nil && !lastModified.IsZero() && lastModified.Truncate(time.Second).Equal(date)
+}
+
+// etagListMatches checks a comma separated If-None-Match list using weak comparison
+func etagListMatches(list string, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
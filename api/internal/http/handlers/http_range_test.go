@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected *byteRange
+		err      bool
+	}{
+		{name: "No header", header: ""},
+		{name: "Unknown unit", header: "items=0-5"},
+		{name: "Bounded", header: "bytes=0-99", expected: &byteRange{start: 0, length: 100}},
+		{name: "Open ended", header: "bytes=900-", expected: &byteRange{start: 900, length: 100}},
+		{name: "End past size is clamped", header: "bytes=950-5000", expected: &byteRange{start: 950, length: 50}},
+		{name: "Suffix", header: "bytes=-10", expected: &byteRange{start: 990, length: 10}},
+		{name: "Suffix larger than file", header: "bytes=-5000", expected: &byteRange{start: 0, length: 1000}},
+		{name: "Start past end", header: "bytes=1000-", err: true},
+		{name: "End before start", header: "bytes=50-10", err: true},
+		{name: "Multiple ranges", header: "bytes=0-10,20-30", err: true},
+		{name: "Malformed", header: "bytes=abc", err: true},
+		{name: "Empty suffix", header: "bytes=-0", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseRange(tt.header, 1000)
+			if tt.err {
+				assert.ErrorIs(t, err, errUnsatisfiableRange)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	etag := `"abc123"`
+	lastModified := time.Date(2025, 3, 1, 10, 0, 0, 500, time.UTC)
+
+	request := func(headers map[string]string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/public/videos/1/stream", nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		return req
+	}
+
+	assert.True(t, notModified(request(map[string]string{"If-None-Match": `"other", "abc123"`}), etag, lastModified))
+	assert.True(t, notModified(request(map[string]string{"If-None-Match": `W/"abc123"`}), etag, lastModified))
+	assert.False(t, notModified(request(map[string]string{"If-None-Match": `"other"`}), etag, lastModified))
+	assert.True(t, notModified(request(map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}), etag, lastModified))
+	assert.False(t, notModified(request(map[string]string{"If-Modified-Since": lastModified.Add(-time.Hour).Format(http.TimeFormat)}), etag, lastModified))
+	// If-None-Match takes precedence over If-Modified-Since
+	assert.False(t, notModified(request(map[string]string{
+		"If-None-Match":     `"other"`,
+		"If-Modified-Since": lastModified.Format(http.TimeFormat),
+	}), etag, lastModified))
+
+	assert.True(t, rangeStillValid(request(nil), etag, lastModified))
+	assert.True(t, rangeStillValid(request(map[string]string{"If-Range": etag}), etag, lastModified))
+	assert.False(t, rangeStillValid(request(map[string]string{"If-Range": `"stale"`}), etag, lastModified))
+	assert.False(t, rangeStillValid(request(map[string]string{"If-Range": `W/"abc123"`}), `W/"abc123"`, lastModified))
+	assert.True(t, rangeStillValid(request(map[string]string{"If-Range": lastModified.Format(http.TimeFormat)}), etag, lastModified))
+	assert.False(t, rangeStillValid(request(map[string]string{"If-Range": lastModified.Add(-time.Hour).Format(http.TimeFormat)}), etag, lastModified))
+}
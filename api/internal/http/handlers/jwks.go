@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"proyecto1/root/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	tokens *auth.TokenManager
+}
+
+// NewJWKSHandler creates a handler publishing the public signing keys
+func NewJWKSHandler(tokens *auth.TokenManager) *JWKSHandler {
+	return &JWKSHandler{tokens: tokens}
+}
+
+// GetJWKS serves the public keys accepted for verification as a JSON Web Key Set, so other
+// services can verify tokens without holding a secret. The set is empty while tokens are
+// signed with the HMAC secret
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	set := auth.JWKSet{Keys: []auth.JWK{}}
+	if h.tokens.Keys != nil {
+		set = h.tokens.Keys.JWKS()
+	}
+
+	// Short enough for verifiers to see new keys well within the overlap window
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, set)
+}
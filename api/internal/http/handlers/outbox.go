@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"proyecto1/root/internal/config"
+	"proyecto1/root/internal/database"
+	"proyecto1/root/internal/http/dto"
+	"proyecto1/root/internal/outbox"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OutboxHandler struct {
+	outboxRepo *outbox.Repository
+	stuckAfter time.Duration
+}
+
+// NewOutboxHandler creates a new outbox handler
+func NewOutboxHandler(db *database.DB, cfg *config.Config) *OutboxHandler {
+	return &OutboxHandler{
+		outboxRepo: outbox.NewRepository(db),
+		stuckAfter: cfg.Outbox.StuckAfter,
+	}
+}
+
+// ListStuckEntries lists processing messages that have not been published after the
+// configured threshold (overridable with ?older_than=10m)
+func (h *OutboxHandler) ListStuckEntries(c *gin.Context) {
+	olderThan := h.stuckAfter
+	if value := c.Query("older_than"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error: "older_than must be a valid duration (e.g. 5m, 1h)",
+			})
+			return
+		}
+		olderThan = parsed
+	}
+
+	entries, err := h.outboxRepo.ListStuck(c.Request.Context(), olderThan, 100)
+	if err != nil {
+		log.Printf("Failed to list stuck outbox entries: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to list outbox entries",
+		})
+		return
+	}
+
+	response := make([]dto.OutboxEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		response = append(response, dto.OutboxEntryResponse{
+			ID:            entry.ID,
+			VideoID:       entry.VideoID,
+			MessageType:   entry.MessageType,
+			Payload:       entry.Payload,
+			Attempts:      entry.Attempts,
+			LastError:     entry.LastError,
+			NextAttemptAt: entry.NextAttemptAt,
+			CreatedAt:     entry.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"proyecto1/root/internal/ObjectStorage/providers"
+	"proyecto1/root/internal/config"
+	"proyecto1/root/internal/http/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StorageHandler serves and receives files of the filesystem storage provider using the
+// HMAC-signed URLs generated by the provider (replaces S3 presigned URLs)
+type StorageHandler struct {
+	provider *providers.FilesystemProvider
+}
+
+// NewStorageHandler creates a storage handler for the configured filesystem provider
+func NewStorageHandler(cfg *config.Config) (*StorageHandler, error) {
+	fsProvider, err := newFilesystemProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StorageHandler{
+		provider: fsProvider,
+	}, nil
+}
+
+// ServeFile streams a stored file after validating the signed URL (supports Range requests)
+func (h *StorageHandler) ServeFile(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: "Invalid signed URL"})
+		return
+	}
+
+	if err := h.provider.VerifySignature(http.MethodGet, key, expires, 0, c.Query("signature")); err != nil {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: "Invalid signed URL"})
+		return
+	}
+
+	file, info, err := h.provider.Open(key)
+	if err != nil {
+		if errors.Is(err, providers.ErrFileNotFound) {
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "File not found"})
+			return
+		}
+		log.Printf("Failed to open stored file %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	// ServeContent handles Content-Type, Range and conditional requests
+	http.ServeContent(c.Writer, c.Request, path.Base(key), info.ModTime(), file)
+}
+
+// UploadFile stores the request body using a signed upload URL. The signed maximum size is
+// enforced while reading the body
+func (h *StorageHandler) UploadFile(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	expires, expiresErr := strconv.ParseInt(c.Query("expires"), 10, 64)
+	maxSize, maxSizeErr := strconv.ParseInt(c.Query("max_size"), 10, 64)
+	if expiresErr != nil || maxSizeErr != nil {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: "Invalid signed URL"})
+		return
+	}
+
+	if err := h.provider.VerifySignature(http.MethodPut, key, expires, maxSize, c.Query("signature")); err != nil {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: "Invalid signed URL"})
+		return
+	}
+
+	if c.Request.ContentLength > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, dto.ErrorResponse{Error: "File too large"})
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+	err := h.provider.Upload(c.Request.Context(), key, body, c.Request.ContentLength, c.ContentType())
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, dto.ErrorResponse{Error: "File too large"})
+			return
+		}
+		log.Printf("Failed to store uploaded file %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to store file"})
+		return
+	}
+
+	c.Status(http.StatusCreated)
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"proyecto1/root/internal/config"
+	"proyecto1/root/internal/http/dto"
+	"proyecto1/root/internal/uploads"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// tusVersion is the only tus protocol version supported by the resumable upload endpoints
+const tusVersion = "1.0.0"
+
+// tusExtensions lists the tus protocol extensions implemented by UploadHandler
+const tusExtensions = "creation,termination,expiration"
+
+type UploadHandler struct {
+	uploadService *uploads.Service
+}
+
+// NewUploadHandler creates a handler for tus resumable uploads on the given store (shared with
+// the upload janitor started in main) that reuses the video service of the given video handler
+// for validation, persistence and enqueueing
+func NewUploadHandler(cfg *config.Config, store *uploads.FileStore, videoHandler *VideoHandler) *UploadHandler {
+	return &UploadHandler{
+		uploadService: uploads.NewService(store, videoHandler.videoService, cfg.Uploads.Expiration),
+	}
+}
+
+// Options advertises the tus capabilities of the server
+func (h *UploadHandler) Options(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(h.uploadService.MaxSize(), 10))
+	c.Status(http.StatusNoContent)
+}
+
+// CreateUpload handles the tus creation request (POST with Upload-Length and Upload-Metadata)
+func (h *UploadHandler) CreateUpload(c *gin.Context) {
+	if !h.checkTusResumable(c) {
+		return
+	}
+
+	// Get user ID from JWT claims (guaranteed to exist by AuthMiddleware)
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := int(claims["user_id"].(float64))
+
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Upload-Defer-Length is not supported, Upload-Length is required",
+		})
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "A valid Upload-Length header is required",
+		})
+		return
+	}
+
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid Upload-Metadata header: " + err.Error(),
+		})
+		return
+	}
+
+	upload, err := h.uploadService.CreateUpload(userID, length, metadata)
+	if err != nil {
+		switch {
+		case errors.Is(err, uploads.ErrUploadTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, dto.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, uploads.ErrInvalidUpload):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to create upload"})
+		}
+		return
+	}
+
+	c.Header("Location", "/api/videos/uploads/"+upload.ID)
+	c.Header("Upload-Offset", "0")
+	h.setUploadExpires(c, upload)
+	c.Status(http.StatusCreated)
+}
+
+// GetUploadOffset handles the tus HEAD request used by clients to resume an upload
+func (h *UploadHandler) GetUploadOffset(c *gin.Context) {
+	if !h.checkTusResumable(c) {
+		return
+	}
+
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := int(claims["user_id"].(float64))
+
+	upload, err := h.uploadService.GetUpload(c.Param("upload_id"), userID)
+	if err != nil {
+		if errors.Is(err, uploads.ErrUploadNotFound) {
+			c.Status(http.StatusNotFound)
+		} else {
+			c.Status(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	h.setUploadExpires(c, upload)
+	if upload.IsFinalized() {
+		c.Header("X-Video-Id", strconv.Itoa(upload.VideoID))
+	}
+	c.Status(http.StatusOK)
+}
+
+// PatchUpload handles a tus PATCH request carrying the next chunk of the upload.
+// The request that completes the upload also validates it and creates the video
+func (h *UploadHandler) PatchUpload(c *gin.Context) {
+	if !h.checkTusResumable(c) {
+		return
+	}
+
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := int(claims["user_id"].(float64))
+
+	if c.GetHeader("Content-Type") != "application/offset+octet-stream" {
+		c.JSON(http.StatusUnsupportedMediaType, dto.ErrorResponse{
+			Error: "Content-Type must be application/offset+octet-stream",
+		})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "A valid Upload-Offset header is required",
+		})
+		return
+	}
+
+	upload, response, err := h.uploadService.WriteChunk(c.Param("upload_id"), userID, offset, c.Request.Body)
+	if upload != nil {
+		// Always report the current offset so the client knows where to resume
+		c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		h.setUploadExpires(c, upload)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, uploads.ErrUploadNotFound):
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "Upload not found"})
+		case errors.Is(err, uploads.ErrOffsetMismatch):
+			c.JSON(http.StatusConflict, dto.ErrorResponse{Error: "Upload-Offset does not match the current offset"})
+		case errors.Is(err, uploads.ErrVideoRejected):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to store upload chunk"})
+		}
+		return
+	}
+
+	if response != nil {
+		c.Header("X-Video-Id", strconv.Itoa(response.ID))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// TerminateUpload handles the tus termination request (DELETE)
+func (h *UploadHandler) TerminateUpload(c *gin.Context) {
+	if !h.checkTusResumable(c) {
+		return
+	}
+
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := int(claims["user_id"].(float64))
+
+	if err := h.uploadService.Terminate(c.Param("upload_id"), userID); err != nil {
+		if errors.Is(err, uploads.ErrUploadNotFound) {
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "Upload not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to terminate upload"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// setUploadExpires sets the tus Upload-Expires header, after which the upload cannot be resumed
+func (h *UploadHandler) setUploadExpires(c *gin.Context, upload *uploads.Upload) {
+	c.Header("Upload-Expires", h.uploadService.ExpiresAt(upload).UTC().Format(http.TimeFormat))
+}
+
+// checkTusResumable sets the Tus-Resumable response header and rejects requests for
+// unsupported protocol versions with 412 Precondition Failed
+func (h *UploadHandler) checkTusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.AbortWithStatus(http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// parseUploadMetadata decodes a tus Upload-Metadata header:
+// comma-separated "key base64value" pairs, where the value may be omitted
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		switch len(parts) {
+		case 1:
+			metadata[parts[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, errors.New("value for key " + parts[0] + " is not valid base64")
+			}
+			metadata[parts[0]] = string(value)
+		default:
+			return nil, errors.New("malformed key/value pair")
+		}
+	}
+
+	return metadata, nil
+}
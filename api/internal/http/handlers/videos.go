@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -12,8 +14,7 @@ import (
 	"proyecto1/root/internal/config"
 	"proyecto1/root/internal/database"
 	"proyecto1/root/internal/http/dto"
-	"proyecto1/root/internal/messaging"
-	messagingProviders "proyecto1/root/internal/messaging/providers"
+	"proyecto1/root/internal/outbox"
 	"proyecto1/root/internal/videos"
 	"proyecto1/root/internal/votes"
 
@@ -26,17 +27,9 @@ type VideoHandler struct {
 	voteService  *votes.Service
 }
 
-func NewVideoHandler(db *database.DB, cfg *config.Config) *VideoHandler {
-	// Create storage manager based on configuration
-	storageManager := createStorageManager(cfg)
-
-	// Create message queue service
-	messageQueue := createMessageQueue(cfg)
-
-	// Create repository and service with storage manager and message queue
-	repo := videos.NewRepository(db)
-	service := videos.NewService(repo, storageManager, messageQueue)
-
+// NewVideoHandler creates the video handler on the video service shared with the pending
+// upload janitor started in main
+func NewVideoHandler(db *database.DB, service *videos.Service) *VideoHandler {
 	// Create vote service
 	voteRepo := votes.NewRepository(db)
 	voteService := votes.NewService(voteRepo)
@@ -47,36 +40,70 @@ func NewVideoHandler(db *database.DB, cfg *config.Config) *VideoHandler {
 	}
 }
 
-// createStorageManager creates S3 storage manager based on configuration
-func createStorageManager(cfg *config.Config) *ObjectStorage.FileStorageManager {
-	// S3/LocalStack configuration
-	s3Config := &providers.S3Config{
-		AccessKeyID:     cfg.AWS.AccessKeyID,
-		SecretAccessKey: cfg.AWS.SecretAccessKey,
-		Region:          cfg.AWS.Region,
-		BucketName:      cfg.AWS.S3BucketName,
-		EndpointURL:     cfg.AWS.EndpointURL, // LocalStack URL in development, empty for production AWS
-	}
-
-	// Create S3 provider (works with both LocalStack and real AWS)
-	s3Provider, err := providers.NewS3Provider(s3Config)
+// NewVideoService creates the video service with the storage provider selected in configuration,
+// for the video handler and the pending upload janitor started in main
+func NewVideoService(db *database.DB, cfg *config.Config) (*videos.Service, error) {
+	// Create storage manager based on configuration
+	storageManager, err := createStorageManager(cfg)
 	if err != nil {
-		panic("Failed to create S3 storage provider: " + err.Error())
+		return nil, err
 	}
 
-	return ObjectStorage.NewFileStorageManager(s3Provider)
+	// Create repository and service with storage manager and outbox
+	// (processing messages are published by the outbox relay started in main)
+	repo := videos.NewRepository(db)
+	return videos.NewService(repo, storageManager, outbox.NewRepository(db), cfg.Profiles), nil
+}
+
+// createStorageManager creates the storage manager for the provider selected in configuration
+func createStorageManager(cfg *config.Config) (*ObjectStorage.FileStorageManager, error) {
+	switch cfg.Storage.Provider {
+	case "s3":
+		// S3/LocalStack configuration
+		s3Config := &providers.S3Config{
+			AccessKeyID:     cfg.AWS.AccessKeyID,
+			SecretAccessKey: cfg.AWS.SecretAccessKey,
+			Region:          cfg.AWS.Region,
+			BucketName:      cfg.AWS.S3BucketName,
+			EndpointURL:     cfg.AWS.EndpointURL, // LocalStack URL in development, empty for production AWS
+
+			PublicEndpointURL: cfg.AWS.S3PublicEndpointURL, // Browser-reachable endpoint for direct uploads
+		}
+
+		// Create S3 provider (works with both LocalStack and real AWS)
+		s3Provider, err := providers.NewS3Provider(s3Config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create S3 storage provider: %w", err)
+		}
+		return ObjectStorage.NewFileStorageManager(s3Provider), nil
+
+	case "filesystem":
+		fsProvider, err := newFilesystemProvider(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return ObjectStorage.NewFileStorageManager(fsProvider), nil
+
+	case "memory":
+		return ObjectStorage.NewFileStorageManager(providers.NewMemoryProvider()), nil
+
+	default:
+		return nil, fmt.Errorf("unknown storage provider %q (expected s3, filesystem or memory)", cfg.Storage.Provider)
+	}
 }
 
-// createMessageQueue creates message queue service based on configuration
-func createMessageQueue(cfg *config.Config) messaging.MessageQueue {
-	// For now, we only have SQS implementation
-	// In the future, you can add logic to choose between different providers
-	// based on configuration (e.g., cfg.Messaging.Provider)
-	messageQueue, err := messagingProviders.NewSQSQueue(&cfg.AWS)
+// newFilesystemProvider creates the filesystem provider shared by the storage manager and the storage route
+func newFilesystemProvider(cfg *config.Config) (*providers.FilesystemProvider, error) {
+	fsProvider, err := providers.NewFilesystemProvider(&providers.FilesystemConfig{
+		RootDir:       cfg.Storage.RootDir,
+		BaseURL:       cfg.Storage.PublicBaseURL,
+		SigningKey:    cfg.Storage.SigningKey,
+		URLExpiration: cfg.Storage.URLExpiration,
+	})
 	if err != nil {
-		panic("Failed to create message queue service: " + err.Error())
+		return nil, fmt.Errorf("failed to create filesystem storage provider: %w", err)
 	}
-	return messageQueue
+	return fsProvider, nil
 }
 
 // UploadVideo handles video upload with authentication and validation
@@ -121,8 +148,20 @@ func (h *VideoHandler) UploadVideo(c *gin.Context) {
 		return
 	}
 
+	// Optional upload profile, the default profile applies when it is empty
+	profile := strings.TrimSpace(c.PostForm("profile"))
+
+	// Optional start of the processed clip, the worker picks the best window when it is empty
+	startOffset, err := videos.ParseStartOffset(c.PostForm("start_offset"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
 	// Call service layer for business logic
-	response, err := h.videoService.UploadVideo(file, title, isPublic, userID)
+	response, err := h.videoService.UploadVideo(file, title, isPublic, userID, profile, startOffset)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
 			Error: err.Error(),
@@ -133,6 +172,91 @@ func (h *VideoHandler) UploadVideo(c *gin.Context) {
 	c.JSON(http.StatusCreated, response)
 }
 
+// GetUploadRules returns the upload limits of every profile so the frontend can show them and
+// check files before uploading
+func (h *VideoHandler) GetUploadRules(c *gin.Context) {
+	c.JSON(http.StatusOK, h.videoService.GetUploadRules())
+}
+
+// CreatePresignedUpload starts a direct-to-storage upload and returns the presigned request
+func (h *VideoHandler) CreatePresignedUpload(c *gin.Context) {
+	// Get user ID from JWT claims (guaranteed to exist by AuthMiddleware)
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := int(claims["user_id"].(float64))
+
+	var req dto.PresignedUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Call service layer for business logic
+	response, err := h.videoService.CreatePresignedUpload(req.Title, *req.IsPublic, userID, req.Filename, req.ContentType, req.Size, req.Profile, req.StartOffset)
+	if err != nil {
+		if errors.Is(err, videos.ErrValidationFailed) {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error: err.Error(),
+			})
+			return
+		}
+		log.Printf("Failed to create presigned upload for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to create upload",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// CompletePresignedUpload validates a video uploaded directly to storage and enqueues it
+func (h *VideoHandler) CompletePresignedUpload(c *gin.Context) {
+	// Get user ID from JWT claims (guaranteed to exist by AuthMiddleware)
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := int(claims["user_id"].(float64))
+
+	// Convert video ID to integer
+	videoID, err := strconv.Atoi(c.Param("video_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid video ID format",
+		})
+		return
+	}
+
+	response, err := h.videoService.CompletePresignedUpload(videoID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, videos.ErrVideoNotFound):
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{
+				Error: "Video not found",
+			})
+		case errors.Is(err, videos.ErrUploadCompleted):
+			c.JSON(http.StatusConflict, dto.ErrorResponse{
+				Error: "Video upload already completed",
+			})
+		case errors.Is(err, videos.ErrUploadMissing):
+			c.JSON(http.StatusConflict, dto.ErrorResponse{
+				Error: "Video file has not been uploaded yet",
+			})
+		case errors.Is(err, videos.ErrValidationFailed):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error: err.Error(),
+			})
+		default:
+			log.Printf("Failed to complete upload for video %d: %v", videoID, err)
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+				Error: "Failed to complete upload",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
 // GetVideo retrieves video details with presigned URLs
 func (h *VideoHandler) GetVideo(c *gin.Context) {
 	// Get user ID from JWT claims
@@ -185,6 +309,16 @@ func (h *VideoHandler) GetVideo(c *gin.Context) {
 		OriginalURL:  originalURL,
 		ProcessedURL: processedURL,
 		Votes:        voteCount,
+
+		FailureReason:        video.FailureReason,
+		Attempts:             video.Attempts,
+		ProcessingStartedAt:  video.ProcessingStartedAt,
+		ProcessingDurationMs: video.ProcessingDurationMs,
+
+		HiddenAt:     video.HiddenAt,
+		HiddenReason: video.HiddenReason,
+
+		VideoImages: h.videoService.ImageURLs(video),
 	}
 
 	// Log for debugging (can be removed in production)
@@ -193,6 +327,38 @@ func (h *VideoHandler) GetVideo(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// GetVideoMetadata returns the ffprobe metadata of the uploaded and processed files of a video
+func (h *VideoHandler) GetVideoMetadata(c *gin.Context) {
+	// Get user ID from JWT claims
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := int(claims["user_id"].(float64))
+
+	videoID, err := strconv.Atoi(c.Param("video_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid video ID format",
+		})
+		return
+	}
+
+	metadata, err := h.videoService.GetVideoMetadata(videoID, userID)
+	if err != nil {
+		if errors.Is(err, videos.ErrVideoNotFound) {
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{
+				Error: "Video not found or not accessible",
+			})
+			return
+		}
+		log.Printf("Error getting metadata for video %d: %v", videoID, err)
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to get video metadata",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, metadata)
+}
+
 // GetUserVideos retrieves all videos for the authenticated user
 func (h *VideoHandler) GetUserVideos(c *gin.Context) {
 	// Get user ID from JWT claims
@@ -314,7 +480,8 @@ func (h *VideoHandler) GetPublicVideos(c *gin.Context) {
 	c.JSON(http.StatusOK, videos)
 }
 
-// StreamVideo devuelve el video procesado de forma reproducible en el navegador
+// StreamVideo devuelve el video procesado de forma reproducible en el navegador. Soporta
+// peticiones Range (206) para poder adelantar el video y GET condicional (304) para cache
 func (h *VideoHandler) StreamVideo(c *gin.Context) {
 	videoIDStr := c.Param("video_id")
 	videoID, err := strconv.Atoi(videoIDStr)
@@ -324,29 +491,138 @@ func (h *VideoHandler) StreamVideo(c *gin.Context) {
 	}
 
 	// Obtener el video por ID y validar que sea público
-	video, _, processedURL, err := h.videoService.GetVideoForPublicStream(videoID)
-	if err != nil || video == nil {
+	s3Key, info, err := h.videoService.GetPublicStreamFile(videoID)
+	if err != nil {
+		if !errors.Is(err, providers.ErrFileNotFound) && !strings.Contains(err.Error(), "not found") &&
+			!strings.Contains(err.Error(), "not public") {
+			log.Printf("Error getting stream for video %d: %v", videoID, err)
+		}
 		c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "Video not found or not public"})
 		return
 	}
 
-	resp, err := http.Get(processedURL)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to fetch video"})
+	// Validators and cache policy are sent on every response, including 304 and 416. Shared caches
+	// (nginx) keep the stream for a minute and then revalidate it with the ETag, so a video that is
+	// unpublished or reprocessed stops being served within a minute and an unchanged one costs a 304
+	if info.ETag != "" {
+		c.Header("ETag", info.ETag)
+	}
+	if !info.LastModified.IsZero() {
+		c.Header("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	}
+	c.Header("Cache-Control", "public, max-age=60")
+	c.Header("Accept-Ranges", "bytes")
+
+	if notModified(c.Request, info.ETag, info.LastModified) {
+		c.Status(http.StatusNotModified)
 		return
 	}
-	defer resp.Body.Close()
 
-	if cl := resp.Header.Get("Content-Length"); cl != "" {
-		c.Header("Content-Length", cl)
+	var requested *byteRange
+	if rangeStillValid(c.Request, info.ETag, info.LastModified) {
+		requested, err = parseRange(c.GetHeader("Range"), info.Size)
+		if err != nil {
+			c.Header("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+			c.Status(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+	}
+
+	status := http.StatusOK
+	start, length := int64(0), info.Size
+	if requested != nil {
+		status = http.StatusPartialContent
+		start, length = requested.start, requested.length
+		c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, info.Size))
 	}
 
+	c.Header("Content-Length", strconv.FormatInt(length, 10))
 	c.Header("Content-Type", "video/mp4")
 	c.Header("Content-Disposition", "inline")
-	c.Status(http.StatusOK)
+	if length == 0 {
+		c.Status(status)
+		return
+	}
+
+	body, err := h.videoService.OpenStreamRange(c.Request.Context(), s3Key, start, length)
+	if err != nil {
+		log.Printf("Error fetching video %d: %v", videoID, err)
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to fetch video"})
+		return
+	}
+	defer body.Close()
+
+	c.Status(status)
 
-	_, copyErr := io.Copy(c.Writer, resp.Body)
+	_, copyErr := io.Copy(c.Writer, body)
 	if copyErr != nil {
 		log.Printf("Error streaming video %d: %v", videoID, copyErr)
 	}
 }
+
+// GetPreviewVTT devuelve el WebVTT de previsualización (sprite firmado) para la barra de progreso
+func (h *VideoHandler) GetPreviewVTT(c *gin.Context) {
+	videoID, err := strconv.Atoi(c.Param("video_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "Invalid video ID"})
+		return
+	}
+
+	vtt, err := h.videoService.GetPreviewVTT(c.Request.Context(), videoID)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not generated"), errors.Is(err, providers.ErrFileNotFound):
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "Preview not available for this video"})
+		case strings.Contains(err.Error(), "not found"), strings.Contains(err.Error(), "not public"):
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "Video not found or not public"})
+		default:
+			log.Printf("Error getting preview VTT for video %d: %v", videoID, err)
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to get preview"})
+		}
+		return
+	}
+
+	// Short cache: the sprite URL inside expires
+	c.Header("Cache-Control", "private, max-age=60")
+	c.Data(http.StatusOK, "text/vtt; charset=utf-8", vtt)
+}
+
+// GetHLSMasterPlaylist devuelve el master playlist HLS del video procesado (streaming adaptativo)
+func (h *VideoHandler) GetHLSMasterPlaylist(c *gin.Context) {
+	h.serveHLSPlaylist(c, "")
+}
+
+// GetHLSRenditionPlaylist devuelve el playlist de una calidad con los segmentos firmados
+func (h *VideoHandler) GetHLSRenditionPlaylist(c *gin.Context) {
+	h.serveHLSPlaylist(c, c.Param("rendition"))
+}
+
+// serveHLSPlaylist writes an HLS playlist of a public video. Playlists are cached only briefly
+// because rendition playlists contain expiring signed segment URLs
+func (h *VideoHandler) serveHLSPlaylist(c *gin.Context, rendition string) {
+	videoID, err := strconv.Atoi(c.Param("video_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "Invalid video ID"})
+		return
+	}
+
+	playlist, err := h.videoService.GetHLSPlaylist(c.Request.Context(), videoID, rendition)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "invalid rendition"):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "Invalid rendition"})
+		case strings.Contains(err.Error(), "not processed"), errors.Is(err, providers.ErrFileNotFound):
+			// Videos processed before HLS output existed only have the MP4 stream
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "Adaptive stream not available for this video"})
+		case strings.Contains(err.Error(), "not found"), strings.Contains(err.Error(), "not public"):
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "Video not found or not public"})
+		default:
+			log.Printf("Error getting HLS playlist for video %d: %v", videoID, err)
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to get playlist"})
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=60")
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", playlist)
+}
@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 
@@ -9,9 +10,12 @@ import (
 )
 
 // AuthMiddleware verifies JWT and optionally checks server-side revocation.
-// Pass a function to check whether a token has been revoked (e.g., in-memory or Redis),
-// or nil if you only want stateless JWT validation.
-func AuthMiddleware(tokens auth.TokenManager, isRevoked func(token string) bool) gin.HandlerFunc {
+// Pass a function to check whether the token with a jti has been revoked (e.g., the session
+// store), or nil if you only want stateless JWT validation. isDisabled, if not nil, checks the
+// account of the token, so a disabled user loses access right away instead of when the token
+// expires.
+func AuthMiddleware(tokens auth.TokenManager, isRevoked func(ctx context.Context, jti string) (bool, error),
+	isDisabled func(ctx context.Context, userID int) (bool, error)) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		const prefix = "Bearer "
@@ -22,12 +26,6 @@ func AuthMiddleware(tokens auth.TokenManager, isRevoked func(token string) bool)
 
 		tokenString := authHeader[len(prefix):]
 
-		// Check revocation
-		if isRevoked != nil && isRevoked(tokenString) {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token revoked"})
-			return
-		}
-
 		// Verify and parse claims
 		claims, err := tokens.VerifyToken(tokenString)
 		if err != nil {
@@ -35,6 +33,20 @@ func AuthMiddleware(tokens auth.TokenManager, isRevoked func(token string) bool)
 			return
 		}
 
+		// Check revocation (tokens issued without a jti cannot be revoked and expire on their own)
+		if jti, ok := claims["jti"].(string); ok && isRevoked != nil {
+			revoked, err := isRevoked(c.Request.Context(), jti)
+			if err != nil {
+				// Fail closed: a revoked token must not pass while the store is unreachable
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "could not check token revocation"})
+				return
+			}
+			if revoked {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token revoked"})
+				return
+			}
+		}
+
 		// Extract user_id from claims
 		if rawID, ok := claims["user_id"]; ok {
 			switch v := rawID.(type) {
@@ -49,6 +61,22 @@ func AuthMiddleware(tokens auth.TokenManager, isRevoked func(token string) bool)
 			}
 		}
 
+		// Check the account (fails closed like the revocation check)
+		if userID, ok := c.Get("userID"); ok && isDisabled != nil {
+			disabled, err := isDisabled(c.Request.Context(), userID.(int))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "could not check account status"})
+				return
+			}
+			if disabled {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "account disabled"})
+				return
+			}
+		}
+
+		// Role checked by RequireRole (player for tokens issued before roles existed)
+		c.Set("role", auth.RoleFromClaims(claims))
+
 		// Still set all claims if you want them
 		c.Set("claims", claims)
 
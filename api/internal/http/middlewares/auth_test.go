@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"proyecto1/root/internal/auth"
+	"proyecto1/root/internal/config"
+)
+
+func TestAuthMiddlewareRejectsDisabledAccounts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tokens, err := auth.NewTokenManager(config.JWTConfig{Secret: "test-secret"})
+	require.NoError(t, err)
+	token, err := tokens.CreateToken("7", time.Minute, map[string]any{"user_id": 7})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		disabled bool
+		err      error
+		status   int
+	}{
+		{name: "active account", status: http.StatusOK},
+		{name: "disabled account", disabled: true, status: http.StatusForbidden},
+		{name: "status unavailable", err: errors.New("connection refused"), status: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var checked int
+			isDisabled := func(ctx context.Context, userID int) (bool, error) {
+				checked = userID
+				return tt.disabled, tt.err
+			}
+
+			router := gin.New()
+			router.GET("/profile", AuthMiddleware(*tokens, nil, isDisabled), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			request := httptest.NewRequest(http.MethodGet, "/profile", nil)
+			request.Header.Set("Authorization", "Bearer "+token)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(t, tt.status, recorder.Code)
+			assert.Equal(t, 7, checked)
+		})
+	}
+}
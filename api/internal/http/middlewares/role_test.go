@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		role   string // Empty leaves the role unset, as without AuthMiddleware
+		status int
+	}{
+		{name: "allowed role", role: "admin", status: http.StatusOK},
+		{name: "other allowed role", role: "moderator", status: http.StatusOK},
+		{name: "insufficient role", role: "player", status: http.StatusForbidden},
+		{name: "not authenticated", role: "", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/admin",
+				func(c *gin.Context) {
+					if tt.role != "" {
+						c.Set("role", tt.role)
+					}
+				},
+				RequireRole("moderator", "admin"),
+				func(c *gin.Context) { c.Status(http.StatusOK) },
+			)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin", nil))
+			assert.Equal(t, tt.status, recorder.Code)
+		})
+	}
+}
@@ -7,45 +7,60 @@ import (
 	"proyecto1/root/internal/http/handlers"
 	"proyecto1/root/internal/http/middlewares"
 	"proyecto1/root/internal/http/session"
+	"proyecto1/root/internal/mailer"
+	"proyecto1/root/internal/uploads"
+	"proyecto1/root/internal/users"
+	"proyecto1/root/internal/videos"
 
 	"github.com/gin-gonic/gin"
 )
 
-func NewRouter(cfg *config.Config, db *database.DB) *gin.Engine {
+// NewRouter creates the API routes. The session store holds the access tokens revoked by logout
+// and is shared with the other API instances; the token manager signs and verifies the JWTs and
+// the mailer sends the account emails. The video service and the resumable upload store are
+// shared with the janitors started in main
+func NewRouter(cfg *config.Config, db *database.DB, sessionStore session.SessionStore, tokenManager *auth.TokenManager, mailer mailer.Mailer, videoService *videos.Service, uploadStore *uploads.FileStore) (*gin.Engine, error) {
 	router := gin.New()
 	router.Use(gin.Logger(), gin.Recovery())
 
 	// CORS is handled entirely by nginx reverse proxy
 	// All requests come through nginx, so no CORS configuration needed here
 
-	// Initialize shared session store for token revocation
-	sessionStore := session.NewInMemorySessionStore()
-
 	// Initialize handlers (passing shared session store to auth handler)
-	authHandler := handlers.NewAuthHandler(db, cfg, sessionStore)
-	videoHandler := handlers.NewVideoHandler(db, cfg)
+	authHandler := handlers.NewAuthHandler(db, cfg, sessionStore, tokenManager, mailer)
+	videoHandler := handlers.NewVideoHandler(db, videoService)
+	uploadHandler := handlers.NewUploadHandler(cfg, uploadStore, videoHandler)
 	voteHandler := handlers.NewVoteHandler(db)
 	rankingHandler := handlers.NewRankingHandler(db)
 	healthHandler := handlers.NewHealthHandler(db, videoHandler)
+	outboxHandler := handlers.NewOutboxHandler(db, cfg)
+	jwksHandler := handlers.NewJWKSHandler(tokenManager)
+	adminHandler := handlers.NewAdminHandler(db, cfg, tokenManager, mailer, videoHandler)
 
-	// Initialize auth middleware with shared session store
-	tokenManager := &auth.TokenManager{
-		Secret: []byte(cfg.JWT.Secret),
-		Issuer: cfg.JWT.Issuer,
-	}
-	authMiddleware := middlewares.AuthMiddleware(*tokenManager, sessionStore.IsTokenRevoked)
+	// Initialize auth middleware with shared session store, rejecting the tokens of disabled accounts
+	authMiddleware := middlewares.AuthMiddleware(*tokenManager, sessionStore.IsTokenRevoked, users.NewRepository(db).IsDisabled)
+
+	// Public keys verifying the access tokens (no authentication required)
+	router.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
 
 	api := router.Group("/api")
 	{
 		// Health check endpoint
 		api.GET("/health", healthHandler.Health)
 
-		auth := api.Group("/auth")
+		// Upload limits of the configured profiles (no authentication required)
+		api.GET("/config/upload-rules", videoHandler.GetUploadRules)
+
+		authRoutes := api.Group("/auth")
 		{
-			auth.POST("/signup", authHandler.Signup)
-			auth.POST("/login", authHandler.Login)
-			auth.POST("/logout", authHandler.Logout)
-			auth.GET("/profile", authMiddleware, authHandler.Profile)
+			authRoutes.POST("/signup", authHandler.Signup)
+			authRoutes.POST("/login", authHandler.Login)
+			authRoutes.POST("/refresh", authHandler.Refresh)
+			authRoutes.POST("/logout", authHandler.Logout)
+			authRoutes.POST("/verify-email", authHandler.VerifyEmail)
+			authRoutes.POST("/forgot-password", authHandler.ForgotPassword)
+			authRoutes.POST("/reset-password", authHandler.ResetPassword)
+			authRoutes.GET("/profile", authMiddleware, authHandler.Profile)
 		}
 
 		videos := api.Group("/videos")
@@ -53,7 +68,19 @@ func NewRouter(cfg *config.Config, db *database.DB) *gin.Engine {
 			videos.POST("/upload", authMiddleware, videoHandler.UploadVideo)
 			videos.GET("/", authMiddleware, videoHandler.GetUserVideos)
 			videos.GET("/:video_id", authMiddleware, videoHandler.GetVideo)
+			videos.GET("/:video_id/metadata", authMiddleware, videoHandler.GetVideoMetadata)
 			videos.DELETE("/:video_id", authMiddleware, videoHandler.DeleteVideo)
+
+			// Resumable uploads (tus 1.0 protocol)
+			videos.OPTIONS("/uploads", uploadHandler.Options)
+			videos.POST("/uploads", authMiddleware, uploadHandler.CreateUpload)
+			videos.HEAD("/uploads/:upload_id", authMiddleware, uploadHandler.GetUploadOffset)
+			videos.PATCH("/uploads/:upload_id", authMiddleware, uploadHandler.PatchUpload)
+			videos.DELETE("/uploads/:upload_id", authMiddleware, uploadHandler.TerminateUpload)
+
+			// Direct-to-storage uploads (presigned POST + completion)
+			videos.POST("/presigned-upload", authMiddleware, videoHandler.CreatePresignedUpload)
+			videos.POST("/:video_id/complete", authMiddleware, videoHandler.CompletePresignedUpload)
 		}
 
 		public := api.Group("/public")
@@ -64,11 +91,52 @@ func NewRouter(cfg *config.Config, db *database.DB) *gin.Engine {
 			public.POST("/videos/:video_id/vote", authMiddleware, voteHandler.VoteForVideo)
 			public.DELETE("/videos/:video_id/vote", authMiddleware, voteHandler.UnvoteForVideo)
 			public.GET("/videos/:video_id/stream", videoHandler.StreamVideo)
+			public.GET("/videos/:video_id/preview.vtt", videoHandler.GetPreviewVTT)
+			public.GET("/videos/:video_id/hls/master.m3u8", videoHandler.GetHLSMasterPlaylist)
+			public.GET("/videos/:video_id/hls/:rendition/index.m3u8", videoHandler.GetHLSRenditionPlaylist)
 
 			// Rankings endpoints (no authentication required)
 			public.GET("/rankings", rankingHandler.GetPlayerRankings)
 		}
+
+		admin := api.Group("/admin")
+		{
+			// Moderation (moderator or admin role)
+			moderation := admin.Group("", authMiddleware, middlewares.RequireRole(auth.RoleModerator, auth.RoleAdmin))
+			{
+				moderation.GET("/users", adminHandler.ListUsers)
+				moderation.DELETE("/users/:user_id/votes", adminHandler.RemoveUserVotes)
+				moderation.POST("/videos/:video_id/hide", adminHandler.HideVideo)
+				moderation.POST("/videos/:video_id/restore", adminHandler.RestoreVideo)
+				moderation.GET("/videos/:video_id/votes", adminHandler.ListVideoVotes)
+				moderation.DELETE("/votes/:vote_id", adminHandler.RemoveVote)
+				moderation.POST("/rankings/refresh", adminHandler.RefreshRankings)
+			}
+
+			// Account management (admin role)
+			accounts := admin.Group("", authMiddleware, middlewares.RequireRole(auth.RoleAdmin))
+			{
+				accounts.POST("/users/:user_id/disable", adminHandler.DisableUser)
+				accounts.POST("/users/:user_id/enable", adminHandler.EnableUser)
+				accounts.PUT("/users/:user_id/role", adminHandler.SetUserRole)
+
+				// Operational endpoints for scripts and monitoring
+				accounts.GET("/outbox/stuck", outboxHandler.ListStuckEntries)
+			}
+		}
+
+		// Signed file URLs of the filesystem storage provider (authorized by signature, not JWT)
+		if cfg.Storage.Provider == "filesystem" {
+			storageHandler, err := handlers.NewStorageHandler(cfg)
+			if err != nil {
+				return nil, err
+			}
+
+			api.GET("/storage/*key", storageHandler.ServeFile)
+			api.HEAD("/storage/*key", storageHandler.ServeFile)
+			api.PUT("/storage/*key", storageHandler.UploadFile)
+		}
 	}
 
-	return router
+	return router, nil
 }
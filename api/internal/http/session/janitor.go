@@ -0,0 +1,31 @@
+package session
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunJanitor removes the revocations of expired tokens every interval until the context is
+// cancelled. Every API instance runs one; the cleanup is idempotent
+func RunJanitor(ctx context.Context, store SessionStore, interval time.Duration) {
+	log.Printf("Session janitor started (every %v)", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Session janitor stopping due to context cancellation")
+			return
+		case <-ticker.C:
+		}
+
+		cleanCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		if err := store.CleanExpiredTokens(cleanCtx); err != nil {
+			log.Printf("Error cleaning expired token revocations: %v", err)
+		}
+		cancel()
+	}
+}
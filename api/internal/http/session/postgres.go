@@ -0,0 +1,63 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"proyecto1/root/internal/database"
+)
+
+// PostgresSessionStore keeps revoked tokens in the revoked_tokens table, shared by every API
+// instance and kept across restarts
+type PostgresSessionStore struct {
+	db *database.DB
+}
+
+// NewPostgresSessionStore creates a new Postgres-backed session store
+func NewPostgresSessionStore(db *database.DB) *PostgresSessionStore {
+	return &PostgresSessionStore{db: db}
+}
+
+// RevokeToken inserts the revocation, revoking a token twice keeps the first row
+func (s *PostgresSessionStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING`
+
+	if _, err := s.db.ExecContext(ctx, query, jti, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// IsTokenRevoked checks for a revocation that has not expired yet
+func (s *PostgresSessionStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = $1 AND expires_at > NOW())`
+
+	var revoked bool
+	if err := s.db.QueryRowContext(ctx, query, jti).Scan(&revoked); err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	return revoked, nil
+}
+
+// CleanExpiredTokens deletes the revocations of expired tokens
+func (s *PostgresSessionStore) CleanExpiredTokens(ctx context.Context) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at <= NOW()`)
+	if err != nil {
+		return fmt.Errorf("failed to clean expired tokens: %w", err)
+	}
+
+	if deleted, err := result.RowsAffected(); err == nil && deleted > 0 {
+		fmt.Printf("Removed %d expired token revocations\n", deleted)
+	}
+	return nil
+}
+
+// Close closes the connection to the session store
+func (s *PostgresSessionStore) Close() error {
+	// The database connection is owned by the caller
+	return nil
+}
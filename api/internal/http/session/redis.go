@@ -0,0 +1,61 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// redisKeyPrefix namespaces the revocation keys in a shared Redis database
+const redisKeyPrefix = "revoked_token:"
+
+// RedisSessionStore keeps revoked tokens in Redis (or any server speaking its protocol, such as
+// Valkey or KeyDB). Each revocation is a key that expires with the token, so Redis removes
+// them by itself
+type RedisSessionStore struct {
+	client *redisClient
+}
+
+// NewRedisSessionStore creates a session store on the Redis server at addr, with up to poolSize
+// connections. Connections are opened by the commands that need them
+func NewRedisSessionStore(addr string, password string, db int, poolSize int) *RedisSessionStore {
+	return &RedisSessionStore{client: newRedisClient(addr, password, db, poolSize)}
+}
+
+// RevokeToken sets the revocation key with the remaining lifetime of the token
+func (s *RedisSessionStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt).Milliseconds()
+	if ttl <= 0 {
+		return nil // Already expired, nothing to revoke
+	}
+
+	if _, err := s.client.Do(ctx, "SET", redisKeyPrefix+jti, "1", "PX", strconv.FormatInt(ttl, 10)); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// IsTokenRevoked checks whether the revocation key exists
+func (s *RedisSessionStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	reply, err := s.client.Do(ctx, "EXISTS", redisKeyPrefix+jti)
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+
+	count, ok := reply.(int64)
+	if !ok {
+		return false, fmt.Errorf("failed to check token revocation: unexpected reply %v", reply)
+	}
+	return count > 0, nil
+}
+
+// CleanExpiredTokens implements SessionStore, Redis expires the keys itself
+func (s *RedisSessionStore) CleanExpiredTokens(ctx context.Context) error {
+	return nil
+}
+
+// Close closes the connections to Redis
+func (s *RedisSessionStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,172 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis serves the commands sent by RedisSessionStore on a local port
+type fakeRedis struct {
+	mutex sync.Mutex
+	keys  map[string]time.Time // Key -> expiry
+}
+
+func startFakeRedis(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	server := &fakeRedis{keys: make(map[string]time.Time)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		command, err := readReply(reader)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range command.([]any) {
+			args = append(args, arg.(string))
+		}
+		if strings.EqualFold(args[0], "DEBUG") {
+			seconds, _ := strconv.ParseFloat(args[2], 64)
+			time.Sleep(time.Duration(seconds * float64(time.Second)))
+		}
+		fmt.Fprint(conn, f.execute(args))
+	}
+}
+
+func (f *fakeRedis) execute(args []string) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "DEBUG": // DEBUG SLEEP seconds, answered by serve
+		return "+OK\r\n"
+	case "SET": // SET key value PX milliseconds
+		ttl, _ := strconv.Atoi(args[4])
+		f.keys[args[1]] = time.Now().Add(time.Duration(ttl) * time.Millisecond)
+		return "+OK\r\n"
+	case "EXISTS":
+		if expiry, ok := f.keys[args[1]]; ok && time.Now().Before(expiry) {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+// TestRedisSessionStore runs against a local fake server, or against a real server when
+// REDIS_TEST_ADDR is set (e.g. REDIS_TEST_ADDR=localhost:6379)
+func TestRedisSessionStore(t *testing.T) {
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		addr = startFakeRedis(t)
+	}
+	store := NewRedisSessionStore(addr, "", 0, 2)
+	defer store.Close()
+	ctx := context.Background()
+
+	jti := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	revoked, err := store.IsTokenRevoked(ctx, jti)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	require.NoError(t, store.RevokeToken(ctx, jti, time.Now().Add(time.Minute)))
+	revoked, err = store.IsTokenRevoked(ctx, jti)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	// Expired tokens are not stored, and revocations expire with the token
+	require.NoError(t, store.RevokeToken(ctx, jti+"-expired", time.Now().Add(-time.Second)))
+	require.NoError(t, store.RevokeToken(ctx, jti+"-short", time.Now().Add(50*time.Millisecond)))
+	time.Sleep(100 * time.Millisecond)
+	for _, key := range []string{jti + "-expired", jti + "-short"} {
+		revoked, err = store.IsTokenRevoked(ctx, key)
+		require.NoError(t, err)
+		assert.False(t, revoked, key)
+	}
+
+	// Error replies keep the connection, network errors drop it and the next command reconnects
+	_, err = store.client.Do(ctx, "NOSUCHCOMMAND")
+	assert.IsType(t, redisError(""), err)
+	require.NoError(t, store.client.Close())
+	revoked, err = store.IsTokenRevoked(ctx, jti)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestRedisSessionStore_SlowReplyDoesNotStallOtherCommands(t *testing.T) {
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		addr = startFakeRedis(t)
+	}
+	store := NewRedisSessionStore(addr, "", 0, 2)
+	defer store.Close()
+	ctx := context.Background()
+
+	slow := make(chan error)
+	go func() {
+		_, err := store.client.Do(ctx, "DEBUG", "SLEEP", "0.5")
+		slow <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	started := time.Now()
+	_, err := store.IsTokenRevoked(ctx, "other")
+	require.NoError(t, err)
+	assert.Less(t, time.Since(started), 250*time.Millisecond, "served on another connection of the pool")
+	require.NoError(t, <-slow)
+}
+
+func TestReadReply(t *testing.T) {
+	reply, err := readReply(bufio.NewReader(strings.NewReader("*3\r\n$3\r\nfoo\r\n:42\r\n$-1\r\n")))
+	require.NoError(t, err)
+	assert.Equal(t, []any{"foo", int64(42), nil}, reply)
+
+	_, err = readReply(bufio.NewReader(strings.NewReader("-WRONGPASS invalid password\r\n")))
+	assert.EqualError(t, err, "redis: WRONGPASS invalid password")
+
+	assert.Equal(t, "*2\r\n$6\r\nEXISTS\r\n$3\r\nkey\r\n", string(encodeCommand([]string{"EXISTS", "key"})))
+}
+
+func TestInMemorySessionStore(t *testing.T) {
+	store := NewInMemorySessionStore()
+	ctx := context.Background()
+
+	require.NoError(t, store.RevokeToken(ctx, "active", time.Now().Add(time.Minute)))
+	require.NoError(t, store.RevokeToken(ctx, "expired", time.Now().Add(-time.Second)))
+
+	revoked, _ := store.IsTokenRevoked(ctx, "active")
+	assert.True(t, revoked)
+	revoked, _ = store.IsTokenRevoked(ctx, "expired")
+	assert.False(t, revoked)
+
+	require.NoError(t, store.CleanExpiredTokens(ctx))
+	assert.Len(t, store.revoked, 1)
+}
@@ -0,0 +1,245 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisTimeout bounds each command when the context has no deadline
+const redisTimeout = 5 * time.Second
+
+// redisError is an error reply sent by the server ("-ERR unknown command")
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// redisClient is a minimal client of the Redis protocol (RESP 2): enough for the few commands
+// the session store sends. Commands run on a pool of at most poolSize connections, opened on
+// demand and reused; a connection is dropped after any network or protocol error
+type redisClient struct {
+	addr     string
+	password string
+	db       int
+
+	slots chan struct{} // One per connection in use, bounds the pool
+	mutex sync.Mutex
+	idle  []*redisConn
+}
+
+// redisConn is a pooled connection with its reader
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// newRedisClient creates a client for the server at addr with up to poolSize connections. No
+// connection is opened yet
+func newRedisClient(addr string, password string, db int, poolSize int) *redisClient {
+	if poolSize < 1 {
+		poolSize = 1
+	}
+	return &redisClient{addr: addr, password: password, db: db, slots: make(chan struct{}, poolSize)}
+}
+
+// Do sends a command and returns its reply: string for simple and bulk strings, int64 for
+// integers, nil for null replies and []any for arrays. Error replies are returned as redisError.
+// A command that fails on a reused connection, which the server may have closed while idle, is
+// sent once more on a new one (the store only sends idempotent commands)
+func (c *redisClient) Do(ctx context.Context, args ...string) (any, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, redisTimeout)
+		defer cancel()
+	}
+
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("no redis connection available: %w", ctx.Err())
+	}
+	defer func() { <-c.slots }()
+
+	conn, reused, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.roundTrip(ctx, args)
+	if _, isReply := err.(redisError); err != nil && !isReply && reused {
+		conn.close()
+		if conn, err = c.dial(ctx); err != nil {
+			return nil, err
+		}
+		reply, err = conn.roundTrip(ctx, args)
+	}
+
+	if _, isReply := err.(redisError); err != nil && !isReply {
+		// The connection state is unknown after a network or protocol error
+		conn.close()
+		return nil, err
+	}
+	c.put(conn)
+	return reply, err
+}
+
+// Close closes the idle connections. Connections in use are returned to the pool as usual
+func (c *redisClient) Close() error {
+	c.mutex.Lock()
+	idle := c.idle
+	c.idle = nil
+	c.mutex.Unlock()
+
+	var firstErr error
+	for _, conn := range idle {
+		if err := conn.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// get returns an idle connection, or opens a new one. reused tells which
+func (c *redisClient) get(ctx context.Context) (*redisConn, bool, error) {
+	c.mutex.Lock()
+	if n := len(c.idle); n > 0 {
+		conn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mutex.Unlock()
+		return conn, true, nil
+	}
+	c.mutex.Unlock()
+
+	conn, err := c.dial(ctx)
+	return conn, false, err
+}
+
+// put returns a healthy connection to the pool
+func (c *redisClient) put(conn *redisConn) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.idle = append(c.idle, conn)
+}
+
+// dial opens a connection and authenticates and selects the database when configured
+func (c *redisClient) dial(ctx context.Context) (*redisConn, error) {
+	dialer := net.Dialer{Timeout: redisTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis at %s: %w", c.addr, err)
+	}
+	conn := &redisConn{conn: netConn, reader: bufio.NewReader(netConn)}
+
+	if c.password != "" {
+		if _, err := conn.roundTrip(ctx, []string{"AUTH", c.password}); err != nil {
+			conn.close()
+			return nil, fmt.Errorf("redis authentication failed: %w", err)
+		}
+	}
+	if c.db != 0 {
+		if _, err := conn.roundTrip(ctx, []string{"SELECT", strconv.Itoa(c.db)}); err != nil {
+			conn.close()
+			return nil, fmt.Errorf("failed to select redis database %d: %w", c.db, err)
+		}
+	}
+	return conn, nil
+}
+
+// roundTrip writes one command and reads its reply before the deadline of ctx
+func (c *redisConn) roundTrip(ctx context.Context, args []string) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if _, err := c.conn.Write(encodeCommand(args)); err != nil {
+		return nil, fmt.Errorf("failed to send redis command: %w", err)
+	}
+	return readReply(c.reader)
+}
+
+// close closes the connection
+func (c *redisConn) close() error {
+	return c.conn.Close()
+}
+
+// encodeCommand encodes a command as an array of bulk strings
+func encodeCommand(args []string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return []byte(b.String())
+}
+
+// readReply reads one RESP value
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redis reply: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("invalid redis reply: empty line")
+	}
+
+	prefix, payload := line[0], line[1:]
+	switch prefix {
+	case '+':
+		return payload, nil
+
+	case '-':
+		return nil, redisError(payload)
+
+	case ':':
+		value, err := strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis integer %q", payload)
+		}
+		return value, nil
+
+	case '$':
+		length, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis bulk length %q", payload)
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		data := make([]byte, length+2) // Followed by \r\n
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("failed to read redis reply: %w", err)
+		}
+		return string(data[:length]), nil
+
+	case '*':
+		count, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis array length %q", payload)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]any, count)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+
+	default:
+		return nil, fmt.Errorf("invalid redis reply type %q", prefix)
+	}
+}
@@ -1,14 +1,51 @@
 package session
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"time"
+
+	"proyecto1/root/internal/config"
+	"proyecto1/root/internal/database"
 )
 
-// InMemorySessionStore manages revoked JWT tokens in memory
+// SessionStore keeps the access tokens revoked by logout until they expire. Tokens are
+// identified by their jti claim, so the store never holds usable tokens
+type SessionStore interface {
+	// RevokeToken marks the token with the given jti as revoked until expiresAt (its exp claim)
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+
+	// IsTokenRevoked reports whether the token with the given jti was revoked
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+
+	// CleanExpiredTokens removes the revocations of tokens that have expired
+	CleanExpiredTokens(ctx context.Context) error
+
+	// Close releases the connections of the store
+	Close() error
+}
+
+// NewSessionStore creates the session store selected by SESSION_STORE
+func NewSessionStore(cfg *config.Config, db *database.DB) (SessionStore, error) {
+	switch cfg.Session.Provider {
+	case "postgres":
+		return NewPostgresSessionStore(db), nil
+
+	case "redis":
+		return NewRedisSessionStore(cfg.Session.RedisAddr, cfg.Session.RedisPassword, cfg.Session.RedisDB, cfg.Session.RedisPoolSize), nil
+
+	case "memory":
+		return NewInMemorySessionStore(), nil
+
+	default:
+		return nil, fmt.Errorf("unknown session store %q (expected postgres, redis or memory)", cfg.Session.Provider)
+	}
+}
+
+// InMemorySessionStore manages revoked JWT tokens in memory. Revocations are only seen by
+// this process and lost on restart, so it is meant for tests and single-instance development
 type InMemorySessionStore struct {
-	// For simplicity, we store token string in a set to invalidate on logout.
-	// In production, use a blacklist with TTL in Redis, or rotate tokens with server-side sessions.
 	revoked map[string]time.Time
 	mutex   sync.RWMutex
 }
@@ -21,40 +58,38 @@ func NewInMemorySessionStore() *InMemorySessionStore {
 }
 
 // RevokeToken adds a token to the revoked list
-func (s *InMemorySessionStore) RevokeToken(token string, expiration time.Time) {
+func (s *InMemorySessionStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	s.revoked[token] = expiration
+	s.revoked[jti] = expiresAt
+	return nil
 }
 
 // IsTokenRevoked checks if a token is in the revoked list
-func (s *InMemorySessionStore) IsTokenRevoked(token string) bool {
+func (s *InMemorySessionStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	expiration, exists := s.revoked[token]
-	if !exists {
-		return false
-	}
-
-	// Clean up expired tokens
-	if time.Now().After(expiration) {
-		delete(s.revoked, token)
-		return false
-	}
-
-	return true
+	// Expired entries are left for CleanExpiredTokens
+	expiresAt, exists := s.revoked[jti]
+	return exists && time.Now().Before(expiresAt), nil
 }
 
 // CleanExpiredTokens removes expired tokens from the revoked list
-func (s *InMemorySessionStore) CleanExpiredTokens() {
+func (s *InMemorySessionStore) CleanExpiredTokens(ctx context.Context) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	now := time.Now()
-	for token, expiration := range s.revoked {
-		if now.After(expiration) {
-			delete(s.revoked, token)
+	for jti, expiresAt := range s.revoked {
+		if now.After(expiresAt) {
+			delete(s.revoked, jti)
 		}
 	}
+	return nil
+}
+
+// Close implements SessionStore, there is nothing to release
+func (s *InMemorySessionStore) Close() error {
+	return nil
 }
@@ -0,0 +1,67 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// unsafeFileChars are replaced in the recipient part of the file names
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9@._-]+`)
+
+// FileMailer writes every email as an .eml file instead of sending it, for local development
+// and tests. The files open in any mail client
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer creates a mailer writing to dir, which is created if needed
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory %s: %w", dir, err)
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+// Send writes the message to <dir>/<timestamp>_<recipient>.eml
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data, err := formatMessage(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s_%s.eml", now.UTC().Format("20060102T150405.000000000"), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	path := filepath.Join(m.dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+
+	log.Printf("Email to %s written to %s", msg.To, path)
+	return nil
+}
+
+// LogMailer prints every email to the log instead of sending it. The body is logged as is,
+// links included, so it must not be used in production
+type LogMailer struct {
+	from string
+}
+
+// NewLogMailer creates a mailer that logs the messages
+func NewLogMailer(from string) *LogMailer {
+	return &LogMailer{from: from}
+}
+
+// Send logs the message
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	if _, err := formatMessage(m.from, msg, time.Now()); err != nil {
+		return err
+	}
+	log.Printf("Email from %s to %s\nSubject: %s\n\n%s", m.from, msg.To, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,98 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+
+	"proyecto1/root/internal/config"
+)
+
+// Mailer sends plain text emails
+type Mailer interface {
+	// Send delivers the message or returns an error, it does not retry
+	Send(ctx context.Context, msg Message) error
+}
+
+// Message is a plain text email to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// NewMailer creates the mailer selected by MAIL_PROVIDER
+func NewMailer(cfg *config.Config) (Mailer, error) {
+	if _, err := mail.ParseAddress(cfg.Mail.From); err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM %q: %w", cfg.Mail.From, err)
+	}
+
+	switch cfg.Mail.Provider {
+	case "smtp":
+		return NewSMTPMailer(cfg.Mail.SMTPHost, cfg.Mail.SMTPPort, cfg.Mail.SMTPUser, cfg.Mail.SMTPPassword, cfg.Mail.From), nil
+
+	case "file":
+		return NewFileMailer(cfg.Mail.FileDir, cfg.Mail.From)
+
+	case "log":
+		return NewLogMailer(cfg.Mail.From), nil
+
+	default:
+		return nil, fmt.Errorf("unknown mail provider %q (expected smtp, file or log)", cfg.Mail.Provider)
+	}
+}
+
+// formatMessage encodes the message as an RFC 5322 email: UTF-8 quoted-printable body and an
+// encoded subject, so accented text survives any relay
+func formatMessage(from string, msg Message, date time.Time) ([]byte, error) {
+	if _, err := mail.ParseAddress(msg.To); err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", msg.To, err)
+	}
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, errors.New("invalid subject: contains a line break")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: %s\r\n", messageID(from))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	buf.WriteString("\r\n")
+
+	writer := quotedprintable.NewWriter(&buf)
+	body := strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := writer.Write([]byte(body)); err != nil {
+		return nil, fmt.Errorf("failed to encode message body: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode message body: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// messageID returns a unique Message-ID on the domain of the sender
+func messageID(from string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndex(addr.Address, "@"); at >= 0 {
+			domain = addr.Address[at+1:]
+		}
+	}
+
+	random := make([]byte, 12)
+	_, _ = rand.Read(random)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(random), domain)
+}
@@ -0,0 +1,132 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testFrom = "Proyecto_1 <no-reply@example.com>"
+
+var fixedTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// parseMessage decodes a formatted message back into its subject and body
+func parseMessage(t *testing.T, data []byte) (*mail.Message, string, string) {
+	parsed, err := mail.ReadMessage(strings.NewReader(string(data)))
+	require.NoError(t, err)
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	require.NoError(t, err)
+	return parsed, subject, string(body)
+}
+
+func TestFileMailerWritesMessage(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewFileMailer(dir, testFrom)
+	require.NoError(t, err)
+
+	err = m.Send(context.Background(), Message{
+		To:      "ana@example.com",
+		Subject: "Verificación de correo",
+		Body:    "Hola Ana,\nabre este enlace: http://localhost/verify-email?token=abc=def\n",
+	})
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*_ana@example.com.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+
+	parsed, subject, body := parseMessage(t, data)
+	assert.Equal(t, "ana@example.com", parsed.Header.Get("To"))
+	assert.Equal(t, testFrom, parsed.Header.Get("From"))
+	assert.Equal(t, "Verificación de correo", subject)
+	assert.Equal(t, "Hola Ana,\r\nabre este enlace: http://localhost/verify-email?token=abc=def\r\n", body)
+}
+
+func TestFormatMessageRejectsHeaderInjection(t *testing.T) {
+	_, err := formatMessage(testFrom, Message{To: "ana@example.com\r\nBcc: eve@example.com", Subject: "Hola"}, fixedTime)
+	assert.Error(t, err)
+
+	_, err = formatMessage(testFrom, Message{To: "ana@example.com", Subject: "Hola\r\nBcc: eve@example.com"}, fixedTime)
+	assert.Error(t, err)
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		received <- serveFakeSMTP(conn)
+	}()
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	m := NewSMTPMailer(host, port, "", "", testFrom)
+	require.NoError(t, m.Send(context.Background(), Message{To: "Ana <ana@example.com>", Subject: "Hola", Body: "Cuerpo"}))
+
+	lines := <-received
+	assert.Contains(t, lines, "MAIL FROM:<no-reply@example.com>")
+	assert.Contains(t, lines, "RCPT TO:<ana@example.com>")
+	assert.Contains(t, lines, "Subject: Hola")
+	assert.Contains(t, lines, "Cuerpo")
+	assert.Equal(t, "QUIT", lines[len(lines)-1])
+}
+
+// serveFakeSMTP accepts one message without STARTTLS or authentication and returns every line
+// sent by the client
+func serveFakeSMTP(conn net.Conn) []string {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	var lines []string
+	inData := false
+
+	fmt.Fprint(conn, "220 fake ESMTP\r\n")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return lines
+		}
+		line = strings.TrimSuffix(line, "\r\n")
+		lines = append(lines, line)
+
+		switch {
+		case inData && line == ".":
+			inData = false
+			fmt.Fprint(conn, "250 queued\r\n")
+		case inData:
+		case strings.HasPrefix(line, "EHLO"):
+			fmt.Fprint(conn, "250 fake\r\n")
+		case line == "DATA":
+			inData = true
+			fmt.Fprint(conn, "354 go ahead\r\n")
+		case line == "QUIT":
+			fmt.Fprint(conn, "221 bye\r\n")
+			return lines
+		default:
+			fmt.Fprint(conn, "250 ok\r\n")
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// smtpTimeout bounds a delivery when the context has no deadline
+const smtpTimeout = 30 * time.Second
+
+// SMTPMailer delivers emails through an SMTP server, upgrading the connection with STARTTLS
+// when the server offers it. A new connection is opened for every message
+type SMTPMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPMailer creates a mailer for the server at host:port. An empty username skips
+// authentication, which net/smtp only allows over TLS or to localhost
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{host: host, port: port, username: username, password: password, from: from}
+}
+
+// Send delivers the message in a single SMTP transaction
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	data, err := formatMessage(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	sender, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return fmt.Errorf("invalid sender %q: %w", m.from, err)
+	}
+	recipient, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient %q: %w", msg.To, err)
+	}
+
+	addr := net.JoinHostPort(m.host, m.port)
+	dialer := net.Dialer{Timeout: smtpTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server %s: %w", addr, err)
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to set smtp deadline: %w", err)
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start smtp session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return fmt.Errorf("failed to start tls: %w", err)
+		}
+	}
+	if m.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return fmt.Errorf("failed to authenticate to smtp server: %w", err)
+		}
+	}
+
+	if err := client.Mail(sender.Address); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := client.Rcpt(recipient.Address); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return client.Quit()
+}
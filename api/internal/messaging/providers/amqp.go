@@ -0,0 +1,116 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"proyecto1/root/internal/messaging"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// AMQPQueue implements the MessageQueue interface using an AMQP 0.9.1 broker (RabbitMQ). A lost
+// connection or channel is opened again on the next send; the outbox relay retries failed sends
+// with backoff, which paces the reconnection attempts
+type AMQPQueue struct {
+	url       string
+	queueName string
+
+	mu      sync.Mutex // Serialises sends and reconnections
+	conn    *amqp.Connection
+	channel *amqp.Channel
+}
+
+// NewAMQPQueue connects to the broker and declares the durable queue
+func NewAMQPQueue(url string, queueName string) (*AMQPQueue, error) {
+	q := &AMQPQueue{
+		url:       url,
+		queueName: queueName,
+	}
+	if err := q.connect(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// connect dials the broker, opens a channel with publisher confirms and declares the queue
+func (q *AMQPQueue) connect() error {
+	conn, err := amqp.Dial(q.url)
+	if err != nil {
+		return fmt.Errorf("failed to connect to AMQP broker: %w", err)
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to open AMQP channel: %w", err)
+	}
+
+	// Publisher confirms make SendMessage fail if the broker did not store the message
+	if err := channel.Confirm(false); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	if _, err := channel.QueueDeclare(q.queueName, true, false, false, false, nil); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to declare AMQP queue: %w", err)
+	}
+
+	q.conn = conn
+	q.channel = channel
+	return nil
+}
+
+// SendMessage publishes a persistent message and waits for the broker confirmation
+func (q *AMQPQueue) SendMessage(ctx context.Context, message messaging.Message) error {
+	messageBody, err := encodeMessageBody(message)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// The broker closed the connection or the channel (restart, network failure, channel error)
+	if q.channel.IsClosed() {
+		log.Printf("AMQP connection lost, reconnecting")
+		q.conn.Close()
+		if err := q.connect(); err != nil {
+			return err
+		}
+	}
+
+	confirmation, err := q.channel.PublishWithDeferredConfirmWithContext(ctx, "", q.queueName, true, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Body:         []byte(messageBody),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish message to AMQP: %w", err)
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to confirm AMQP message: %w", err)
+	}
+	if !acked {
+		return fmt.Errorf("AMQP broker rejected the message")
+	}
+
+	return nil
+}
+
+// Close closes the channel and the connection to the broker
+func (q *AMQPQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.channel.Close(); err != nil && err != amqp.ErrClosed {
+		q.conn.Close()
+		return fmt.Errorf("failed to close AMQP channel: %w", err)
+	}
+	return q.conn.Close()
+}
@@ -0,0 +1,37 @@
+package providers
+
+import (
+	"fmt"
+
+	"proyecto1/root/internal/config"
+	"proyecto1/root/internal/database"
+	"proyecto1/root/internal/messaging"
+)
+
+// NewMessageQueue creates the message queue for the provider selected in configuration
+func NewMessageQueue(cfg *config.Config, db *database.DB) (messaging.MessageQueue, error) {
+	switch cfg.Messaging.Provider {
+	case "sqs":
+		messageQueue, err := NewSQSQueue(&cfg.AWS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SQS message queue: %w", err)
+		}
+		return messageQueue, nil
+
+	case "memory":
+		return NewMemoryQueue(1000), nil
+
+	case "postgres":
+		return NewPostgresQueue(db, cfg.Messaging.QueueName), nil
+
+	case "amqp":
+		messageQueue, err := NewAMQPQueue(cfg.Messaging.AMQPURL, cfg.Messaging.QueueName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AMQP message queue: %w", err)
+		}
+		return messageQueue, nil
+
+	default:
+		return nil, fmt.Errorf("unknown messaging provider %q (expected sqs, memory, postgres or amqp)", cfg.Messaging.Provider)
+	}
+}
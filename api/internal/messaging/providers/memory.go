@@ -0,0 +1,49 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+
+	"proyecto1/root/internal/messaging"
+)
+
+// MemoryQueue implements the MessageQueue interface with an in-process buffered channel.
+// It is meant for tests and local development; messages are lost when the process exits
+type MemoryQueue struct {
+	messages chan string
+}
+
+// NewMemoryQueue creates a new in-memory queue holding up to capacity pending messages
+func NewMemoryQueue(capacity int) *MemoryQueue {
+	return &MemoryQueue{
+		messages: make(chan string, capacity),
+	}
+}
+
+// SendMessage enqueues the message body, failing if the queue is full
+func (q *MemoryQueue) SendMessage(ctx context.Context, message messaging.Message) error {
+	messageBody, err := encodeMessageBody(message)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case q.messages <- messageBody:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to send message to memory queue: %w", ctx.Err())
+	default:
+		return fmt.Errorf("failed to send message to memory queue: queue is full")
+	}
+}
+
+// Messages returns the channel consumers read message bodies from
+func (q *MemoryQueue) Messages() <-chan string {
+	return q.messages
+}
+
+// Close closes the connection to the message queue
+func (q *MemoryQueue) Close() error {
+	// Nothing to release, pending messages are simply dropped
+	return nil
+}
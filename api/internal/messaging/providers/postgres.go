@@ -0,0 +1,48 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+
+	"proyecto1/root/internal/database"
+	"proyecto1/root/internal/messaging"
+)
+
+// PostgresQueue implements the MessageQueue interface on the message_queue table, so small
+// deployments only need Postgres. The worker claims rows with SELECT ... FOR UPDATE SKIP LOCKED
+type PostgresQueue struct {
+	db        *database.DB
+	queueName string
+}
+
+// NewPostgresQueue creates a new Postgres-backed message queue
+func NewPostgresQueue(db *database.DB, queueName string) *PostgresQueue {
+	return &PostgresQueue{
+		db:        db,
+		queueName: queueName,
+	}
+}
+
+// SendMessage inserts the message, immediately visible to consumers
+func (q *PostgresQueue) SendMessage(ctx context.Context, message messaging.Message) error {
+	messageBody, err := encodeMessageBody(message)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO message_queue (queue_name, body)
+		VALUES ($1, $2)`
+
+	if _, err := q.db.ExecContext(ctx, query, q.queueName, messageBody); err != nil {
+		return fmt.Errorf("failed to send message to Postgres queue: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the connection to the message queue
+func (q *PostgresQueue) Close() error {
+	// The database connection is owned by the caller
+	return nil
+}
@@ -75,20 +75,9 @@ func NewSQSQueue(cfg *config.AWSConfig) (*SQSQueue, error) {
 
 // SendMessage sends a message to the SQS queue
 func (s *SQSQueue) SendMessage(ctx context.Context, message messaging.Message) error {
-	var messageBody string
-	var err error
-
-	// Handle different message types
-	switch msg := message.(type) {
-	case *messaging.VideoProcessingMessage:
-		messageBodyBytes, err := json.Marshal(msg)
-		if err != nil {
-			return fmt.Errorf("failed to marshal video processing message: %w", err)
-		}
-		messageBody = string(messageBodyBytes)
-	default:
-		// For generic messages, use the body directly
-		messageBody = message.GetBody()
+	messageBody, err := encodeMessageBody(message)
+	if err != nil {
+		return err
 	}
 
 	_, err = s.client.SendMessage(ctx, &sqs.SendMessageInput{
@@ -102,6 +91,22 @@ func (s *SQSQueue) SendMessage(ctx context.Context, message messaging.Message) e
 	return nil
 }
 
+// encodeMessageBody serializes a message the same way for every provider
+func encodeMessageBody(message messaging.Message) (string, error) {
+	// Handle different message types
+	switch msg := message.(type) {
+	case *messaging.VideoProcessingMessage:
+		messageBodyBytes, err := json.Marshal(msg)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal video processing message: %w", err)
+		}
+		return string(messageBodyBytes), nil
+	default:
+		// For generic messages, use the body directly
+		return message.GetBody(), nil
+	}
+}
+
 // Close closes the connection to the message queue
 func (s *SQSQueue) Close() error {
 	// SQS doesn't require explicit connection closing
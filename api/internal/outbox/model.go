@@ -0,0 +1,55 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"proyecto1/root/internal/messaging"
+)
+
+// Message types stored in the outbox
+const (
+	MessageTypeVideoProcessing = "video_processing"
+)
+
+// Entry represents a message waiting in the outbox to be published
+type Entry struct {
+	ID            int64      `json:"id" db:"id"`
+	VideoID       int        `json:"video_id" db:"video_id"`
+	MessageType   string     `json:"message_type" db:"message_type"`
+	Payload       string     `json:"payload" db:"payload"`
+	Attempts      int        `json:"attempts" db:"attempts"`
+	LastError     *string    `json:"last_error" db:"last_error"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" db:"next_attempt_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	SentAt        *time.Time `json:"sent_at" db:"sent_at"`
+}
+
+// Message rebuilds the queue message stored in the entry
+func (e *Entry) Message() (messaging.Message, error) {
+	switch e.MessageType {
+	case MessageTypeVideoProcessing:
+		var msg messaging.VideoProcessingMessage
+		if err := json.Unmarshal([]byte(e.Payload), &msg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal outbox payload: %w", err)
+		}
+		return &msg, nil
+	default:
+		return nil, fmt.Errorf("unsupported outbox message type: %s", e.MessageType)
+	}
+}
+
+// encodeMessage returns the message type and payload to store for a queue message
+func encodeMessage(message messaging.Message) (string, string, error) {
+	switch msg := message.(type) {
+	case *messaging.VideoProcessingMessage:
+		payload, err := json.Marshal(msg)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to marshal video processing message: %w", err)
+		}
+		return MessageTypeVideoProcessing, string(payload), nil
+	default:
+		return "", "", fmt.Errorf("unsupported outbox message: %T", message)
+	}
+}
@@ -0,0 +1,176 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"proyecto1/root/internal/database"
+	"proyecto1/root/internal/messaging"
+)
+
+// relayLockKey is the Postgres advisory lock key held by the relay leader
+const relayLockKey int64 = 0x6f7574626f78 // "outbox"
+
+// RelayConfig holds the relay polling and retry settings
+type RelayConfig struct {
+	PollInterval time.Duration // How often pending entries are checked
+	BatchSize    int           // Maximum entries published per poll
+	BaseDelay    time.Duration // Delay before the first retry, doubled on each attempt
+	MaxDelay     time.Duration // Maximum delay between retries
+}
+
+// Relay publishes pending outbox entries to the message queue. Only the instance holding the
+// Postgres advisory lock publishes, so API instances do not race on the same entries. Delivery is
+// still at least once: an entry published just before the relay fails to mark it sent (or loses
+// leadership) is published again, and the worker ignores messages for videos already processed
+type Relay struct {
+	db     *database.DB
+	repo   *Repository
+	queue  messaging.MessageQueue
+	config RelayConfig
+
+	leaderConn *sql.Conn // Session holding the advisory lock while this instance is leader
+}
+
+// NewRelay creates a new outbox relay
+func NewRelay(db *database.DB, queue messaging.MessageQueue, config RelayConfig) *Relay {
+	return &Relay{
+		db:     db,
+		repo:   NewRepository(db),
+		queue:  queue,
+		config: config,
+	}
+}
+
+// Run polls the outbox until the context is cancelled
+func (r *Relay) Run(ctx context.Context) {
+	log.Println("Outbox relay started")
+	defer r.stepDown()
+
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if r.isLeader(ctx) {
+			if err := r.publishPending(ctx); err != nil {
+				log.Printf("Error publishing outbox entries: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Outbox relay stopping due to context cancellation")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// isLeader checks the current leadership or tries to acquire it
+func (r *Relay) isLeader(ctx context.Context) bool {
+	if r.leaderConn != nil {
+		// The lock lives as long as the session, so a healthy connection means we are still leader
+		if err := r.leaderConn.PingContext(ctx); err == nil {
+			return true
+		}
+		log.Println("Outbox relay lost its leader connection, stepping down")
+		r.stepDown()
+	}
+
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		log.Printf("Error getting outbox relay connection: %v", err)
+		return false
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", relayLockKey).Scan(&acquired); err != nil {
+		log.Printf("Error acquiring outbox relay lock: %v", err)
+		conn.Close()
+		return false
+	}
+	if !acquired {
+		conn.Close() // Another instance is the leader
+		return false
+	}
+
+	log.Println("Outbox relay acquired leadership")
+	r.leaderConn = conn
+	return true
+}
+
+// stepDown releases the advisory lock and returns the connection to the pool
+func (r *Relay) stepDown() {
+	if r.leaderConn == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// If the unlock fails the session is most likely gone, which releases the lock as well
+	if _, err := r.leaderConn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", relayLockKey); err != nil {
+		log.Printf("Warning: failed to release outbox relay lock: %v", err)
+	}
+	r.leaderConn.Close()
+	r.leaderConn = nil
+}
+
+// publishPending sends the due entries and records the outcome of each one
+func (r *Relay) publishPending(ctx context.Context) error {
+	entries, err := r.repo.ListPending(ctx, r.config.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := r.publish(ctx, entry); err != nil {
+			nextAttemptAt := time.Now().Add(r.backoff(entry.Attempts + 1))
+			log.Printf("Outbox entry %d (video %d) failed on attempt %d, retrying at %s: %v",
+				entry.ID, entry.VideoID, entry.Attempts+1, nextAttemptAt.Format(time.RFC3339), err)
+
+			if markErr := r.repo.MarkFailed(ctx, entry.ID, err.Error(), nextAttemptAt); markErr != nil {
+				return markErr
+			}
+			continue
+		}
+
+		if err := r.repo.MarkSent(ctx, entry.ID); err != nil {
+			// The message was sent; the worker skips videos that are already processed
+			return err
+		}
+	}
+
+	return nil
+}
+
+// publish sends one entry to the message queue
+func (r *Relay) publish(ctx context.Context, entry *Entry) error {
+	message, err := entry.Message()
+	if err != nil {
+		return err
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := r.queue.SendMessage(sendCtx, message); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return nil
+}
+
+// backoff returns the exponential delay before the given attempt, capped at MaxDelay
+func (r *Relay) backoff(attempt int) time.Duration {
+	delay := r.config.BaseDelay
+	for i := 1; i < attempt && delay < r.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > r.config.MaxDelay {
+		delay = r.config.MaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,36 @@
+package outbox
+
+import (
+	"testing"
+	"time"
+
+	"proyecto1/root/internal/messaging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayBackoff(t *testing.T) {
+	relay := &Relay{config: RelayConfig{BaseDelay: 5 * time.Second, MaxDelay: time.Minute}}
+
+	assert.Equal(t, 5*time.Second, relay.backoff(1))
+	assert.Equal(t, 10*time.Second, relay.backoff(2))
+	assert.Equal(t, 20*time.Second, relay.backoff(3))
+	assert.Equal(t, 40*time.Second, relay.backoff(4))
+	assert.Equal(t, time.Minute, relay.backoff(5))
+	assert.Equal(t, time.Minute, relay.backoff(50))
+}
+
+func TestEntryMessageRoundTrip(t *testing.T) {
+	messageType, payload, err := encodeMessage(&messaging.VideoProcessingMessage{S3Key: "original/7.mp4"})
+	require.NoError(t, err)
+	assert.Equal(t, MessageTypeVideoProcessing, messageType)
+
+	entry := &Entry{MessageType: messageType, Payload: payload}
+	message, err := entry.Message()
+	require.NoError(t, err)
+	assert.Equal(t, &messaging.VideoProcessingMessage{S3Key: "original/7.mp4"}, message)
+
+	_, err = (&Entry{MessageType: "unknown", Payload: "{}"}).Message()
+	assert.Error(t, err)
+}
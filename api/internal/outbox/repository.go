@@ -0,0 +1,122 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"proyecto1/root/internal/database"
+	"proyecto1/root/internal/messaging"
+)
+
+type Repository struct {
+	db *database.DB
+}
+
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Enqueue stores a message in the outbox inside the caller's transaction, so it is only
+// published if the rest of the transaction (e.g. the videos insert) commits
+func (r *Repository) Enqueue(tx *sql.Tx, videoID int, message messaging.Message) error {
+	messageType, payload, err := encodeMessage(message)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO outbox (video_id, message_type, payload)
+		VALUES ($1, $2, $3)`
+
+	if _, err := tx.Exec(query, videoID, messageType, payload); err != nil {
+		return fmt.Errorf("failed to insert outbox entry: %w", err)
+	}
+
+	return nil
+}
+
+// ListPending returns unsent entries whose next attempt is due, oldest first
+func (r *Repository) ListPending(ctx context.Context, limit int) ([]*Entry, error) {
+	query := `
+		SELECT id, video_id, message_type, payload, attempts, last_error, next_attempt_at, created_at, sent_at
+		FROM outbox
+		WHERE sent_at IS NULL AND next_attempt_at <= NOW()
+		ORDER BY id
+		LIMIT $1`
+
+	return r.list(ctx, query, limit)
+}
+
+// ListStuck returns unsent entries created more than olderThan ago
+func (r *Repository) ListStuck(ctx context.Context, olderThan time.Duration, limit int) ([]*Entry, error) {
+	query := `
+		SELECT id, video_id, message_type, payload, attempts, last_error, next_attempt_at, created_at, sent_at
+		FROM outbox
+		WHERE sent_at IS NULL AND created_at < NOW() - make_interval(secs => $1)
+		ORDER BY created_at
+		LIMIT $2`
+
+	return r.list(ctx, query, olderThan.Seconds(), limit)
+}
+
+// MarkSent records that the entry was published and moves its video from uploaded to queued.
+// Videos a worker already picked up are left untouched
+func (r *Repository) MarkSent(ctx context.Context, id int64) error {
+	query := `
+		WITH sent AS (
+			UPDATE outbox
+			SET sent_at = NOW(), attempts = attempts + 1, last_error = NULL
+			WHERE id = $1
+			RETURNING video_id
+		)
+		UPDATE videos
+		SET status = 'queued'
+		WHERE id IN (SELECT video_id FROM sent) AND status = 'uploaded'`
+
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to mark outbox entry %d as sent: %w", id, err)
+	}
+	return nil
+}
+
+// MarkFailed records a failed publish attempt and when to retry
+func (r *Repository) MarkFailed(ctx context.Context, id int64, lastError string, nextAttemptAt time.Time) error {
+	query := `
+		UPDATE outbox
+		SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+		WHERE id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, id, lastError, nextAttemptAt); err != nil {
+		return fmt.Errorf("failed to mark outbox entry %d as failed: %w", id, err)
+	}
+	return nil
+}
+
+func (r *Repository) list(ctx context.Context, query string, args ...any) ([]*Entry, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*Entry
+	for rows.Next() {
+		var entry Entry
+		err := rows.Scan(
+			&entry.ID, &entry.VideoID, &entry.MessageType, &entry.Payload, &entry.Attempts,
+			&entry.LastError, &entry.NextAttemptAt, &entry.CreatedAt, &entry.SentAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox row: %w", err)
+		}
+		entries = append(entries, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox rows: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,33 @@
+package uploads
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunJanitor removes the uploads idle for longer than expiration every interval until the
+// context is cancelled. Every API instance runs one on its own uploads directory
+func RunJanitor(ctx context.Context, store *FileStore, expiration time.Duration, interval time.Duration) {
+	log.Printf("Upload janitor started (every %v, uploads expire after %v)", interval, expiration)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Upload janitor stopping due to context cancellation")
+			return
+		case <-ticker.C:
+		}
+
+		removed, err := store.DeleteIdle(time.Now().Add(-expiration))
+		if err != nil {
+			log.Printf("Error removing expired uploads: %v", err)
+		}
+		if removed > 0 {
+			log.Printf("Removed %d expired uploads", removed)
+		}
+	}
+}
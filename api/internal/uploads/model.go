@@ -0,0 +1,31 @@
+package uploads
+
+import (
+	"time"
+)
+
+// Upload represents the state of a resumable (tus) video upload
+type Upload struct {
+	ID          string    `json:"id"`
+	UserID      int       `json:"user_id"`
+	Length      int64     `json:"length"`                 // Total size declared with Upload-Length
+	Offset      int64     `json:"offset"`                 // Bytes received so far
+	Title       string    `json:"title"`                  // From Upload-Metadata
+	IsPublic    bool      `json:"is_public"`              // From Upload-Metadata
+	Filename    string    `json:"filename"`               // From Upload-Metadata
+	Profile     string    `json:"profile"`                // Upload profile, from the optional profile metadata
+	StartOffset *float64  `json:"start_offset,omitempty"` // From the optional start_offset metadata
+	VideoID     int       `json:"video_id"`               // Set once the upload has been turned into a video
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// IsComplete reports whether all declared bytes have been received
+func (u *Upload) IsComplete() bool {
+	return u.Offset == u.Length
+}
+
+// IsFinalized reports whether the completed upload has already been turned into a video
+func (u *Upload) IsFinalized() bool {
+	return u.VideoID > 0
+}
@@ -0,0 +1,239 @@
+package uploads
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"proyecto1/root/internal/http/dto"
+	"proyecto1/root/internal/videos"
+)
+
+var (
+	// ErrInvalidUpload is returned when the upload creation request is malformed
+	ErrInvalidUpload = errors.New("invalid upload")
+	// ErrUploadTooLarge is returned when the declared length exceeds the maximum video size
+	ErrUploadTooLarge = errors.New("upload exceeds maximum size")
+	// ErrVideoRejected is returned when a completed upload fails video validation
+	ErrVideoRejected = errors.New("video rejected")
+)
+
+// Service implements resumable uploads on top of the regular video upload flow
+type Service struct {
+	store        *FileStore
+	videoService *videos.Service
+	expiration   time.Duration // Uploads without a chunk for this long are gone
+	locks        sync.Map      // upload ID -> *sync.Mutex, serialises chunks for the same upload
+}
+
+// NewService creates a resumable upload service that hands completed uploads to videoService.
+// Uploads expire after expiration without a new chunk (see RunJanitor)
+func NewService(store *FileStore, videoService *videos.Service, expiration time.Duration) *Service {
+	return &Service{
+		store:        store,
+		videoService: videoService,
+		expiration:   expiration,
+	}
+}
+
+// ExpiresAt returns when the upload expires unless another chunk arrives
+func (s *Service) ExpiresAt(upload *Upload) time.Time {
+	return upload.UpdatedAt.Add(s.expiration)
+}
+
+// MaxSize returns the maximum upload length accepted by the service (the largest of all profiles)
+func (s *Service) MaxSize() int64 {
+	return s.videoService.MaxUploadSize()
+}
+
+// CreateUpload registers a new upload for the user using the tus Upload-Metadata values
+func (s *Service) CreateUpload(userID int, length int64, metadata map[string]string) (*Upload, error) {
+	if length <= 0 {
+		return nil, fmt.Errorf("%w: upload length must be greater than zero", ErrInvalidUpload)
+	}
+
+	// The optional profile metadata selects the upload limits, the default profile otherwise
+	profile, rules, err := s.videoService.ResolveProfile(strings.TrimSpace(metadata["profile"]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUpload, err)
+	}
+	if length > rules.MaxSizeBytes {
+		return nil, fmt.Errorf("%w: %d bytes (max: %d bytes)", ErrUploadTooLarge, length, rules.MaxSizeBytes)
+	}
+
+	// Same required fields as the multipart upload endpoint
+	title := strings.TrimSpace(metadata["title"])
+	if title == "" {
+		return nil, fmt.Errorf("%w: title metadata is required", ErrInvalidUpload)
+	}
+
+	isPublicStr := metadata["is_public"]
+	if isPublicStr == "" {
+		return nil, fmt.Errorf("%w: is_public metadata is required", ErrInvalidUpload)
+	}
+	isPublic, err := strconv.ParseBool(strings.ToLower(strings.TrimSpace(isPublicStr)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: is_public must be a valid boolean value", ErrInvalidUpload)
+	}
+
+	filename := metadata["filename"]
+	if filename == "" {
+		return nil, fmt.Errorf("%w: filename metadata is required", ErrInvalidUpload)
+	}
+
+	startOffset, err := videos.ParseStartOffset(metadata["start_offset"])
+	if err == nil {
+		err = videos.CheckStartOffset(startOffset, rules)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUpload, err)
+	}
+
+	id, err := generateUploadID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	upload := &Upload{
+		ID:          id,
+		UserID:      userID,
+		Length:      length,
+		Title:       title,
+		IsPublic:    isPublic,
+		Filename:    filename,
+		Profile:     profile,
+		StartOffset: startOffset,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := s.store.Create(upload); err != nil {
+		return nil, fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	return upload, nil
+}
+
+// GetUpload returns the upload if it exists, belongs to the user and has not expired. Expired
+// uploads are not found even before the janitor removes them
+func (s *Service) GetUpload(id string, userID int) (*Upload, error) {
+	upload, err := s.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if upload.UserID != userID || !time.Now().Before(s.ExpiresAt(upload)) {
+		return nil, ErrUploadNotFound
+	}
+	return upload, nil
+}
+
+// WriteChunk appends a chunk at the given offset. When the last byte arrives the upload is
+// validated and turned into a video, in which case the upload response is returned as well
+func (s *Service) WriteChunk(id string, userID int, offset int64, chunk io.Reader) (*Upload, *dto.VideoUploadResponse, error) {
+	unlock := s.lock(id)
+	defer unlock()
+
+	upload, err := s.GetUpload(id, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if upload.IsFinalized() {
+		return upload, nil, ErrOffsetMismatch
+	}
+
+	// An empty PATCH at the final offset retries a finalization that failed earlier
+	if !upload.IsComplete() {
+		if _, err := s.store.Append(upload, offset, chunk); err != nil {
+			return upload, nil, err
+		}
+	} else if offset != upload.Offset {
+		return upload, nil, ErrOffsetMismatch
+	}
+
+	if !upload.IsComplete() {
+		return upload, nil, nil
+	}
+
+	response, err := s.finalize(upload)
+	if err != nil {
+		return upload, nil, err
+	}
+
+	return upload, response, nil
+}
+
+// Terminate discards an upload and all of its received bytes
+func (s *Service) Terminate(id string, userID int) error {
+	unlock := s.lock(id)
+	defer unlock()
+
+	if _, err := s.GetUpload(id, userID); err != nil {
+		return err
+	}
+
+	if err := s.store.Delete(id); err != nil {
+		return fmt.Errorf("failed to delete upload: %w", err)
+	}
+	s.release(id)
+	return nil
+}
+
+// finalize runs the regular validation/persistence/enqueue flow on the assembled file
+func (s *Service) finalize(upload *Upload) (*dto.VideoUploadResponse, error) {
+	response, err := s.videoService.UploadVideoFromFile(
+		s.store.DataPath(upload.ID), upload.Title, upload.IsPublic, upload.UserID, upload.Profile, upload.StartOffset,
+	)
+	if err != nil {
+		// A video that fails validation will never succeed, so the upload is discarded.
+		// Other failures (database, storage) keep the data so the client can retry.
+		if errors.Is(err, videos.ErrValidationFailed) {
+			if deleteErr := s.store.Delete(upload.ID); deleteErr != nil {
+				fmt.Printf("Warning: Failed to delete rejected upload %s: %v\n", upload.ID, deleteErr)
+			}
+			s.release(upload.ID)
+			return nil, fmt.Errorf("%w: %v", ErrVideoRejected, err)
+		}
+		return nil, fmt.Errorf("failed to finalize upload: %w", err)
+	}
+
+	if err := s.store.MarkFinalized(upload, response.ID); err != nil {
+		// The video exists already; only the cleanup of the upload failed
+		fmt.Printf("Warning: Failed to mark upload %s as finalized: %v\n", upload.ID, err)
+	} else {
+		s.release(upload.ID)
+	}
+
+	return response, nil
+}
+
+// lock acquires the per-upload mutex and returns its release function
+func (s *Service) lock(id string) func() {
+	value, _ := s.locks.LoadOrStore(id, &sync.Mutex{})
+	mutex := value.(*sync.Mutex)
+	mutex.Lock()
+	return mutex.Unlock
+}
+
+// release forgets the mutex of an upload that reached a final state (finalized, rejected or
+// terminated), called with the mutex held. Requests still waiting on it and requests creating a
+// new one only read that final state, so they no longer need to be serialised
+func (s *Service) release(id string) {
+	s.locks.Delete(id)
+}
+
+// generateUploadID creates a random hex identifier for a new upload
+func generateUploadID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate upload ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,217 @@
+package uploads
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUploadNotFound is returned when an upload does not exist (or belongs to another user)
+	ErrUploadNotFound = errors.New("upload not found")
+	// ErrOffsetMismatch is returned when a chunk does not start at the current upload offset
+	ErrOffsetMismatch = errors.New("upload offset mismatch")
+)
+
+// FileStore persists partial uploads on local disk so they survive API restarts. The disk is not
+// shared between API instances: behind a load balancer every request of an upload must reach the
+// instance that created it (sticky sessions), and uploads on a replaced instance are lost (the
+// client gets 404 and starts over).
+// Each upload uses two files inside the store directory:
+//   - {id}.bin  holds the bytes received so far (its size is the upload offset)
+//   - {id}.info holds the upload metadata as JSON
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a file store rooted at dir, creating the directory if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create uploads directory %s: %w", dir, err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Create registers a new upload with an empty data file
+func (s *FileStore) Create(upload *Upload) error {
+	dataFile, err := os.OpenFile(s.DataPath(upload.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to create upload data file: %w", err)
+	}
+	if err := dataFile.Close(); err != nil {
+		return fmt.Errorf("failed to close upload data file: %w", err)
+	}
+
+	if err := s.writeInfo(upload); err != nil {
+		os.Remove(s.DataPath(upload.ID))
+		return err
+	}
+
+	return nil
+}
+
+// Get loads an upload. The offset is always taken from the size of the data file,
+// so bytes written before a crash are accounted for
+func (s *FileStore) Get(id string) (*Upload, error) {
+	if !isValidID(id) {
+		return nil, ErrUploadNotFound
+	}
+
+	content, err := os.ReadFile(s.infoPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, fmt.Errorf("failed to read upload info: %w", err)
+	}
+
+	var upload Upload
+	if err := json.Unmarshal(content, &upload); err != nil {
+		return nil, fmt.Errorf("failed to parse upload info: %w", err)
+	}
+
+	// Finalized uploads no longer keep their data file around
+	if upload.IsFinalized() {
+		upload.Offset = upload.Length
+		return &upload, nil
+	}
+
+	stat, err := os.Stat(s.DataPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat upload data file: %w", err)
+	}
+	upload.Offset = stat.Size()
+
+	return &upload, nil
+}
+
+// Append writes the chunk to the end of the upload data, never past the declared length.
+// The caller must serialise calls for the same upload
+func (s *FileStore) Append(upload *Upload, offset int64, chunk io.Reader) (int64, error) {
+	if offset != upload.Offset {
+		return upload.Offset, ErrOffsetMismatch
+	}
+
+	dataFile, err := os.OpenFile(s.DataPath(upload.ID), os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return upload.Offset, fmt.Errorf("failed to open upload data file: %w", err)
+	}
+	defer dataFile.Close()
+
+	// Copy what we can even if the connection drops mid-chunk; the client resumes from the new offset
+	written, copyErr := io.Copy(dataFile, io.LimitReader(chunk, upload.Length-upload.Offset))
+	upload.Offset += written
+	upload.UpdatedAt = time.Now()
+
+	if err := dataFile.Sync(); err != nil {
+		return upload.Offset, fmt.Errorf("failed to flush upload data: %w", err)
+	}
+	if err := s.writeInfo(upload); err != nil {
+		return upload.Offset, err
+	}
+	if copyErr != nil {
+		return upload.Offset, fmt.Errorf("failed to write upload chunk: %w", copyErr)
+	}
+
+	return upload.Offset, nil
+}
+
+// MarkFinalized records the video created from the upload and releases its data file
+func (s *FileStore) MarkFinalized(upload *Upload, videoID int) error {
+	upload.VideoID = videoID
+	upload.UpdatedAt = time.Now()
+	if err := s.writeInfo(upload); err != nil {
+		return err
+	}
+
+	if err := os.Remove(s.DataPath(upload.ID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove upload data file: %w", err)
+	}
+	return nil
+}
+
+// Delete removes all state for an upload
+func (s *FileStore) Delete(id string) error {
+	if !isValidID(id) {
+		return ErrUploadNotFound
+	}
+
+	for _, path := range []string{s.DataPath(id), s.infoPath(id)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove upload file %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// DeleteIdle removes the uploads, finalized or not, whose last chunk arrived before cutoff and
+// returns how many were removed
+func (s *FileStore) DeleteIdle(cutoff time.Time) (int, error) {
+	infoFiles, err := filepath.Glob(filepath.Join(s.dir, "*.info"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list uploads: %w", err)
+	}
+
+	removed := 0
+	for _, infoFile := range infoFiles {
+		upload, err := s.Get(strings.TrimSuffix(filepath.Base(infoFile), ".info"))
+		if err != nil {
+			// Removed meanwhile or unreadable, the next run tries again
+			continue
+		}
+		if !upload.UpdatedAt.Before(cutoff) {
+			continue
+		}
+		if err := s.Delete(upload.ID); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// DataPath returns the path of the file holding the received bytes
+func (s *FileStore) DataPath(id string) string {
+	return filepath.Join(s.dir, id+".bin")
+}
+
+// infoPath returns the path of the JSON metadata file
+func (s *FileStore) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".info")
+}
+
+// writeInfo atomically replaces the metadata file (write to temp file + rename)
+func (s *FileStore) writeInfo(upload *Upload) error {
+	content, err := json.Marshal(upload)
+	if err != nil {
+		return fmt.Errorf("failed to encode upload info: %w", err)
+	}
+
+	tempPath := s.infoPath(upload.ID) + ".tmp"
+	if err := os.WriteFile(tempPath, content, 0o640); err != nil {
+		return fmt.Errorf("failed to write upload info: %w", err)
+	}
+	if err := os.Rename(tempPath, s.infoPath(upload.ID)); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to save upload info: %w", err)
+	}
+	return nil
+}
+
+// isValidID guards against path traversal: upload IDs are lowercase hex strings
+func isValidID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
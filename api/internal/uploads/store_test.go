@@ -0,0 +1,117 @@
+package uploads
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestUpload(id string, length int64) *Upload {
+	return &Upload{
+		ID:        id,
+		UserID:    7,
+		Length:    length,
+		Title:     "Test Video",
+		IsPublic:  true,
+		Filename:  "video.mp4",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+func TestFileStoreAppendAndResume(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+
+	upload := newTestUpload("abc123", 10)
+	require.NoError(t, store.Create(upload))
+
+	// First chunk
+	offset, err := store.Append(upload, 0, bytes.NewReader([]byte("hello")))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), offset)
+
+	// A new store on the same directory (e.g. after an API restart) sees the same offset
+	restarted, err := NewFileStore(dir)
+	require.NoError(t, err)
+	resumed, err := restarted.Get("abc123")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), resumed.Offset)
+	assert.Equal(t, "Test Video", resumed.Title)
+	assert.False(t, resumed.IsComplete())
+
+	// Chunk at the wrong offset is rejected
+	_, err = restarted.Append(resumed, 3, bytes.NewReader([]byte("xx")))
+	assert.ErrorIs(t, err, ErrOffsetMismatch)
+
+	// Bytes past the declared length are never written
+	offset, err = restarted.Append(resumed, 5, bytes.NewReader([]byte("world and more")))
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), offset)
+	assert.True(t, resumed.IsComplete())
+
+	content, err := os.ReadFile(restarted.DataPath("abc123"))
+	require.NoError(t, err)
+	assert.Equal(t, "helloworld", string(content))
+}
+
+func TestFileStoreMarkFinalized(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	upload := newTestUpload("def456", 3)
+	require.NoError(t, store.Create(upload))
+	_, err = store.Append(upload, 0, bytes.NewReader([]byte("abc")))
+	require.NoError(t, err)
+
+	require.NoError(t, store.MarkFinalized(upload, 42))
+
+	finalized, err := store.Get("def456")
+	require.NoError(t, err)
+	assert.True(t, finalized.IsFinalized())
+	assert.Equal(t, 42, finalized.VideoID)
+	assert.Equal(t, int64(3), finalized.Offset)
+
+	_, err = os.Stat(store.DataPath("def456"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFileStoreDeleteIdle(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	abandoned := newTestUpload("aaa111", 10)
+	abandoned.UpdatedAt = time.Now().Add(-48 * time.Hour)
+	require.NoError(t, store.Create(abandoned))
+	active := newTestUpload("bbb222", 10)
+	require.NoError(t, store.Create(active))
+
+	removed, err := store.DeleteIdle(time.Now().Add(-24 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	_, err = store.Get("aaa111")
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+	_, err = os.Stat(store.DataPath("aaa111"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = store.Get("bbb222")
+	assert.NoError(t, err)
+}
+
+func TestFileStoreRejectsInvalidIDs(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	tests := []string{"", "../etc/passwd", "ABC", "abc/def", "notfound0"}
+	for _, id := range tests {
+		t.Run("ID "+id, func(t *testing.T) {
+			_, err := store.Get(id)
+			assert.ErrorIs(t, err, ErrUploadNotFound)
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"proyecto1/root/internal/auth"
+	"proyecto1/root/internal/http/dto"
+	"proyecto1/root/internal/mailer"
+)
+
+// mailTimeout bounds the delivery of an email during a request
+const mailTimeout = 15 * time.Second
+
+// VerifyEmail marks the email of the user the verification token was sent to as verified
+func (s *Service) VerifyEmail(req dto.VerifyEmailRequest) error {
+	userID, err := s.repo.VerifyEmail(auth.HashToken(req.Token))
+	if errors.Is(err, ErrInvalidToken) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return errors.New("failed to verify email")
+	}
+
+	fmt.Printf("Email verified for user %d\n", userID)
+	return nil
+}
+
+// ForgotPassword emails a password reset link to the user with the given email. Unknown
+// emails and delivery errors are not reported, and the token and email are handled in the
+// background so the response takes the same time either way: the endpoint does not reveal which
+// emails have an account
+func (s *Service) ForgotPassword(req dto.ForgotPasswordRequest) error {
+	user, err := s.repo.GetUserByEmail(req.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return errors.New("failed to request password reset")
+	}
+	if user.DisabledAt != nil {
+		return nil
+	}
+
+	go s.sendPasswordReset(user)
+	return nil
+}
+
+// sendPasswordReset creates a reset token for the user and emails the link. Errors are only logged
+func (s *Service) sendPasswordReset(user *User) {
+	token, err := s.createUserToken(user.ID, TokenPurposeResetPassword, s.accountConfig.ResetExpiration)
+	if err != nil {
+		fmt.Printf("Warning: failed to create password reset token for user %d: %v\n", user.ID, err)
+		return
+	}
+
+	body := fmt.Sprintf("Hola %s,\n\n"+
+		"Recibimos una solicitud para restablecer la contraseña de tu cuenta en %s.\n"+
+		"Para elegir una nueva contraseña abre este enlace (válido por %s):\n\n%s\n\n"+
+		"Si no fuiste tú, ignora este correo: tu contraseña no cambiará.\n",
+		user.FirstName, s.appName, formatValidity(s.accountConfig.ResetExpiration), s.link("/reset-password", token))
+
+	if err := s.sendEmail(user, "Restablece tu contraseña", body); err != nil {
+		fmt.Printf("Warning: failed to send password reset email to user %d: %v\n", user.ID, err)
+	}
+}
+
+// ResetPassword sets a new password with a reset token. Every refresh token of the user is
+// revoked, so sessions opened with the old password end when their access token expires
+func (s *Service) ResetPassword(req dto.ResetPasswordRequest) error {
+	if req.Password1 != req.Password2 {
+		return ErrPasswordMismatch
+	}
+
+	hashedPassword, err := auth.HashPassword(req.Password1)
+	if err != nil {
+		return errors.New("failed to process password")
+	}
+
+	userID, err := s.repo.ResetPassword(auth.HashToken(req.Token), hashedPassword)
+	if errors.Is(err, ErrInvalidToken) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return errors.New("failed to reset password")
+	}
+
+	fmt.Printf("Password reset for user %d\n", userID)
+	return nil
+}
+
+// sendVerificationEmail emails a verification link to a new user
+func (s *Service) sendVerificationEmail(user *User) error {
+	token, err := s.createUserToken(user.ID, TokenPurposeVerifyEmail, s.accountConfig.VerificationExpiration)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hola %s,\n\n"+
+		"Gracias por registrarte en %s. Para confirmar tu correo abre este enlace (válido por %s):\n\n%s\n\n"+
+		"Si no creaste esta cuenta, ignora este correo.\n",
+		user.FirstName, s.appName, formatValidity(s.accountConfig.VerificationExpiration), s.link("/verify-email", token))
+
+	return s.sendEmail(user, "Confirma tu correo", body)
+}
+
+// createUserToken stores a new single-use token and returns it; only its hash is kept
+func (s *Service) createUserToken(userID int, purpose string, ttl time.Duration) (string, error) {
+	token, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.repo.CreateUserToken(&UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// sendEmail sends a message to the user
+func (s *Service) sendEmail(user *User, subject string, body string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+	defer cancel()
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: fmt.Sprintf("%s - %s", s.appName, subject),
+		Body:    body,
+	})
+}
+
+// link returns the frontend URL of the given path carrying the token
+func (s *Service) link(path string, token string) string {
+	return strings.TrimRight(s.accountConfig.LinkBaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// formatValidity describes a token lifetime in the emails ("24 horas", "30 minutos")
+func formatValidity(ttl time.Duration) string {
+	switch {
+	case ttl >= time.Hour && ttl%time.Hour == 0:
+		if hours := int(ttl.Hours()); hours != 1 {
+			return fmt.Sprintf("%d horas", hours)
+		}
+		return "1 hora"
+	case ttl >= time.Minute:
+		if minutes := int(ttl.Minutes()); minutes != 1 {
+			return fmt.Sprintf("%d minutos", minutes)
+		}
+		return "1 minuto"
+	default:
+		return ttl.String()
+	}
+}
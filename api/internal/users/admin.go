@@ -0,0 +1,87 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"proyecto1/root/internal/auth"
+	"proyecto1/root/internal/http/dto"
+)
+
+// ListUsers returns a page of users for the admin listing
+func (s *Service) ListUsers(params ListParams) (*dto.AdminUsersResponse, error) {
+	if params.Role != "" && !auth.ValidRole(params.Role) {
+		return nil, ErrInvalidRole
+	}
+
+	users, totalCount, err := s.repo.ListUsers(params)
+	if err != nil {
+		return nil, errors.New("failed to list users")
+	}
+
+	response := &dto.AdminUsersResponse{
+		Users: make([]dto.AdminUserResponse, 0, len(users)),
+		Pagination: dto.PaginationResponse{
+			CurrentPage: params.Page,
+			PageSize:    params.PageSize,
+			TotalItems:  totalCount,
+			TotalPages:  int(math.Ceil(float64(totalCount) / float64(params.PageSize))),
+		},
+	}
+	for _, user := range users {
+		response.Users = append(response.Users, dto.AdminUserResponse{
+			ID:            user.ID,
+			FirstName:     user.FirstName,
+			LastName:      user.LastName,
+			Email:         user.Email,
+			City:          user.City,
+			Country:       user.Country,
+			Role:          user.Role,
+			EmailVerified: user.EmailVerifiedAt != nil,
+			DisabledAt:    user.DisabledAt,
+		})
+	}
+	return response, nil
+}
+
+// SetDisabled disables or re-enables the account of userID on behalf of the admin actorID.
+// Admins cannot disable themselves, so there is always one left to undo it
+func (s *Service) SetDisabled(actorID int, userID int, disabled bool) error {
+	if disabled && actorID == userID {
+		return ErrDisableSelf
+	}
+
+	if err := s.repo.SetDisabled(userID, disabled); err != nil {
+		return adminError(err, "failed to update account")
+	}
+
+	fmt.Printf("User %d set disabled=%t for user %d\n", actorID, disabled, userID)
+	return nil
+}
+
+// SetRole changes the role of userID on behalf of the admin actorID. Admins cannot change
+// their own role, so there is always one left to undo it
+func (s *Service) SetRole(actorID int, userID int, role string) error {
+	if !auth.ValidRole(role) {
+		return ErrInvalidRole
+	}
+	if actorID == userID {
+		return ErrChangeOwnRole
+	}
+
+	if err := s.repo.SetRole(userID, role); err != nil {
+		return adminError(err, "failed to update role")
+	}
+
+	fmt.Printf("User %d set role %s for user %d\n", actorID, role, userID)
+	return nil
+}
+
+// adminError keeps ErrUserNotFound and hides the details of database errors
+func adminError(err error, message string) error {
+	if errors.Is(err, ErrUserNotFound) {
+		return err
+	}
+	return errors.New(message)
+}
@@ -1,5 +1,7 @@
 package users
 
+import "time"
+
 // User represents the user model based on the database schema
 type User struct {
 	ID           int    `json:"id" db:"id"`
@@ -9,4 +11,47 @@ type User struct {
 	PasswordHash string `json:"-" db:"password_hash"`
 	City         string `json:"city" db:"city"`
 	Country      string `json:"country" db:"country"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at" db:"email_verified_at"` // nil until the email is verified
+
+	Role       string     `json:"role" db:"role"`               // player, moderator or admin (see auth.Role*)
+	DisabledAt *time.Time `json:"disabled_at" db:"disabled_at"` // Set when an admin disables the account
+}
+
+// ListParams are the filters and pagination of the admin user listing
+type ListParams struct {
+	Role     string `form:"role"`
+	Email    string `form:"email"` // Part of the email, case-insensitive
+	Disabled *bool  `form:"disabled"`
+	Page     int    `form:"page,default=1" binding:"min=1"`
+	PageSize int    `form:"page_size,default=20" binding:"min=1,max=100"`
+}
+
+// RefreshToken is a stored refresh token. Only the hash of the token is kept
+type RefreshToken struct {
+	ID        int64      `db:"id"`
+	UserID    int        `db:"user_id"`
+	FamilyID  string     `db:"family_id"`
+	TokenHash string     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	CreatedAt time.Time  `db:"created_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+}
+
+// Purposes of the single-use tokens sent by email
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+)
+
+// UserToken is a stored single-use token sent by email. Only the hash of the token is kept
+type UserToken struct {
+	ID        int64      `db:"id"`
+	UserID    int        `db:"user_id"`
+	Purpose   string     `db:"purpose"`
+	TokenHash string     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	CreatedAt time.Time  `db:"created_at"`
+	UsedAt    *time.Time `db:"used_at"`
 }
@@ -1,8 +1,11 @@
 package users
 
 import (
-	"errors"
+	"context"
+	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	"proyecto1/root/internal/database"
 )
@@ -15,6 +18,24 @@ func NewRepository(db *database.DB) *Repository {
 	return &Repository{db: db}
 }
 
+// userColumns lists the columns read by scanUser, in order
+const userColumns = `id, first_name, last_name, email, password_hash, city, country,
+		email_verified_at, role, disabled_at`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser reads a row selected with userColumns
+func scanUser(row rowScanner, user *User) error {
+	return row.Scan(
+		&user.ID, &user.FirstName, &user.LastName, &user.Email,
+		&user.PasswordHash, &user.City, &user.Country,
+		&user.EmailVerifiedAt, &user.Role, &user.DisabledAt,
+	)
+}
+
 // CreateUser creates a new user in the database
 func (r *Repository) CreateUser(user *User) (*User, error) {
 	query := `
@@ -29,7 +50,7 @@ func (r *Repository) CreateUser(user *User) (*User, error) {
 	if err != nil {
 		// Check if it's a duplicate email error
 		if err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"` {
-			return nil, errors.New("email already exists")
+			return nil, ErrEmailExists
 		}
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
@@ -54,15 +75,12 @@ func (r *Repository) EmailExists(email string) (bool, error) {
 // GetUserByEmail retrieves a user by email
 func (r *Repository) GetUserByEmail(email string) (*User, error) {
 	query := `
-		SELECT id, first_name, last_name, email, password_hash, city, country
+		SELECT ` + userColumns + `
 		FROM users 
 		WHERE email = $1`
 
 	var user User
-	err := r.db.QueryRow(query, email).Scan(
-		&user.ID, &user.FirstName, &user.LastName, &user.Email,
-		&user.PasswordHash, &user.City, &user.Country,
-	)
+	err := scanUser(r.db.QueryRow(query, email), &user)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user by email: %w", err)
@@ -74,18 +92,337 @@ func (r *Repository) GetUserByEmail(email string) (*User, error) {
 // GetUserByID retrieves a user by their ID
 func (r *Repository) GetUserByID(id int) (*User, error) {
 	query := `
-		SELECT id, first_name, last_name, email, password_hash, city, country
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE id = $1`
 
 	var user User
-	err := r.db.QueryRow(query, id).Scan(
-		&user.ID, &user.FirstName, &user.LastName, &user.Email,
-		&user.PasswordHash, &user.City, &user.Country,
-	)
+	err := scanUser(r.db.QueryRow(query, id), &user)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user by id: %w", err)
 	}
 
 	return &user, nil
 }
+
+// IsDisabled reports whether the account of a user is disabled. Unknown users are reported as
+// disabled, so the tokens of a removed account stop working too
+func (r *Repository) IsDisabled(ctx context.Context, userID int) (bool, error) {
+	var disabled bool
+	err := r.db.QueryRowContext(ctx, `SELECT disabled_at IS NOT NULL FROM users WHERE id = $1`, userID).Scan(&disabled)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check account status: %w", err)
+	}
+	return disabled, nil
+}
+
+// ListUsers retrieves a page of users matching the filters, ordered by id, and the total
+// number of matching users
+func (r *Repository) ListUsers(params ListParams) ([]*User, int64, error) {
+	var whereClauses []string
+	var args []any
+
+	if params.Role != "" {
+		args = append(args, params.Role)
+		whereClauses = append(whereClauses, fmt.Sprintf("role = $%d", len(args)))
+	}
+	if params.Email != "" {
+		args = append(args, "%"+params.Email+"%")
+		whereClauses = append(whereClauses, fmt.Sprintf("email ILIKE $%d", len(args)))
+	}
+	if params.Disabled != nil {
+		if *params.Disabled {
+			whereClauses = append(whereClauses, "disabled_at IS NOT NULL")
+		} else {
+			whereClauses = append(whereClauses, "disabled_at IS NULL")
+		}
+	}
+
+	var whereClause string
+	if len(whereClauses) > 0 {
+		whereClause = "WHERE " + strings.Join(whereClauses, " AND ")
+	}
+
+	var totalCount int64
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM users `+whereClause, args...).Scan(&totalCount); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM users
+		%s
+		ORDER BY id ASC
+		LIMIT $%d OFFSET $%d`, userColumns, whereClause, len(args)+1, len(args)+2)
+	args = append(args, params.PageSize, (params.Page-1)*params.PageSize)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		var user User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan user row: %w", err)
+		}
+		users = append(users, &user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating user rows: %w", err)
+	}
+
+	return users, totalCount, nil
+}
+
+// SetDisabled disables or re-enables an account. Disabling also revokes every refresh token of
+// the user, so the sessions end when their access token expires
+func (r *Repository) SetDisabled(userID int, disabled bool) error {
+	return r.db.WithTx(context.Background(), func(tx *sql.Tx) error {
+		query := `UPDATE users SET disabled_at = NULL WHERE id = $1`
+		if disabled {
+			query = `UPDATE users SET disabled_at = COALESCE(disabled_at, NOW()) WHERE id = $1`
+		}
+		if err := requireRow(tx.Exec(query, userID)); err != nil {
+			return err
+		}
+
+		if disabled {
+			return revokeUserRefreshTokens(tx, userID)
+		}
+		return nil
+	})
+}
+
+// SetRole changes the role of a user. Refresh tokens are kept: the next refresh issues an
+// access token with the new role
+func (r *Repository) SetRole(userID int, role string) error {
+	return requireRow(r.db.Exec(`UPDATE users SET role = $1 WHERE id = $2`, role, userID))
+}
+
+// requireRow checks that an update matched a row
+func requireRow(result sql.Result, err error) error {
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// CreateRefreshToken stores a new refresh token
+func (r *Repository) CreateRefreshToken(token *RefreshToken) error {
+	return createRefreshToken(r.db, token)
+}
+
+func createRefreshToken(q rowQuerier, token *RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	err := q.QueryRow(query, token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt).
+		Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+// RotateRefreshToken marks the token with the given hash as used and stores its replacement
+// of the same family, in one transaction that locks the presented token so concurrent
+// refreshes cannot both succeed. The presented token is returned. A token that was already
+// used is being replayed: its whole family is revoked and the error is ErrTokenReused
+func (r *Repository) RotateRefreshToken(tokenHash string, replacement *RefreshToken) (*RefreshToken, error) {
+	var current RefreshToken
+	reused := false
+
+	err := r.db.WithTx(context.Background(), func(tx *sql.Tx) error {
+		query := `
+			SELECT id, user_id, family_id, token_hash, expires_at, created_at, used_at, revoked_at
+			FROM refresh_tokens
+			WHERE token_hash = $1
+			FOR UPDATE`
+
+		err := tx.QueryRow(query, tokenHash).Scan(&current.ID, &current.UserID, &current.FamilyID,
+			&current.TokenHash, &current.ExpiresAt, &current.CreatedAt, &current.UsedAt, &current.RevokedAt)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: not found", ErrInvalidRefreshToken)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get refresh token: %w", err)
+		}
+
+		switch {
+		case current.RevokedAt != nil:
+			return fmt.Errorf("%w: revoked", ErrInvalidRefreshToken)
+		case current.UsedAt != nil:
+			// Committed below so the revocation survives the error returned to the caller
+			reused = true
+			return revokeFamily(tx, current.FamilyID)
+		case time.Now().After(current.ExpiresAt):
+			return fmt.Errorf("%w: expired", ErrInvalidRefreshToken)
+		}
+
+		if _, err := tx.Exec(`UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1`, current.ID); err != nil {
+			return fmt.Errorf("failed to mark refresh token as used: %w", err)
+		}
+
+		replacement.UserID = current.UserID
+		replacement.FamilyID = current.FamilyID
+		return createRefreshToken(tx, replacement)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return &current, fmt.Errorf("%w, family %s revoked", ErrTokenReused, current.FamilyID)
+	}
+
+	return &current, nil
+}
+
+// RevokeRefreshFamily revokes every token of the family the token with the given hash belongs
+// to. It reports whether the token was found
+func (r *Repository) RevokeRefreshFamily(tokenHash string) (bool, error) {
+	var familyID string
+	err := r.db.QueryRow(`SELECT family_id FROM refresh_tokens WHERE token_hash = $1`, tokenHash).Scan(&familyID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	if err := revokeFamily(r.db, familyID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CreateUserToken stores a new single-use token. Unused tokens of the same user and purpose
+// are marked as used, so only the link of the latest email works
+func (r *Repository) CreateUserToken(token *UserToken) error {
+	return r.db.WithTx(context.Background(), func(tx *sql.Tx) error {
+		query := `
+			UPDATE user_tokens SET used_at = NOW()
+			WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`
+		if _, err := tx.Exec(query, token.UserID, token.Purpose); err != nil {
+			return fmt.Errorf("failed to invalidate previous tokens: %w", err)
+		}
+
+		query = `
+			INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at`
+		err := tx.QueryRow(query, token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt).
+			Scan(&token.ID, &token.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create user token: %w", err)
+		}
+		return nil
+	})
+}
+
+// VerifyEmail consumes an email verification token and marks the email of its user as
+// verified. It returns the user id
+func (r *Repository) VerifyEmail(tokenHash string) (int, error) {
+	var userID int
+	err := r.db.WithTx(context.Background(), func(tx *sql.Tx) error {
+		var err error
+		if userID, err = consumeUserToken(tx, tokenHash, TokenPurposeVerifyEmail); err != nil {
+			return err
+		}
+		return markEmailVerified(tx, userID)
+	})
+	return userID, err
+}
+
+// ResetPassword consumes a password reset token and replaces the password of its user. The
+// email is marked as verified, since the user proved access to it, and every refresh token of
+// the user is revoked so other sessions cannot be renewed. It returns the user id
+func (r *Repository) ResetPassword(tokenHash string, passwordHash string) (int, error) {
+	var userID int
+	err := r.db.WithTx(context.Background(), func(tx *sql.Tx) error {
+		var err error
+		if userID, err = consumeUserToken(tx, tokenHash, TokenPurposeResetPassword); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`UPDATE users SET password_hash = $1 WHERE id = $2`, passwordHash, userID); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		if err := markEmailVerified(tx, userID); err != nil {
+			return err
+		}
+
+		return revokeUserRefreshTokens(tx, userID)
+	})
+	return userID, err
+}
+
+// consumeUserToken marks a valid token of the given purpose as used and returns its user. The
+// update only matches unused, unexpired tokens, so concurrent requests cannot both consume it
+func consumeUserToken(q rowQuerier, tokenHash string, purpose string) (int, error) {
+	query := `
+		UPDATE user_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`
+
+	var userID int
+	err := q.QueryRow(query, tokenHash, purpose).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidToken
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to consume user token: %w", err)
+	}
+	return userID, nil
+}
+
+// markEmailVerified sets the verification time of the user, keeping an earlier one
+func markEmailVerified(db execer, userID int) error {
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()) WHERE id = $1`
+	if _, err := db.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to mark email as verified: %w", err)
+	}
+	return nil
+}
+
+// revokeUserRefreshTokens revokes every refresh token of a user that is not revoked yet
+func revokeUserRefreshTokens(db execer, userID int) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	if _, err := db.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// rowQuerier is implemented by both the database connection and transactions
+type rowQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// execer is implemented by both the database connection and transactions
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// revokeFamily revokes the tokens of a family that are not revoked yet
+func revokeFamily(db execer, familyID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`
+	if _, err := db.Exec(query, familyID); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return nil
+}
@@ -2,28 +2,62 @@ package users
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
+	"time"
 
 	"proyecto1/root/internal/auth"
 	"proyecto1/root/internal/config"
 	"proyecto1/root/internal/http/dto"
+	"proyecto1/root/internal/mailer"
+)
+
+var (
+	// ErrPasswordMismatch is returned when the two passwords of a form differ
+	ErrPasswordMismatch = errors.New("passwords do not match")
+	// ErrEmailExists is returned when signing up with the email of another account
+	ErrEmailExists = errors.New("email already exists")
+	// ErrInvalidCredentials is returned when the email has no account or the password is wrong
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrAccountDisabled is returned when a disabled account logs in or refreshes its tokens
+	ErrAccountDisabled = errors.New("account disabled")
+	// ErrEmailNotVerified is returned when logging in before verifying the email, if it is required
+	ErrEmailNotVerified = errors.New("email not verified")
+	// ErrInvalidRefreshToken is returned when a refresh token is unknown, expired, revoked or replayed
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrTokenReused is returned by the repository when a used refresh token is presented again
+	ErrTokenReused = errors.New("refresh token reuse detected")
+	// ErrInvalidToken is returned when a verification or reset token is unknown, used or expired
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrUserNotFound is returned when updating a user that does not exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrInvalidRole is returned when a role is not one of auth.Roles
+	ErrInvalidRole = errors.New("invalid role")
+	// ErrDisableSelf is returned when an admin disables their own account
+	ErrDisableSelf = errors.New("cannot disable your own account")
+	// ErrChangeOwnRole is returned when an admin changes their own role
+	ErrChangeOwnRole = errors.New("cannot change your own role")
 )
 
 type Service struct {
-	repo         *Repository
-	tokenManager *auth.TokenManager
-	jwtConfig    *config.JWTConfig
+	repo          *Repository
+	tokenManager  *auth.TokenManager
+	mailer        mailer.Mailer
+	jwtConfig     *config.JWTConfig
+	accountConfig *config.AccountConfig
+	appName       string
 }
 
-func NewService(repo *Repository, cfg *config.Config) *Service {
-	tokenManager := &auth.TokenManager{
-		Secret: []byte(cfg.JWT.Secret),
-		Issuer: cfg.JWT.Issuer,
-	}
+// NewService creates the user service. Tokens are signed by the shared token manager, which
+// holds the signing keys, and the verification and reset links are sent with the mailer
+func NewService(repo *Repository, cfg *config.Config, tokenManager *auth.TokenManager, mailer mailer.Mailer) *Service {
 	return &Service{
-		repo:         repo,
-		tokenManager: tokenManager,
-		jwtConfig:    &cfg.JWT,
+		repo:          repo,
+		tokenManager:  tokenManager,
+		mailer:        mailer,
+		jwtConfig:     &cfg.JWT,
+		accountConfig: &cfg.Account,
+		appName:       cfg.App.Name,
 	}
 }
 
@@ -31,7 +65,7 @@ func NewService(repo *Repository, cfg *config.Config) *Service {
 func (s *Service) Signup(req dto.SignupRequest) (*dto.SignupResponse, error) {
 	// Validate passwords match
 	if req.Password1 != req.Password2 {
-		return nil, errors.New("passwords do not match")
+		return nil, ErrPasswordMismatch
 	}
 
 	// Check if email already exists
@@ -41,7 +75,7 @@ func (s *Service) Signup(req dto.SignupRequest) (*dto.SignupResponse, error) {
 	}
 
 	if exists {
-		return nil, errors.New("email already exists")
+		return nil, ErrEmailExists
 	}
 
 	// Hash password
@@ -63,12 +97,20 @@ func (s *Service) Signup(req dto.SignupRequest) (*dto.SignupResponse, error) {
 	// Save user to database
 	createdUser, err := s.repo.CreateUser(user)
 	if err != nil {
-		if err.Error() == "email already exists" {
-			return nil, errors.New("email already exists")
+		if errors.Is(err, ErrEmailExists) {
+			return nil, ErrEmailExists
 		}
 		return nil, errors.New("failed to create user")
 	}
 
+	// The account is created even if the email cannot be sent, a password reset also verifies it.
+	// Sent in the background so the response does not wait for the mail server
+	go func() {
+		if err := s.sendVerificationEmail(createdUser); err != nil {
+			fmt.Printf("Warning: failed to send verification email to user %d: %v\n", createdUser.ID, err)
+		}
+	}()
+
 	// Return success response
 	response := &dto.SignupResponse{
 		ID:        createdUser.ID,
@@ -87,27 +129,49 @@ func (s *Service) Login(req dto.LoginRequest) (*dto.LoginResponse, error) {
 	// Get user by email
 	user, err := s.repo.GetUserByEmail(req.Email)
 	if err != nil {
-		return nil, errors.New("invalid email or password")
+		return nil, ErrInvalidCredentials
 	}
 
 	// Verify password
 	if err := auth.CheckPassword(user.PasswordHash, req.Password); err != nil {
-		return nil, errors.New("invalid email or password")
+		return nil, ErrInvalidCredentials
 	}
 
-	// Generate JWT token
-	customClaims := map[string]any{
-		"user_id": user.ID,
-		"email":   user.Email,
+	// Checked after the password so disabled and unverified accounts are not revealed to other people
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
 	}
-	token, err := s.tokenManager.CreateToken(strconv.Itoa(user.ID), s.jwtConfig.Expiration, customClaims)
+	if s.accountConfig.RequireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
+	}
+
+	// Start a new refresh token family for this login
+	family, err := auth.NewTokenFamily()
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+	refreshToken, err := auth.NewOpaqueToken()
 	if err != nil {
 		return nil, errors.New("failed to generate token")
 	}
+	err = s.repo.CreateRefreshToken(&RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  family,
+		TokenHash: auth.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(s.jwtConfig.RefreshExpiration),
+	})
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+
+	tokens, err := s.tokenResponse(user, refreshToken)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create response
 	response := &dto.LoginResponse{
-		Token: token,
+		TokenResponse: *tokens,
 	}
 	response.User.ID = user.ID
 	response.User.FirstName = user.FirstName
@@ -115,10 +179,78 @@ func (s *Service) Login(req dto.LoginRequest) (*dto.LoginResponse, error) {
 	response.User.Email = user.Email
 	response.User.City = user.City
 	response.User.Country = user.Country
+	response.User.EmailVerified = user.EmailVerifiedAt != nil
+	response.User.Role = user.Role
 
 	return response, nil
 }
 
+// Refresh exchanges a refresh token for a new access token and a new refresh token. The
+// presented token cannot be used again; replaying it revokes every token of its family
+func (s *Service) Refresh(req dto.RefreshRequest) (*dto.TokenResponse, error) {
+	refreshToken, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+
+	replacement := &RefreshToken{
+		TokenHash: auth.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(s.jwtConfig.RefreshExpiration),
+	}
+	current, err := s.repo.RotateRefreshToken(auth.HashToken(req.RefreshToken), replacement)
+	if err != nil {
+		if errors.Is(err, ErrTokenReused) {
+			fmt.Printf("Warning: refresh token replayed for user %d: %v\n", current.UserID, err)
+		}
+		// Not found, expired, revoked and replayed tokens all get the same answer
+		if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, ErrTokenReused) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, errors.New("failed to refresh token")
+	}
+
+	user, err := s.repo.GetUserByID(current.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+	return s.tokenResponse(user, refreshToken)
+}
+
+// Logout revokes the family of a refresh token, so neither it nor any token rotated from the
+// same login can be refreshed again
+func (s *Service) Logout(refreshToken string) error {
+	found, err := s.repo.RevokeRefreshFamily(auth.HashToken(refreshToken))
+	if err != nil {
+		return errors.New("failed to revoke refresh token")
+	}
+	if !found {
+		return ErrInvalidRefreshToken
+	}
+	return nil
+}
+
+// tokenResponse signs an access token for the user and pairs it with the refresh token
+func (s *Service) tokenResponse(user *User, refreshToken string) (*dto.TokenResponse, error) {
+	customClaims := map[string]any{
+		"user_id": user.ID,
+		"email":   user.Email,
+		"role":    user.Role, // Read again from the database on every refresh
+	}
+	token, err := s.tokenManager.CreateToken(strconv.Itoa(user.ID), s.jwtConfig.Expiration, customClaims)
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+
+	return &dto.TokenResponse{
+		Token:        token,
+		ExpiresIn:    int(s.jwtConfig.Expiration.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
 func (s *Service) GetUserByID(id int) (*dto.UserResponse, error) {
 	user, err := s.repo.GetUserByID(id)
 	if err != nil {
@@ -131,5 +263,8 @@ func (s *Service) GetUserByID(id int) (*dto.UserResponse, error) {
 		Email:     user.Email,
 		City:      user.City,
 		Country:   user.Country,
+
+		EmailVerified: user.EmailVerifiedAt != nil,
+		Role:          user.Role,
 	}, nil
 }
@@ -0,0 +1,111 @@
+package videos
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// sniffLength is how many leading bytes are read to detect the container
+const sniffLength = 512
+
+// Container describes an accepted input container. The original is stored with its extension
+// and the worker normalises every container to the same H.264 MP4 output
+type Container struct {
+	Name        string // Name used in the profile allow-list: mp4, mov, webm, mkv
+	Extension   string // Extension of the stored original
+	ContentType string // Content type of the stored original
+	formatName  string // Part of the ffprobe format_name reported for the container family
+}
+
+// containers lists the supported input containers
+var containers = []Container{
+	{Name: "mp4", Extension: ".mp4", ContentType: "video/mp4", formatName: "mp4"},
+	{Name: "mov", Extension: ".mov", ContentType: "video/quicktime", formatName: "mov"},
+	{Name: "webm", Extension: ".webm", ContentType: "video/webm", formatName: "webm"},
+	{Name: "mkv", Extension: ".mkv", ContentType: "video/x-matroska", formatName: "matroska"},
+}
+
+// ContainerByName returns the container with the given profile name
+func ContainerByName(name string) (Container, bool) {
+	for _, container := range containers {
+		if container.Name == name {
+			return container, true
+		}
+	}
+	return Container{}, false
+}
+
+// containerForExtension returns the container of a file extension (".MOV" -> mov). Only used
+// when the content is not available yet, e.g. when a presigned upload is created
+func containerForExtension(ext string) (Container, bool) {
+	ext = strings.ToLower(ext)
+	for _, container := range containers {
+		if container.Extension == ext {
+			return container, true
+		}
+	}
+	return Container{}, false
+}
+
+// matchesFormat reports whether ffprobe's format_name belongs to the container family
+// ("mov,mp4,m4a,3gp,3g2,mj2" for MP4 and MOV, "matroska,webm" for WebM and Matroska)
+func (c Container) matchesFormat(formatName string) bool {
+	return strings.Contains(strings.ToLower(formatName), c.formatName)
+}
+
+// sniffContainer detects the container from the first bytes of a file
+func sniffContainer(header []byte) (Container, error) {
+	// ISO base media (MP4) and QuickTime (MOV) files start with a box: 4 bytes size and 4 bytes type
+	if len(header) >= 12 && string(header[4:8]) == "ftyp" {
+		if string(header[8:12]) == "qt  " { // QuickTime brand
+			return mustContainer("mov"), nil
+		}
+		return mustContainer("mp4"), nil
+	}
+	if len(header) >= 8 {
+		switch string(header[4:8]) {
+		case "moov", "mdat", "wide", "free", "skip", "pnot": // Older QuickTime files have no ftyp box
+			return mustContainer("mov"), nil
+		}
+	}
+
+	// WebM and Matroska share the EBML header, the DocType element tells them apart
+	if bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3}) {
+		if bytes.Contains(header, []byte("webm")) {
+			return mustContainer("webm"), nil
+		}
+		if bytes.Contains(header, []byte("matroska")) {
+			return mustContainer("mkv"), nil
+		}
+	}
+
+	return Container{}, fmt.Errorf("unsupported format: file content is not MP4, MOV, WebM or MKV")
+}
+
+// mustContainer returns one of the containers listed above
+func mustContainer(name string) Container {
+	container, ok := ContainerByName(name)
+	if !ok {
+		panic("unknown container " + name)
+	}
+	return container
+}
+
+// readHeader returns the first sniffLength bytes of a file
+func readHeader(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open video file: %w", err)
+	}
+	defer file.Close()
+
+	header := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read video file: %w", err)
+	}
+	return header[:n], nil
+}
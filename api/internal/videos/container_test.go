@@ -0,0 +1,37 @@
+package videos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffContainer(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   []byte
+		expected string
+	}{
+		{name: "MP4", header: []byte("\x00\x00\x00\x20ftypisom\x00\x00\x02\x00"), expected: "mp4"},
+		{name: "QuickTime brand", header: []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x02\x00"), expected: "mov"},
+		{name: "QuickTime without ftyp", header: []byte("\x00\x00\x00\x08wide\x00\x00\x00\x00mdat"), expected: "mov"},
+		{name: "WebM", header: []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84webm"), expected: "webm"},
+		{name: "Matroska", header: []byte("\x1a\x45\xdf\xa3\xa3\x42\x86\x81\x01\x42\x82\x88matroska"), expected: "mkv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container, err := sniffContainer(tt.header)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, container.Name)
+		})
+	}
+
+	_, err := sniffContainer([]byte("RIFF\x00\x00\x00\x00AVI LIST"))
+	assert.ErrorContains(t, err, "unsupported format")
+
+	mkv, _ := ContainerByName("mkv")
+	assert.True(t, mkv.matchesFormat("matroska,webm"))
+	assert.False(t, mkv.matchesFormat("mov,mp4,m4a,3gp,3g2,mj2"))
+}
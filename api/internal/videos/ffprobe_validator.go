@@ -1,6 +1,8 @@
 package videos
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,8 +10,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+
+	"proyecto1/root/internal/config"
 )
 
 // FFProbeValidator handles video validation using ffprobe
@@ -20,14 +25,8 @@ type FFProbeValidator struct {
 
 // FFProbeOutput represents the structure of ffprobe JSON output
 type FFProbeOutput struct {
-	Streams []struct {
-		CodecType string `json:"codec_type"`
-		CodecName string `json:"codec_name"`
-		Width     int    `json:"width"`
-		Height    int    `json:"height"`
-		Duration  string `json:"duration"`
-	} `json:"streams"`
-	Format struct {
+	Streams []FFProbeStream `json:"streams"`
+	Format  struct {
 		FormatName     string `json:"format_name"`
 		Duration       string `json:"duration"`
 		Size           string `json:"size"`
@@ -37,12 +36,32 @@ type FFProbeOutput struct {
 	} `json:"format"`
 }
 
+// FFProbeStream represents one stream of the ffprobe JSON output
+type FFProbeStream struct {
+	CodecType    string `json:"codec_type"`
+	CodecName    string `json:"codec_name"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Duration     string `json:"duration"`
+	AvgFrameRate string `json:"avg_frame_rate"`
+	RFrameRate   string `json:"r_frame_rate"`
+	Tags         struct {
+		Rotate string `json:"rotate"` // Older ffmpeg versions report rotation as a tag
+	} `json:"tags"`
+	SideDataList []struct {
+		Rotation int `json:"rotation"` // Display matrix rotation, counter-clockwise degrees
+	} `json:"side_data_list"`
+}
+
 // ValidationRules represents video validation constraints
 type ValidationRules struct {
-	MaxSizeBytes int64   // 100MB
-	MinDuration  float64 // 20 seconds
-	MaxDuration  float64 // 60 seconds
-	MinHeight    int     // > 1080p
+	MaxSizeBytes       int64    // 100MB
+	MinDuration        float64  // 20 seconds
+	MaxDuration        float64  // 60 seconds
+	MinWidth           int      // 1920px
+	MinHeight          int      // 1080p
+	AllowedContainers  []string // Container names ("mp4", "mov", "webm", "mkv")
+	AllowedVideoCodecs []string // ffprobe codec names ("h264", "hevc", "vp9", "av1")
 }
 
 // NewFFProbeValidator creates a new FFprobe validator
@@ -57,87 +76,205 @@ func NewFFProbeValidator(tempDir string) *FFProbeValidator {
 	}
 }
 
-// DefaultValidationRules returns the validation rules for this project
-func DefaultValidationRules() ValidationRules {
-	return ValidationRules{
-		MaxSizeBytes: 100 * 1024 * 1024, // 100MB
-		MinDuration:  20,                // 20 seconds
-		MaxDuration:  60,                // 60 seconds
-		MinHeight:    1080,              // Greater than 1080p
+// NewValidationRules converts the validation section of an upload profile
+func NewValidationRules(profile config.ValidationProfile) ValidationRules {
+	rules := ValidationRules{
+		MaxSizeBytes: profile.MaxSizeMB * 1024 * 1024,
+		MinDuration:  profile.MinDurationSeconds,
+		MaxDuration:  profile.MaxDurationSeconds,
+		MinWidth:     profile.MinWidth,
+		MinHeight:    profile.MinHeight,
+
+		AllowedContainers: profile.AllowedContainers,
+	}
+	for _, codec := range profile.AllowedVideoCodecs {
+		rules.AllowedVideoCodecs = append(rules.AllowedVideoCodecs, normalizeVideoCodec(codec))
 	}
+	return rules
 }
 
-// ValidateVideo performs complete video validation using ffprobe
+// DefaultValidationRules returns the validation rules of the built-in standard profile
+func DefaultValidationRules() ValidationRules {
+	return NewValidationRules(config.BuiltinValidationProfile())
+}
+
+// ValidateVideo performs complete video validation using ffprobe. The container is detected
+// from the file content, the client filename is not trusted
 func (v *FFProbeValidator) ValidateVideo(file *multipart.FileHeader, rules ValidationRules) (*VideoMetadata, error) {
 	// 1. Quick pre-validation (no I/O)
-	if err := v.quickValidation(file, rules); err != nil {
+	if err := v.quickValidation(file.Size, rules); err != nil {
 		return nil, fmt.Errorf("quick validation failed: %w", err)
 	}
 
 	// 2. Save uploaded file to temporary location
-	tempFile, err := v.saveToTempFile(file)
+	tempFile, checksum, err := v.saveToTempFile(file)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temp file: %w", err)
 	}
 	defer os.Remove(tempFile) // Always clean up
 
-	// 3. Run ffprobe analysis
-	metadata, err := v.analyzeWithFFProbe(tempFile, file.Size)
+	header, err := readHeader(tempFile)
 	if err != nil {
-		return nil, fmt.Errorf("ffprobe analysis failed: %w", err)
+		return nil, err
 	}
 
-	// 4. Validate extracted metadata against rules
-	if err := v.validateMetadata(metadata, rules); err != nil {
-		return nil, fmt.Errorf("metadata validation failed: %w", err)
+	// 3. Detect the container, run ffprobe and validate the metadata
+	metadata, err := v.validateContent(tempFile, header, file.Size, rules)
+	if err != nil {
+		return nil, err
 	}
+	metadata.Checksum = checksum
 
 	return metadata, nil
 }
 
+// ValidateFile performs complete video validation on a file that is already on disk,
+// such as an upload assembled from resumable chunks
+func (v *FFProbeValidator) ValidateFile(path string, rules ValidationRules) (*VideoMetadata, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat video file: %w", err)
+	}
+
+	// 1. Quick pre-validation (no I/O)
+	if err := v.quickValidation(info.Size(), rules); err != nil {
+		return nil, fmt.Errorf("quick validation failed: %w", err)
+	}
+
+	header, err := readHeader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Detect the container, run ffprobe directly on the file and validate the metadata
+	metadata, err := v.validateContent(path, header, info.Size(), rules)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata.Checksum, err = fileChecksum(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return metadata, nil
+}
+
+// ValidateRemote performs complete video validation on a file that is already in object
+// storage. header holds the first bytes of the object, used to detect the container. ffprobe
+// reads the (presigned) URL directly, so only the headers and the parts of the file it needs
+// are transferred. The checksum is left empty, computing it would download the whole file
+func (v *FFProbeValidator) ValidateRemote(url string, header []byte, size int64, rules ValidationRules) (*VideoMetadata, error) {
+	// 1. Quick pre-validation (no I/O)
+	if err := v.quickValidation(size, rules); err != nil {
+		return nil, fmt.Errorf("quick validation failed: %w", err)
+	}
+
+	// 2. Detect the container, run ffprobe against the stored object and validate the metadata
+	return v.validateContent(url, header, size, rules)
+}
+
+// validateDeclaredFile checks the name and size a client declares before uploading, when the
+// content is not available yet. The container is checked again from the content on completion
+func (v *FFProbeValidator) validateDeclaredFile(filename string, size int64, rules ValidationRules) (Container, error) {
+	if err := v.quickValidation(size, rules); err != nil {
+		return Container{}, err
+	}
+
+	ext := filepath.Ext(filename)
+	container, ok := containerForExtension(ext)
+	if !ok || !slices.Contains(rules.AllowedContainers, container.Name) {
+		return Container{}, fmt.Errorf("invalid file extension: %s (allowed containers: %s)",
+			strings.ToLower(ext), strings.Join(rules.AllowedContainers, ", "))
+	}
+
+	return container, nil
+}
+
 // quickValidation performs fast validations without I/O
-func (v *FFProbeValidator) quickValidation(file *multipart.FileHeader, rules ValidationRules) error {
+func (v *FFProbeValidator) quickValidation(size int64, rules ValidationRules) error {
 	// Check file size first (fastest check)
-	if file.Size > rules.MaxSizeBytes {
+	if size > rules.MaxSizeBytes {
 		return fmt.Errorf("file too large: %d bytes (max: %d bytes / %.1fMB)",
-			file.Size, rules.MaxSizeBytes, float64(rules.MaxSizeBytes)/(1024*1024))
+			size, rules.MaxSizeBytes, float64(rules.MaxSizeBytes)/(1024*1024))
 	}
 
-	if file.Size == 0 {
+	if size == 0 {
 		return fmt.Errorf("file is empty")
 	}
 
-	// Check file extension (basic format check)
-	ext := strings.ToLower(filepath.Ext(file.Filename))
-	if ext != ".mp4" {
-		return fmt.Errorf("invalid file extension: %s (only .mp4 allowed)", ext)
+	return nil
+}
+
+// validateContent detects the container from the leading bytes of the file, runs ffprobe on
+// target (a path or URL) and validates the extracted metadata against the rules
+func (v *FFProbeValidator) validateContent(target string, header []byte, size int64, rules ValidationRules) (*VideoMetadata, error) {
+	// 1. Content sniffing, the extension is not trusted
+	container, err := sniffContainer(header)
+	if err != nil {
+		return nil, fmt.Errorf("quick validation failed: %w", err)
+	}
+	if !slices.Contains(rules.AllowedContainers, container.Name) {
+		return nil, fmt.Errorf("quick validation failed: %s files are not allowed (allowed containers: %s)",
+			container.Name, strings.Join(rules.AllowedContainers, ", "))
 	}
 
-	return nil
+	// 2. Run ffprobe analysis, it must agree with the detected container
+	metadata, err := v.analyzeWithFFProbe(target, size)
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe analysis failed: %w", err)
+	}
+	if !container.matchesFormat(metadata.Container) {
+		return nil, fmt.Errorf("ffprobe analysis failed: %s content reported as %s", container.Name, metadata.Container)
+	}
+	metadata.Format = container.Name
+
+	// 3. Validate extracted metadata against rules
+	if err := v.validateMetadata(metadata, rules); err != nil {
+		return nil, fmt.Errorf("metadata validation failed: %w", err)
+	}
+
+	return metadata, nil
 }
 
-// saveToTempFile saves the uploaded file to a temporary location
-func (v *FFProbeValidator) saveToTempFile(file *multipart.FileHeader) (string, error) {
+// saveToTempFile saves the uploaded file to a temporary location and returns its SHA-256 checksum
+func (v *FFProbeValidator) saveToTempFile(file *multipart.FileHeader) (string, string, error) {
 	src, err := file.Open()
 	if err != nil {
-		return "", fmt.Errorf("failed to open uploaded file: %w", err)
+		return "", "", fmt.Errorf("failed to open uploaded file: %w", err)
 	}
 	defer src.Close()
 
-	// Create temp file with .mp4 extension for better ffprobe detection
-	tempFile, err := os.CreateTemp(v.tempDir, "video_*.mp4")
+	// ffprobe detects the container from the content, so the temp file has no extension
+	tempFile, err := os.CreateTemp(v.tempDir, "video_*")
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
+		return "", "", fmt.Errorf("failed to create temp file: %w", err)
 	}
 	defer tempFile.Close()
 
-	// Copy uploaded file to temp location
-	if _, err := io.Copy(tempFile, src); err != nil {
+	// Copy uploaded file to temp location, hashing it on the way
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tempFile, hash), src); err != nil {
 		os.Remove(tempFile.Name())
-		return "", fmt.Errorf("failed to copy file: %w", err)
+		return "", "", fmt.Errorf("failed to copy file: %w", err)
 	}
 
-	return tempFile.Name(), nil
+	return tempFile.Name(), hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// fileChecksum returns the SHA-256 checksum of a file on disk
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open video file: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to compute checksum: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
 // analyzeWithFFProbe runs ffprobe on the file and extracts metadata
@@ -169,24 +306,18 @@ func (v *FFProbeValidator) analyzeWithFFProbe(tempFile string, fileSize int64) (
 
 // extractMetadata extracts video metadata from ffprobe output
 func (v *FFProbeValidator) extractMetadata(probe *FFProbeOutput, fileSize int64) (*VideoMetadata, error) {
-	// Validate container format is MP4
-	if !v.isValidMP4Format(probe.Format.FormatName) {
-		return nil, fmt.Errorf("invalid container format: %s (expected MP4)", probe.Format.FormatName)
-	}
-
-	// Find the video stream (there should be at least one)
-	var videoStream *struct {
-		CodecType string `json:"codec_type"`
-		CodecName string `json:"codec_name"`
-		Width     int    `json:"width"`
-		Height    int    `json:"height"`
-		Duration  string `json:"duration"`
-	}
-
+	// Find the video stream (there should be at least one) and the first audio stream
+	var videoStream, audioStream *FFProbeStream
 	for i := range probe.Streams {
-		if probe.Streams[i].CodecType == "video" {
-			videoStream = &probe.Streams[i]
-			break
+		switch probe.Streams[i].CodecType {
+		case "video":
+			if videoStream == nil {
+				videoStream = &probe.Streams[i]
+			}
+		case "audio":
+			if audioStream == nil {
+				audioStream = &probe.Streams[i]
+			}
 		}
 	}
 
@@ -214,18 +345,62 @@ func (v *FFProbeValidator) extractMetadata(probe *FFProbeOutput, fileSize int64)
 		return nil, fmt.Errorf("invalid video dimensions: %dx%d", videoStream.Width, videoStream.Height)
 	}
 
-	// Validate video codec is compatible
-	if !v.isValidVideoCodec(videoStream.CodecName) {
-		return nil, fmt.Errorf("unsupported video codec: %s (expected H.264, H.265)", videoStream.CodecName)
+	metadata := &VideoMetadata{
+		Duration:   durationFloat,
+		Width:      videoStream.Width,
+		Height:     videoStream.Height,
+		Size:       fileSize,
+		Container:  probe.Format.FormatName,
+		VideoCodec: videoStream.CodecName,
+		FPS:        parseFrameRate(videoStream.AvgFrameRate),
+		Rotation:   streamRotation(videoStream),
+	}
+	if metadata.FPS == 0 {
+		metadata.FPS = parseFrameRate(videoStream.RFrameRate)
+	}
+	if bitrate, err := strconv.ParseInt(probe.Format.BitRate, 10, 64); err == nil {
+		metadata.Bitrate = bitrate
+	}
+	if audioStream != nil {
+		metadata.HasAudio = true
+		metadata.AudioCodec = audioStream.CodecName
+	}
+
+	return metadata, nil
+}
+
+// parseFrameRate converts an ffprobe rational frame rate ("30000/1001") to frames per second
+func parseFrameRate(rate string) float64 {
+	num, den, found := strings.Cut(rate, "/")
+	numerator, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !found {
+		return numerator
+	}
+
+	denominator, err := strconv.ParseFloat(den, 64)
+	if err != nil || denominator == 0 {
+		return 0
 	}
+	return numerator / denominator
+}
 
-	return &VideoMetadata{
-		Duration: durationFloat,
-		Width:    videoStream.Width,
-		Height:   videoStream.Height,
-		Size:     fileSize,
-		Format:   "mp4",
-	}, nil
+// streamRotation returns the clockwise rotation players apply to the stream (0, 90, 180 or 270)
+func streamRotation(stream *FFProbeStream) int {
+	rotation := 0
+	if stream.Tags.Rotate != "" {
+		rotation, _ = strconv.Atoi(stream.Tags.Rotate)
+	} else {
+		for _, sideData := range stream.SideDataList {
+			if sideData.Rotation != 0 {
+				rotation = -sideData.Rotation // The display matrix is counter-clockwise
+				break
+			}
+		}
+	}
+	return ((rotation % 360) + 360) % 360
 }
 
 // validateMetadata validates extracted metadata against business rules
@@ -236,60 +411,42 @@ func (v *FFProbeValidator) validateMetadata(metadata *VideoMetadata, rules Valid
 			metadata.Duration, rules.MinDuration, rules.MaxDuration)
 	}
 
-	// Validate resolution is greater than 1080p
-	if metadata.Height <= rules.MinHeight-1 { // rules.MinHeight is 1081 (> 1080)
-		return fmt.Errorf("video resolution %dx%d is below minimum 1080p (1920x1080)",
-			metadata.Width, metadata.Height)
+	// Validate resolution is at least the profile minimum, as displayed (phones record portrait
+	// clips as landscape frames with a rotation)
+	width, height := metadata.DisplaySize()
+	if height < rules.MinHeight {
+		return fmt.Errorf("video resolution %dx%d is below minimum %dp (%dx%d)",
+			width, height, rules.MinHeight, rules.MinWidth, rules.MinHeight)
 	}
 
 	// Additional sanity checks
-	if metadata.Width < 1920 { // Assume minimum Full HD width
-		return fmt.Errorf("video width %dpx is too low (minimum: 1920px for >1080p)",
-			metadata.Width)
-	}
-
-	return nil
-}
-
-// isValidMP4Format checks if ffprobe detected format is MP4 compatible
-func (v *FFProbeValidator) isValidMP4Format(formatName string) bool {
-	// FFprobe typically returns "mov,mp4,m4a,3gp,3g2,mj2" for MP4 files
-	validFormats := []string{
-		"mov,mp4,m4a,3gp,3g2,mj2", // Most common ffprobe output for MP4
-		"mp4",
-		"mov", // MOV is essentially the same container as MP4
+	if width < rules.MinWidth {
+		return fmt.Errorf("video width %dpx is too low (minimum: %dpx)",
+			width, rules.MinWidth)
 	}
 
-	formatLower := strings.ToLower(formatName)
-	for _, valid := range validFormats {
-		if strings.Contains(formatLower, "mp4") || formatLower == valid {
-			return true
-		}
+	// Validate video codec is allowed by the profile
+	if !slices.Contains(rules.AllowedVideoCodecs, normalizeVideoCodec(metadata.VideoCodec)) {
+		return fmt.Errorf("unsupported video codec: %s (allowed: %s)",
+			metadata.VideoCodec, strings.Join(rules.AllowedVideoCodecs, ", "))
 	}
 
-	return false
+	return nil
 }
 
-// isValidVideoCodec validates that the video codec is supported
-func (v *FFProbeValidator) isValidVideoCodec(codecName string) bool {
-	// Accept common MP4 video codecs
-	validCodecs := []string{
-		"h264", // Most common (AVC)
-		"h265", // HEVC
-		"hevc", // HEVC alternative name
-		"avc1", // H.264 in MP4 container
-		"hvc1", // H.265 in MP4 container
-		"mp4v", // MPEG-4 Part 2
-	}
-
+// normalizeVideoCodec maps the alternative names of a codec to the name reported by ffprobe
+func normalizeVideoCodec(codecName string) string {
 	codecLower := strings.ToLower(codecName)
-	for _, valid := range validCodecs {
-		if codecLower == valid {
-			return true
-		}
+	switch codecLower {
+	case "avc", "avc1": // H.264 in MP4 container
+		return "h264"
+	case "h265", "hvc1", "hev1": // H.265 in MP4 container
+		return "hevc"
+	case "mp4v": // MPEG-4 Part 2
+		return "mpeg4"
+	default:
+		return codecLower
 	}
-
-	return false
 }
 
 // CheckFFProbeInstallation verifies that ffprobe is available
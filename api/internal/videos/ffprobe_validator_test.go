@@ -0,0 +1,75 @@
+package videos
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractMetadata(t *testing.T) {
+	output := `{
+		"streams": [
+			{"codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080, "duration": "25.0",
+			 "avg_frame_rate": "30000/1001", "r_frame_rate": "30/1",
+			 "side_data_list": [{"rotation": -90}]},
+			{"codec_type": "audio", "codec_name": "aac"}
+		],
+		"format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "duration": "25.1", "bit_rate": "8000000"}
+	}`
+
+	var probe FFProbeOutput
+	require.NoError(t, json.Unmarshal([]byte(output), &probe))
+
+	metadata, err := NewFFProbeValidator("").extractMetadata(&probe, 1234)
+	require.NoError(t, err)
+
+	assert.Equal(t, 25.0, metadata.Duration)
+	assert.Equal(t, "mov,mp4,m4a,3gp,3g2,mj2", metadata.Container)
+	assert.Equal(t, "h264", metadata.VideoCodec)
+	assert.True(t, metadata.HasAudio)
+	assert.Equal(t, "aac", metadata.AudioCodec)
+	assert.Equal(t, int64(8000000), metadata.Bitrate)
+	assert.InDelta(t, 29.97, metadata.FPS, 0.01)
+	assert.Equal(t, 90, metadata.Rotation)
+	assert.Equal(t, int64(1234), metadata.Size)
+}
+
+func TestStreamRotation(t *testing.T) {
+	tagged := &FFProbeStream{}
+	tagged.Tags.Rotate = "270"
+	assert.Equal(t, 270, streamRotation(tagged))
+
+	assert.Equal(t, 0, streamRotation(&FFProbeStream{}))
+	assert.Equal(t, 0.0, parseFrameRate("0/0"))
+	assert.Equal(t, 25.0, parseFrameRate("25"))
+}
+
+func TestValidateMetadataUsesProfileRules(t *testing.T) {
+	validator := NewFFProbeValidator("")
+	rules := DefaultValidationRules()
+	metadata := &VideoMetadata{Duration: 30, Width: 1920, Height: 1080, VideoCodec: "h264"}
+
+	assert.NoError(t, validator.validateMetadata(metadata, rules))
+	container, err := validator.validateDeclaredFile("clip.MOV", 1024, rules)
+	require.NoError(t, err)
+	assert.Equal(t, "video/quicktime", container.ContentType)
+	_, err = validator.validateDeclaredFile("clip.avi", 1024, rules)
+	assert.ErrorContains(t, err, "invalid file extension")
+
+	metadata.VideoCodec = "mpeg4"
+	assert.ErrorContains(t, validator.validateMetadata(metadata, rules), "unsupported video codec")
+
+	// A profile for vertical short clips
+	rules.MinWidth, rules.MinHeight = 720, 1280
+	rules.AllowedVideoCodecs = append(rules.AllowedVideoCodecs, "mpeg4")
+	metadata.Width, metadata.Height = 1080, 1920
+	assert.NoError(t, validator.validateMetadata(metadata, rules))
+
+	// The same clip recorded as a rotated landscape frame
+	metadata.Width, metadata.Height, metadata.Rotation = 1920, 1080, 90
+	assert.NoError(t, validator.validateMetadata(metadata, rules))
+	metadata.Rotation = 0
+	assert.ErrorContains(t, validator.validateMetadata(metadata, rules), "below minimum")
+}
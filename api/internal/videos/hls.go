@@ -0,0 +1,107 @@
+package videos
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// hlsMasterPlaylist is the entry point of the HLS set written by the worker under processed/{id}/hls/
+const hlsMasterPlaylist = "master.m3u8"
+
+// maxTextObjectSize bounds how much of a playlist or WebVTT file is read (they are a few KB)
+const maxTextObjectSize = 1 << 20
+
+// renditionPattern matches the rendition names of the worker ladder, e.g. "720p"
+var renditionPattern = regexp.MustCompile(`^[0-9]{3,4}p$`)
+
+// hlsPrefix returns the storage prefix of the HLS set of a video
+func hlsPrefix(videoID int) string {
+	return fmt.Sprintf("processed/%d/hls/", videoID)
+}
+
+// GetHLSPlaylist returns an HLS playlist of a public processed video. With an empty rendition it
+// returns the master playlist unchanged, its relative variant URIs resolve to the rendition route.
+// Rendition playlists are returned with every segment rewritten to a signed storage URL
+func (s *Service) GetHLSPlaylist(ctx context.Context, videoID int, rendition string) ([]byte, error) {
+	if rendition != "" && !renditionPattern.MatchString(rendition) {
+		return nil, fmt.Errorf("invalid rendition")
+	}
+
+	video, err := s.repo.GetVideoByID(videoID, 0)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("video not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !video.PubliclyVisible() {
+		return nil, fmt.Errorf("video is not public")
+	}
+	if video.Status != StatusProcessed {
+		return nil, fmt.Errorf("video not processed yet")
+	}
+
+	key := hlsPrefix(videoID) + hlsMasterPlaylist
+	if rendition != "" {
+		key = hlsPrefix(videoID) + rendition + "/index.m3u8"
+	}
+
+	playlist, err := s.readTextObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if rendition == "" {
+		return playlist, nil
+	}
+
+	return rewriteMediaPlaylist(playlist, hlsPrefix(videoID)+rendition+"/", s.storageManager.GetSignedUrl)
+}
+
+// readTextObject downloads a small text file (playlist, WebVTT) from storage
+func (s *Service) readTextObject(ctx context.Context, key string) ([]byte, error) {
+	body, _, err := s.storageManager.Download(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	defer body.Close()
+
+	content, err := io.ReadAll(io.LimitReader(body, maxTextObjectSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return content, nil
+}
+
+// rewriteMediaPlaylist replaces the relative segment URIs of a media playlist with signed URLs of
+// the segments stored under prefix. Tags and comments are kept as they are
+func rewriteMediaPlaylist(playlist []byte, prefix string, sign func(fileName string) (string, error)) ([]byte, error) {
+	var out strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(string(playlist)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			if strings.Contains(line, "..") || strings.Contains(line, "://") || strings.HasPrefix(line, "/") {
+				return nil, fmt.Errorf("invalid segment URI in playlist: %s", line)
+			}
+
+			signedURL, err := sign(prefix + line)
+			if err != nil {
+				return nil, fmt.Errorf("failed to sign segment URL: %w", err)
+			}
+			line = signedURL
+		}
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse playlist: %w", err)
+	}
+
+	return []byte(out.String()), nil
+}
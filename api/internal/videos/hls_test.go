@@ -0,0 +1,40 @@
+package videos
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteMediaPlaylist(t *testing.T) {
+	playlist := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:6\n#EXTINF:6.000000,\nsegment_000.ts\n#EXTINF:4.000000,\nsegment_001.ts\n#EXT-X-ENDLIST\n"
+	sign := func(fileName string) (string, error) {
+		return "https://cdn.example.com/" + fileName + "?signature=abc", nil
+	}
+
+	rewritten, err := rewriteMediaPlaylist([]byte(playlist), "processed/7/hls/720p/", sign)
+	require.NoError(t, err)
+	assert.Equal(t, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:6\n#EXTINF:6.000000,\n"+
+		"https://cdn.example.com/processed/7/hls/720p/segment_000.ts?signature=abc\n#EXTINF:4.000000,\n"+
+		"https://cdn.example.com/processed/7/hls/720p/segment_001.ts?signature=abc\n#EXT-X-ENDLIST\n", string(rewritten))
+
+	// Segments outside the rendition directory are never signed
+	_, err = rewriteMediaPlaylist([]byte("#EXTM3U\n../../original/7.mp4\n"), "processed/7/hls/720p/", sign)
+	assert.Error(t, err)
+
+	_, err = rewriteMediaPlaylist([]byte("#EXTM3U\nsegment_000.ts\n"), "processed/7/hls/720p/", func(string) (string, error) {
+		return "", errors.New("no credentials")
+	})
+	assert.Error(t, err)
+}
+
+func TestRenditionPattern(t *testing.T) {
+	for _, rendition := range []string{"360p", "720p", "1080p"} {
+		assert.True(t, renditionPattern.MatchString(rendition), rendition)
+	}
+	for _, rendition := range []string{"", "720", "../720p", "720p/..", "99999p"} {
+		assert.False(t, renditionPattern.MatchString(rendition), rendition)
+	}
+}
@@ -0,0 +1,97 @@
+package videos
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"proyecto1/root/internal/http/dto"
+)
+
+// Layout of the images written by the worker under processed/{id}/
+const (
+	posterKey        = "poster.jpg"
+	thumbnailCount   = 5 // thumbnails/thumb_1.jpg ... thumb_5.jpg
+	previewSpriteKey = "preview/sprite.jpg"
+	previewVTTKey    = "preview/thumbnails.vtt"
+)
+
+// imagesPrefix returns the storage prefix of the files generated next to a processed video
+func imagesPrefix(videoID int) string {
+	return fmt.Sprintf("processed/%d/", videoID)
+}
+
+// ImageURLs returns the signed URLs of the video images, or empty URLs while they don't exist
+func (s *Service) ImageURLs(video *Video) dto.VideoImages {
+	var images dto.VideoImages
+	if !video.HasImages {
+		return images
+	}
+
+	sign := func(key string) string {
+		url, err := s.storageManager.GetSignedUrl(imagesPrefix(video.ID) + key)
+		if err != nil {
+			// Log error but continue with empty URL
+			fmt.Printf("Warning: Failed to generate image URL %s for video %d: %v\n", key, video.ID, err)
+			return ""
+		}
+		return url
+	}
+
+	images.PosterURL = sign(posterKey)
+	for i := 1; i <= thumbnailCount; i++ {
+		if url := sign(fmt.Sprintf("thumbnails/thumb_%d.jpg", i)); url != "" {
+			images.ThumbnailURLs = append(images.ThumbnailURLs, url)
+		}
+	}
+	images.PreviewSpriteURL = sign(previewSpriteKey)
+
+	// The WebVTT references the sprite, so it is served by the API with the sprite URL signed
+	if video.PubliclyVisible() {
+		images.PreviewVTTURL = fmt.Sprintf("/api/public/videos/%d/preview.vtt", video.ID)
+	}
+	return images
+}
+
+// GetPreviewVTT returns the scrubbing preview WebVTT of a public video with the sprite
+// references rewritten to a signed sprite URL
+func (s *Service) GetPreviewVTT(ctx context.Context, videoID int) ([]byte, error) {
+	video, err := s.repo.GetVideoByID(videoID, 0)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("video not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !video.PubliclyVisible() {
+		return nil, fmt.Errorf("video is not public")
+	}
+	if !video.HasImages {
+		return nil, fmt.Errorf("preview not generated")
+	}
+
+	vtt, err := s.readTextObject(ctx, imagesPrefix(videoID)+previewVTTKey)
+	if err != nil {
+		return nil, err
+	}
+
+	spriteURL, err := s.storageManager.GetSignedUrl(imagesPrefix(videoID) + previewSpriteKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign preview sprite URL: %w", err)
+	}
+	return rewriteVTTSprite(vtt, spriteURL), nil
+}
+
+// rewriteVTTSprite replaces the relative sprite reference of each cue ("sprite.jpg#xywh=...")
+// with the signed sprite URL, keeping the media fragment
+func rewriteVTTSprite(vtt []byte, spriteURL string) []byte {
+	lines := strings.Split(string(vtt), "\n")
+	for i, line := range lines {
+		if fragment, found := strings.CutPrefix(line, "sprite.jpg#"); found {
+			lines[i] = spriteURL + "#" + fragment
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,36 @@
+package videos
+
+import (
+	"testing"
+
+	"proyecto1/root/internal/ObjectStorage"
+	"proyecto1/root/internal/ObjectStorage/providers"
+	"proyecto1/root/internal/http/dto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriteVTTSprite(t *testing.T) {
+	vtt := "WEBVTT\n\n00:00:00.000 --> 00:00:01.000\nsprite.jpg#xywh=0,0,160,90\n"
+
+	rewritten := rewriteVTTSprite([]byte(vtt), "https://cdn.example.com/processed/7/preview/sprite.jpg?signature=abc")
+	assert.Equal(t, "WEBVTT\n\n00:00:00.000 --> 00:00:01.000\n"+
+		"https://cdn.example.com/processed/7/preview/sprite.jpg?signature=abc#xywh=0,0,160,90\n", string(rewritten))
+}
+
+func TestImageURLs(t *testing.T) {
+	service := &Service{storageManager: ObjectStorage.NewFileStorageManager(providers.NewMemoryProvider())}
+
+	// Nothing is signed until the worker generated the images
+	assert.Equal(t, dto.VideoImages{}, service.ImageURLs(&Video{ID: 7, IsPublic: true}))
+
+	images := service.ImageURLs(&Video{ID: 7, IsPublic: true, HasImages: true})
+	assert.Equal(t, "memory://processed/7/poster.jpg", images.PosterURL)
+	assert.Len(t, images.ThumbnailURLs, thumbnailCount)
+	assert.Equal(t, "memory://processed/7/thumbnails/thumb_1.jpg", images.ThumbnailURLs[0])
+	assert.Equal(t, "memory://processed/7/preview/sprite.jpg", images.PreviewSpriteURL)
+	assert.Equal(t, "/api/public/videos/7/preview.vtt", images.PreviewVTTURL)
+
+	// Private videos cannot use the public WebVTT route
+	assert.Empty(t, service.ImageURLs(&Video{ID: 8, HasImages: true}).PreviewVTTURL)
+}
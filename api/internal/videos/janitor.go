@@ -0,0 +1,34 @@
+package videos
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunPendingUploadJanitor removes the videos whose upload was never completed every interval
+// until the context is cancelled (see Service.ExpirePendingUploads). Every API instance runs
+// one; the cleanup is idempotent
+func RunPendingUploadJanitor(ctx context.Context, service *Service, grace time.Duration, interval time.Duration) {
+	log.Printf("Pending upload janitor started (every %v, %v after the upload URL expires)", interval, grace)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Pending upload janitor stopping due to context cancellation")
+			return
+		case <-ticker.C:
+		}
+
+		removed, err := service.ExpirePendingUploads(grace)
+		if err != nil {
+			log.Printf("Error removing expired pending uploads: %v", err)
+		}
+		if removed > 0 {
+			log.Printf("Removed %d videos whose upload was never completed", removed)
+		}
+	}
+}
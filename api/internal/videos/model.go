@@ -14,10 +14,70 @@ type Video struct {
 	ProcessedAt *time.Time `json:"processed_at,omitempty" db:"processed_at"`
 	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 	UserID      int        `json:"user_id" db:"user_id"`
+
+	// Processing lifecycle, updated by the worker
+	FailureReason        *string    `json:"failure_reason,omitempty" db:"failure_reason"`
+	Attempts             int        `json:"attempts" db:"attempts"`
+	ProcessingStartedAt  *time.Time `json:"processing_started_at,omitempty" db:"processing_started_at"`
+	ProcessingDurationMs *int64     `json:"processing_duration_ms,omitempty" db:"processing_duration_ms"`
+
+	// Poster, thumbnails and scrubbing preview generated by the worker under processed/{id}/
+	HasImages bool `json:"has_images" db:"has_images"`
+
+	// Upload profile the video was validated against, the worker processes it with the same one
+	Profile string `json:"profile" db:"profile"`
+
+	// Extension of the stored original (".mp4", ".mov", ".webm", ".mkv"), from the detected container
+	OriginalExt string `json:"original_ext" db:"original_ext"`
+
+	// Seconds into the source where the processed clip starts, chosen by the user. Nil lets the
+	// worker pick the best window
+	StartOffset *float64 `json:"start_offset,omitempty" db:"start_offset"`
+
+	// Set by a moderator: the video is left out of the public listing, playback and rankings
+	// until restored, whatever IsPublic says
+	HiddenAt     *time.Time `json:"hidden_at,omitempty" db:"hidden_at"`
+	HiddenReason *string    `json:"hidden_reason,omitempty" db:"hidden_reason"`
+}
+
+// PubliclyVisible reports whether anyone may watch the video: public and not hidden
+func (v *Video) PubliclyVisible() bool {
+	return v.IsPublic && v.HiddenAt == nil
+}
+
+// OriginalKey returns the storage key of the original upload
+func (v *Video) OriginalKey() string {
+	return generateS3Key(v.ID, v.OriginalExt)
 }
 
 // VideoStatus constants
 const (
-	StatusUploaded  = "uploaded"
-	StatusProcessed = "processed"
+	StatusPendingUpload = "pending_upload" // Record created, waiting for a direct-to-storage upload
+	StatusUploaded      = "uploaded"
+	StatusQueued        = "queued"     // Processing message published, waiting for a worker (or for a retry)
+	StatusProcessing    = "processing" // A worker is processing the video
+	StatusProcessed     = "processed"
+	StatusFailed        = "failed" // Processing gave up, see FailureReason
+)
+
+// Video metadata variants (video_metadata.variant)
+const (
+	MetadataSource    = "source"    // Uploaded file, probed by the API on upload
+	MetadataProcessed = "processed" // Worker output, probed by the worker after processing
 )
+
+// MetadataRecord is a row of video_metadata. Unknown values are stored as NULL and read as zero values
+type MetadataRecord struct {
+	VideoID  int
+	Variant  string
+	ProbedAt time.Time
+	Trim     *TrimWindow // Processed variant only
+	VideoMetadata
+}
+
+// TrimWindow is the part of the source kept in the processed video, recorded by the worker
+type TrimWindow struct {
+	Start     float64 // Seconds into the source
+	End       float64
+	Selection string // full (the source fits), auto (best scored window) or user (start_offset)
+}
@@ -1,7 +1,10 @@
 package videos
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"time"
 
 	"proyecto1/root/internal/database"
 )
@@ -14,19 +17,57 @@ func NewRepository(db *database.DB) *Repository {
 	return &Repository{db: db}
 }
 
+// videoColumns lists the columns read by scanVideo, in order
+const videoColumns = `id, title, status, is_public, uploaded_at, processed_at, deleted_at, user_id,
+		failure_reason, attempts, processing_started_at, processing_duration_ms, has_images, profile, original_ext, start_offset,
+		hidden_at, hidden_reason`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanVideo reads a row selected with videoColumns
+func scanVideo(row rowScanner, video *Video) error {
+	return row.Scan(
+		&video.ID, &video.Title, &video.Status, &video.IsPublic,
+		&video.UploadedAt, &video.ProcessedAt,
+		&video.DeletedAt, &video.UserID,
+		&video.FailureReason, &video.Attempts,
+		&video.ProcessingStartedAt, &video.ProcessingDurationMs,
+		&video.HasImages, &video.Profile, &video.OriginalExt, &video.StartOffset,
+		&video.HiddenAt, &video.HiddenReason,
+	)
+}
+
+// rowQuerier is implemented by both the database connection and transactions
+type rowQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// WithTx runs fn inside a database transaction
+func (r *Repository) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return r.db.WithTx(ctx, fn)
+}
+
 // CreateVideo creates a new video record in the database
 func (r *Repository) CreateVideo(video *Video) (*Video, error) {
+	return r.createVideo(r.db, video)
+}
+
+// CreateVideoTx creates a new video record inside the given transaction
+func (r *Repository) CreateVideoTx(tx *sql.Tx, video *Video) (*Video, error) {
+	return r.createVideo(tx, video)
+}
+
+func (r *Repository) createVideo(q rowQuerier, video *Video) (*Video, error) {
 	query := `
-		INSERT INTO videos (title, status, is_public, user_id)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, title, status, is_public, uploaded_at, processed_at, deleted_at, user_id`
+		INSERT INTO videos (title, status, is_public, user_id, profile, original_ext, start_offset)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + videoColumns
 
 	var createdVideo Video
-	err := r.db.QueryRow(query, video.Title, video.Status, video.IsPublic, video.UserID).Scan(
-		&createdVideo.ID, &createdVideo.Title, &createdVideo.Status, &createdVideo.IsPublic,
-		&createdVideo.UploadedAt, &createdVideo.ProcessedAt,
-		&createdVideo.DeletedAt, &createdVideo.UserID,
-	)
+	err := scanVideo(q.QueryRow(query, video.Title, video.Status, video.IsPublic, video.UserID, video.Profile, video.OriginalExt, video.StartOffset), &createdVideo)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create video: %w", err)
@@ -38,7 +79,7 @@ func (r *Repository) CreateVideo(video *Video) (*Video, error) {
 // GetVideoByID retrieves a video by its ID and user ID (ensures ownership)
 func (r *Repository) GetVideoByID(videoID int, userID int) (*Video, error) {
 	query := `
-        SELECT id, title, status, is_public, uploaded_at, processed_at, deleted_at, user_id
+        SELECT ` + videoColumns + `
         FROM videos 
         WHERE id = $1 AND deleted_at IS NULL`
 
@@ -50,11 +91,7 @@ func (r *Repository) GetVideoByID(videoID int, userID int) (*Video, error) {
 	}
 
 	var video Video
-	err := r.db.QueryRow(query, args...).Scan(
-		&video.ID, &video.Title, &video.Status, &video.IsPublic,
-		&video.UploadedAt, &video.ProcessedAt,
-		&video.DeletedAt, &video.UserID,
-	)
+	err := scanVideo(r.db.QueryRow(query, args...), &video)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get video by ID %d: %w", videoID, err)
 	}
@@ -65,7 +102,7 @@ func (r *Repository) GetVideoByID(videoID int, userID int) (*Video, error) {
 // GetVideosByUserID retrieves all videos for a specific user
 func (r *Repository) GetVideosByUserID(userID int) ([]*Video, error) {
 	query := `
-		SELECT id, title, status, is_public, uploaded_at, processed_at, deleted_at, user_id
+		SELECT ` + videoColumns + `
 		FROM videos 
 		WHERE user_id = $1 AND deleted_at IS NULL
 		ORDER BY uploaded_at DESC`
@@ -79,11 +116,7 @@ func (r *Repository) GetVideosByUserID(userID int) ([]*Video, error) {
 	var videos []*Video
 	for rows.Next() {
 		var video Video
-		err := rows.Scan(
-			&video.ID, &video.Title, &video.Status, &video.IsPublic,
-			&video.UploadedAt, &video.ProcessedAt,
-			&video.DeletedAt, &video.UserID,
-		)
+		err := scanVideo(rows, &video)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan video row: %w", err)
 		}
@@ -109,7 +142,7 @@ func (r *Repository) SoftDeleteVideo(videoID int, userID int) error {
 	var isPublic bool
 	err := r.db.QueryRow(checkQuery, videoID, userID).Scan(&isPublic)
 	if err != nil {
-		return fmt.Errorf("video not found or not owned by user")
+		return ErrVideoNotFound
 	}
 
 	// Check if video is public (cannot be deleted)
@@ -144,9 +177,9 @@ func (r *Repository) SoftDeleteVideo(videoID int, userID int) error {
 // GetPublicVideos retrieves all public videos that are not deleted
 func (r *Repository) GetPublicVideos() ([]*Video, error) {
 	query := `
-		SELECT id, title, status, is_public, uploaded_at, processed_at, deleted_at, user_id
+		SELECT ` + videoColumns + `
 		FROM videos 
-		WHERE is_public = true AND deleted_at IS NULL
+		WHERE is_public = true AND hidden_at IS NULL AND deleted_at IS NULL AND status <> 'pending_upload'
 		ORDER BY uploaded_at DESC`
 
 	rows, err := r.db.Query(query)
@@ -158,11 +191,7 @@ func (r *Repository) GetPublicVideos() ([]*Video, error) {
 	var videos []*Video
 	for rows.Next() {
 		var video Video
-		err := rows.Scan(
-			&video.ID, &video.Title, &video.Status, &video.IsPublic,
-			&video.UploadedAt, &video.ProcessedAt,
-			&video.DeletedAt, &video.UserID,
-		)
+		err := scanVideo(rows, &video)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan public video row: %w", err)
 		}
@@ -175,3 +204,179 @@ func (r *Repository) GetPublicVideos() ([]*Video, error) {
 
 	return videos, nil
 }
+
+// SetHidden hides a video with an optional reason, or restores it when hidden is false
+func (r *Repository) SetHidden(videoID int, hidden bool, reason *string) error {
+	query := `UPDATE videos SET hidden_at = NULL, hidden_reason = NULL WHERE id = $1 AND deleted_at IS NULL`
+	args := []any{videoID}
+	if hidden {
+		query = `
+			UPDATE videos SET hidden_at = COALESCE(hidden_at, NOW()), hidden_reason = $2
+			WHERE id = $1 AND deleted_at IS NULL`
+		args = append(args, reason)
+	}
+
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update video visibility: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrVideoMissing
+	}
+	return nil
+}
+
+// MarkUploadedTx moves a video waiting for a direct upload to the uploaded status and returns
+// the new upload time. The status condition guarantees only one completion request wins
+func (r *Repository) MarkUploadedTx(tx *sql.Tx, videoID int) (time.Time, error) {
+	query := `
+		UPDATE videos
+		SET status = $1, uploaded_at = NOW(), upload_expires_at = NULL
+		WHERE id = $2 AND status = $3 AND deleted_at IS NULL
+		RETURNING uploaded_at`
+
+	var uploadedAt time.Time
+	err := tx.QueryRow(query, StatusUploaded, videoID, StatusPendingUpload).Scan(&uploadedAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, ErrUploadCompleted
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to mark video as uploaded: %w", err)
+	}
+
+	return uploadedAt, nil
+}
+
+// DeletePendingVideo removes a video whose file was never stored, with its metadata. Videos that
+// left pending_upload are kept
+func (r *Repository) DeletePendingVideo(videoID int) error {
+	query := `DELETE FROM videos WHERE id = $1 AND status = $2`
+
+	if _, err := r.db.Exec(query, videoID, StatusPendingUpload); err != nil {
+		return fmt.Errorf("failed to delete pending video: %w", err)
+	}
+	return nil
+}
+
+// SetUploadExpiry records when the presigned upload URL of a pending video expires
+func (r *Repository) SetUploadExpiry(videoID int, expiresAt time.Time) error {
+	query := `UPDATE videos SET upload_expires_at = $2 WHERE id = $1 AND status = $3`
+
+	if _, err := r.db.Exec(query, videoID, expiresAt, StatusPendingUpload); err != nil {
+		return fmt.Errorf("failed to set upload expiry: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredPendingVideos removes the videos still waiting for their file whose upload URL
+// expired before cutoff (their creation time without a URL) and returns them, so their stored
+// files can be removed. The status condition leaves videos completed meanwhile untouched
+func (r *Repository) DeleteExpiredPendingVideos(cutoff time.Time) ([]*Video, error) {
+	query := `
+		DELETE FROM videos
+		WHERE status = $1 AND COALESCE(upload_expires_at, uploaded_at) < $2
+		RETURNING id, original_ext`
+
+	rows, err := r.db.Query(query, StatusPendingUpload, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete expired pending videos: %w", err)
+	}
+	defer rows.Close()
+
+	var expired []*Video
+	for rows.Next() {
+		video := &Video{Status: StatusPendingUpload}
+		if err := rows.Scan(&video.ID, &video.OriginalExt); err != nil {
+			return nil, fmt.Errorf("failed to scan expired pending video: %w", err)
+		}
+		expired = append(expired, video)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating expired pending video rows: %w", err)
+	}
+
+	return expired, nil
+}
+
+// SaveMetadataTx stores the probed metadata of a video variant, replacing a previous probe
+func (r *Repository) SaveMetadataTx(tx *sql.Tx, videoID int, variant string, metadata *VideoMetadata) error {
+	query := `
+		INSERT INTO video_metadata (video_id, variant, container, video_codec, audio_codec, has_audio,
+			width, height, duration_seconds, bitrate, fps, rotation, size_bytes, checksum_sha256, probed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NOW())
+		ON CONFLICT (video_id, variant) DO UPDATE SET
+			container = EXCLUDED.container, video_codec = EXCLUDED.video_codec,
+			audio_codec = EXCLUDED.audio_codec, has_audio = EXCLUDED.has_audio,
+			width = EXCLUDED.width, height = EXCLUDED.height,
+			duration_seconds = EXCLUDED.duration_seconds, bitrate = EXCLUDED.bitrate,
+			fps = EXCLUDED.fps, rotation = EXCLUDED.rotation, size_bytes = EXCLUDED.size_bytes,
+			checksum_sha256 = EXCLUDED.checksum_sha256, probed_at = EXCLUDED.probed_at`
+
+	_, err := tx.Exec(query, videoID, variant, metadata.Container, metadata.VideoCodec,
+		sql.NullString{String: metadata.AudioCodec, Valid: metadata.AudioCodec != ""}, metadata.HasAudio,
+		metadata.Width, metadata.Height, metadata.Duration,
+		sql.NullInt64{Int64: metadata.Bitrate, Valid: metadata.Bitrate > 0},
+		sql.NullFloat64{Float64: metadata.FPS, Valid: metadata.FPS > 0},
+		metadata.Rotation, metadata.Size,
+		sql.NullString{String: metadata.Checksum, Valid: metadata.Checksum != ""})
+	if err != nil {
+		return fmt.Errorf("failed to save video metadata: %w", err)
+	}
+	return nil
+}
+
+// GetMetadata retrieves the probed metadata rows of a video
+func (r *Repository) GetMetadata(videoID int) ([]*MetadataRecord, error) {
+	query := `
+		SELECT video_id, variant, container, video_codec, audio_codec, has_audio, width, height,
+			duration_seconds, bitrate, fps, rotation, size_bytes, checksum_sha256, probed_at,
+			trim_start_seconds, trim_end_seconds, trim_selection
+		FROM video_metadata
+		WHERE video_id = $1
+		ORDER BY variant DESC`
+
+	rows, err := r.db.Query(query, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video metadata: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*MetadataRecord
+	for rows.Next() {
+		var (
+			record     MetadataRecord
+			audioCodec sql.NullString
+			bitrate    sql.NullInt64
+			fps        sql.NullFloat64
+			checksum   sql.NullString
+			trimStart  sql.NullFloat64
+			trimEnd    sql.NullFloat64
+			selection  sql.NullString
+		)
+		err := rows.Scan(&record.VideoID, &record.Variant, &record.Container, &record.VideoCodec,
+			&audioCodec, &record.HasAudio, &record.Width, &record.Height, &record.Duration,
+			&bitrate, &fps, &record.Rotation, &record.Size, &checksum, &record.ProbedAt,
+			&trimStart, &trimEnd, &selection)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan video metadata row: %w", err)
+		}
+		record.AudioCodec = audioCodec.String
+		record.Bitrate = bitrate.Int64
+		record.FPS = fps.Float64
+		record.Checksum = checksum.String
+		if selection.Valid {
+			record.Trim = &TrimWindow{Start: trimStart.Float64, End: trimEnd.Float64, Selection: selection.String}
+		}
+		records = append(records, &record)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating video metadata rows: %w", err)
+	}
+
+	return records, nil
+}
@@ -2,31 +2,115 @@ package videos
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"mime/multipart"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"proyecto1/root/internal/ObjectStorage"
+	"proyecto1/root/internal/ObjectStorage/providers"
+	"proyecto1/root/internal/config"
 	"proyecto1/root/internal/http/dto"
 	"proyecto1/root/internal/messaging"
+	"proyecto1/root/internal/outbox"
+)
+
+var (
+	// ErrValidationFailed is returned when an upload does not meet the rules of its profile.
+	// Videos rejected with it never pass on a retry, so their files are discarded
+	ErrValidationFailed = errors.New("video validation failed")
+	// ErrVideoNotFound is returned when the video does not exist, was deleted or belongs to another user
+	ErrVideoNotFound = errors.New("video not found or not owned by user")
+	// ErrUploadCompleted is returned when completing an upload that already left pending_upload
+	ErrUploadCompleted = errors.New("video upload already completed")
+	// ErrUploadMissing is returned when completing an upload whose file is not in storage yet
+	ErrUploadMissing = errors.New("video file has not been uploaded yet")
+	// ErrVideoMissing is returned by the moderation actions when the video does not exist or was deleted
+	ErrVideoMissing = errors.New("video not found")
 )
 
 type Service struct {
 	repo           *Repository
 	validator      *FFProbeValidator
 	storageManager *ObjectStorage.FileStorageManager
-	messageQueue   messaging.MessageQueue
+	outbox         *outbox.Repository
+	profiles       map[string]ValidationRules // Upload validation rules by profile name
+	defaultProfile string                     // Profile used when an upload does not name one
 }
 
-func NewService(repo *Repository, storageManager *ObjectStorage.FileStorageManager, messageQueue messaging.MessageQueue) *Service {
+func NewService(repo *Repository, storageManager *ObjectStorage.FileStorageManager, outboxRepo *outbox.Repository, profiles config.ProfilesConfig) *Service {
 	validator := NewFFProbeValidator("/tmp") // Use /tmp for temp files in container
+
+	rules := make(map[string]ValidationRules, len(profiles.Profiles))
+	for name, profile := range profiles.Profiles {
+		rules[name] = NewValidationRules(profile)
+	}
+
 	return &Service{
 		repo:           repo,
 		validator:      validator,
 		storageManager: storageManager,
-		messageQueue:   messageQueue,
+		outbox:         outboxRepo,
+		profiles:       rules,
+		defaultProfile: profiles.Default,
+	}
+}
+
+// ResolveProfile returns the profile name and validation rules for an upload. An empty name
+// selects the default profile
+func (s *Service) ResolveProfile(name string) (string, ValidationRules, error) {
+	if name == "" {
+		name = s.defaultProfile
+	}
+
+	rules, ok := s.profiles[name]
+	if !ok {
+		return "", ValidationRules{}, fmt.Errorf("%w: unknown upload profile %q", ErrValidationFailed, name)
+	}
+	return name, rules, nil
+}
+
+// MaxUploadSize returns the largest file size accepted by any profile
+func (s *Service) MaxUploadSize() int64 {
+	var maxSize int64
+	for _, rules := range s.profiles {
+		maxSize = max(maxSize, rules.MaxSizeBytes)
+	}
+	return maxSize
+}
+
+// GetUploadRules returns the limits of every profile so clients can check files before uploading
+func (s *Service) GetUploadRules() *dto.UploadRulesResponse {
+	names := make([]string, 0, len(s.profiles))
+	for name := range s.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	response := &dto.UploadRulesResponse{
+		DefaultProfile: s.defaultProfile,
+		Profiles:       make([]dto.UploadProfileResponse, 0, len(names)),
 	}
+	for _, name := range names {
+		rules := s.profiles[name]
+		response.Profiles = append(response.Profiles, dto.UploadProfileResponse{
+			Name:               name,
+			MaxSizeBytes:       rules.MaxSizeBytes,
+			MinDurationSeconds: rules.MinDuration,
+			MaxDurationSeconds: rules.MaxDuration,
+			MinWidth:           rules.MinWidth,
+			MinHeight:          rules.MinHeight,
+			AllowedContainers:  rules.AllowedContainers,
+			AllowedVideoCodecs: rules.AllowedVideoCodecs,
+		})
+	}
+	return response
 }
 
 // VideoMetadata represents video file metadata
@@ -36,25 +120,108 @@ type VideoMetadata struct {
 	Height   int
 	Size     int64 // file size in bytes
 	Format   string
+
+	// Probe details kept in video_metadata for support
+	Container  string  // ffprobe format_name, e.g. "mov,mp4,m4a,3gp,3g2,mj2"
+	VideoCodec string  // e.g. "h264"
+	AudioCodec string  // Empty without audio
+	HasAudio   bool    // Whether the file has an audio stream
+	Bitrate    int64   // Overall bitrate in bits per second, 0 if unknown
+	FPS        float64 // Average frame rate, 0 if unknown
+	Rotation   int     // Clockwise display rotation (0, 90, 180, 270)
+	Checksum   string  // SHA-256 hex of the file, empty if not computed
+}
+
+// DisplaySize returns the size the video is shown at, with the rotation applied
+func (m *VideoMetadata) DisplaySize() (int, int) {
+	if m.Rotation == 90 || m.Rotation == 270 {
+		return m.Height, m.Width
+	}
+	return m.Width, m.Height
 }
 
 // UploadVideo handles the business logic for video upload and validation
-func (s *Service) UploadVideo(file *multipart.FileHeader, title string, isPublic bool, userID int) (*dto.VideoUploadResponse, error) {
+func (s *Service) UploadVideo(file *multipart.FileHeader, title string, isPublic bool, userID int, profile string, startOffset *float64) (*dto.VideoUploadResponse, error) {
 	// Get validation rules
-	rules := DefaultValidationRules()
+	profile, rules, err := s.ResolveProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckStartOffset(startOffset, rules); err != nil {
+		return nil, err
+	}
 
 	// Perform complete video validation using FFprobe
-	_, err := s.validator.ValidateVideo(file, rules)
+	metadata, err := s.validator.ValidateVideo(file, rules)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+
+	return s.createAndEnqueueVideo(title, isPublic, userID, profile, startOffset, metadata, func(video *Video) (string, error) {
+		return s.uploadVideoToStorage(file, video)
+	})
+}
+
+// UploadVideoFromFile handles the same validation, persistence and enqueueing as UploadVideo
+// for a video that is already on local disk (e.g. a completed resumable upload)
+func (s *Service) UploadVideoFromFile(path string, title string, isPublic bool, userID int, profile string, startOffset *float64) (*dto.VideoUploadResponse, error) {
+	// Get validation rules
+	profile, rules, err := s.ResolveProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckStartOffset(startOffset, rules); err != nil {
+		return nil, err
+	}
+
+	// Perform complete video validation using FFprobe directly on the file
+	metadata, err := s.validator.ValidateFile(path, rules)
 	if err != nil {
-		return nil, fmt.Errorf("video validation failed: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
 	}
 
-	// Create video record in database with metadata
+	return s.createAndEnqueueVideo(title, isPublic, userID, profile, startOffset, metadata, func(video *Video) (string, error) {
+		return s.uploadLocalFileToStorage(path, video)
+	})
+}
+
+// CreatePresignedUpload creates a video record waiting for its file and returns a presigned
+// request the client can use to upload the file directly to storage
+func (s *Service) CreatePresignedUpload(title string, isPublic bool, userID int, filename string, contentType string, size int64, profile string, startOffset *float64) (*dto.PresignedUploadResponse, error) {
+	// Get validation rules
+	profile, rules, err := s.ResolveProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckStartOffset(startOffset, rules); err != nil {
+		return nil, err
+	}
+
+	// Validate what we can before the file exists (extension and declared size). The content
+	// is checked against the container of the extension when the upload is completed
+	container, err := s.validator.validateDeclaredFile(filename, size, rules)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+
+	if contentType == "" {
+		contentType = container.ContentType
+	}
+	if contentType != container.ContentType {
+		return nil, fmt.Errorf("%w: unsupported content type %s for %s files (expected %s)",
+			ErrValidationFailed, contentType, container.Extension, container.ContentType)
+	}
+
+	// Create video record in database, it stays pending until the upload is completed
 	video := &Video{
 		Title:    title,
-		Status:   StatusUploaded, // Set initial status
-		IsPublic: isPublic,       // Set visibility
+		Status:   StatusPendingUpload,
+		IsPublic: isPublic,
 		UserID:   userID,
+		Profile:  profile,
+
+		OriginalExt: container.Extension,
+		StartOffset: startOffset,
 	}
 
 	createdVideo, err := s.repo.CreateVideo(video)
@@ -62,54 +229,232 @@ func (s *Service) UploadVideo(file *multipart.FileHeader, title string, isPublic
 		return nil, fmt.Errorf("failed to save video record: %w", err)
 	}
 
+	// The presigned request enforces the size limit and content type on the storage side
+	s3Key := createdVideo.OriginalKey()
+	upload, err := s.storageManager.GetPresignedUpload(s3Key, contentType, rules.MaxSizeBytes)
+	if err == nil {
+		// The video is deleted when it is still pending a while after the URL expired
+		err = s.repo.SetUploadExpiry(createdVideo.ID, upload.ExpiresAt)
+	}
+	if err != nil {
+		if deleteErr := s.repo.DeletePendingVideo(createdVideo.ID); deleteErr != nil {
+			fmt.Printf("Warning: Failed to delete video %d without upload URL: %v\n", createdVideo.ID, deleteErr)
+		}
+		return nil, fmt.Errorf("failed to generate upload URL: %w", err)
+	}
+
+	return &dto.PresignedUploadResponse{
+		VideoID:     createdVideo.ID,
+		Status:      createdVideo.Status,
+		UploadURL:   upload.URL,
+		Method:      upload.Method,
+		Fields:      upload.Fields,
+		ExpiresAt:   upload.ExpiresAt,
+		CompleteURL: fmt.Sprintf("/api/videos/%d/complete", createdVideo.ID),
+	}, nil
+}
+
+// CompletePresignedUpload validates a file uploaded directly to storage and enqueues it for
+// processing. Files that fail validation are removed from storage
+func (s *Service) CompletePresignedUpload(videoID int, userID int) (*dto.VideoUploadResponse, error) {
+	// Get video from database with user ownership validation
+	video, err := s.repo.GetVideoByID(videoID, userID)
+	if err != nil {
+		return nil, ErrVideoNotFound
+	}
+
+	if video.Status != StatusPendingUpload {
+		return nil, ErrUploadCompleted
+	}
+
+	// Check the object actually reached storage
+	s3Key := video.OriginalKey()
+	info, err := s.storageManager.GetFileInfo(s3Key)
+	if err != nil {
+		if errors.Is(err, providers.ErrFileNotFound) {
+			return nil, ErrUploadMissing
+		}
+		return nil, fmt.Errorf("failed to check uploaded file: %w", err)
+	}
+
+	// Run the same FFprobe validation as regular uploads against the stored object
+	url, err := s.storageManager.GetSignedUrl(s3Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate validation URL: %w", err)
+	}
+
+	// The upload is validated against the profile chosen when it was created
+	_, rules, err := s.ResolveProfile(video.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	// The container is detected from the first bytes of the object
+	header, err := s.readStoredHeader(s3Key)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := s.validateStoredUpload(video, url, header, info.Size, rules)
+	if err != nil {
+		if deleteErr := s.storageManager.DeleteFile(s3Key); deleteErr != nil {
+			fmt.Printf("Warning: Failed to delete rejected upload for video %d (S3 key: %s): %v\n", videoID, s3Key, deleteErr)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+
+	// Only one completion request can move the video out of pending_upload. The processing
+	// message is stored in the outbox in the same transaction and published by the relay
+	var uploadedAt time.Time
+	err = s.repo.WithTx(context.Background(), func(tx *sql.Tx) error {
+		var err error
+		uploadedAt, err = s.repo.MarkUploadedTx(tx, videoID)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.SaveMetadataTx(tx, videoID, MetadataSource, metadata); err != nil {
+			return err
+		}
+		return s.enqueueVideoProcessingMessage(tx, videoID, s3Key)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.VideoUploadResponse{
+		ID:         video.ID,
+		Title:      video.Title,
+		Status:     StatusUploaded,
+		IsPublic:   video.IsPublic,
+		UploadedAt: uploadedAt,
+		UserID:     video.UserID,
+		S3Key:      s3Key,
+		Profile:    video.Profile,
+	}, nil
+}
+
+// createAndEnqueueVideo creates the video record with the probed source metadata, stores the
+// file using the given upload function and enqueues the processing message in the outbox. No
+// transaction is open during the upload: the record is created as pending_upload, and once the
+// file is stored a second transaction marks it uploaded together with its processing message
+func (s *Service) createAndEnqueueVideo(title string, isPublic bool, userID int, profile string, startOffset *float64, metadata *VideoMetadata, upload func(video *Video) (string, error)) (*dto.VideoUploadResponse, error) {
+	if startOffset != nil && *startOffset >= metadata.Duration {
+		return nil, fmt.Errorf("%w: start_offset %.1f is past the end of the video (%.1f seconds)",
+			ErrValidationFailed, *startOffset, metadata.Duration)
+	}
+
+	// The original is stored with the extension of the container detected from its content
+	container, ok := ContainerByName(metadata.Format)
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported container %s", ErrValidationFailed, metadata.Format)
+	}
+
+	// Create video record in database with metadata, it stays pending until the file is stored
+	video := &Video{
+		Title:    title,
+		Status:   StatusPendingUpload,
+		IsPublic: isPublic, // Set visibility
+		UserID:   userID,
+		Profile:  profile, // The worker processes the video with the same profile
+
+		OriginalExt: container.Extension,
+		StartOffset: startOffset,
+	}
+
+	var createdVideo *Video
+	err := s.repo.WithTx(context.Background(), func(tx *sql.Tx) error {
+		var err error
+		createdVideo, err = s.repo.CreateVideoTx(tx, video)
+		if err != nil {
+			return fmt.Errorf("failed to save video record: %w", err)
+		}
+		return s.repo.SaveMetadataTx(tx, createdVideo.ID, MetadataSource, metadata)
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	// Upload file to S3 using ObjectStorage
-	s3Key, err := s.uploadVideoToStorage(file, createdVideo.ID)
+	s3Key, err := upload(createdVideo)
 	if err != nil {
+		if deleteErr := s.repo.DeletePendingVideo(createdVideo.ID); deleteErr != nil {
+			fmt.Printf("Warning: Failed to delete video %d after a failed upload: %v\n", createdVideo.ID, deleteErr)
+		}
 		return nil, fmt.Errorf("failed to upload video to storage: %w", err)
 	}
 
-	// Send video processing message to message queue
-	err = s.sendVideoProcessingMessage(s3Key)
+	// If this transaction fails the record stays in pending_upload until ExpirePendingUploads
+	// removes it with the stored file
+	var uploadedAt time.Time
+	err = s.repo.WithTx(context.Background(), func(tx *sql.Tx) error {
+		var err error
+		uploadedAt, err = s.repo.MarkUploadedTx(tx, createdVideo.ID)
+		if err != nil {
+			return err
+		}
+		return s.enqueueVideoProcessingMessage(tx, createdVideo.ID, s3Key)
+	})
 	if err != nil {
-		// Log the error but don't fail the upload - the video is already saved
-		// In production, you might want to implement retry logic or dead letter queue
-		fmt.Printf("Warning: Failed to send message for video %d (S3 key: %s): %v\n", createdVideo.ID, s3Key, err)
+		return nil, err
 	}
 
 	// Return success response with S3 information
-	response := &dto.VideoUploadResponse{
+	return &dto.VideoUploadResponse{
 		ID:         createdVideo.ID,
 		Title:      createdVideo.Title,
-		Status:     createdVideo.Status,
+		Status:     StatusUploaded,
 		IsPublic:   createdVideo.IsPublic,
-		UploadedAt: createdVideo.UploadedAt,
+		UploadedAt: uploadedAt,
 		UserID:     createdVideo.UserID,
 		S3Key:      s3Key, // Include S3 key in response
+		Profile:    createdVideo.Profile,
+	}, nil
+}
+
+// ExpirePendingUploads deletes the videos that are still waiting for their file grace after
+// their presigned upload URL expired, with whatever was stored for them, and returns how many
+func (s *Service) ExpirePendingUploads(grace time.Duration) (int, error) {
+	expired, err := s.repo.DeleteExpiredPendingVideos(time.Now().Add(-grace))
+	if err != nil {
+		return 0, err
 	}
 
-	return response, nil
+	for _, video := range expired {
+		if err := s.storageManager.DeleteFile(video.OriginalKey()); err != nil {
+			fmt.Printf("Warning: Failed to delete the upload of expired video %d (S3 key: %s): %v\n", video.ID, video.OriginalKey(), err)
+		}
+	}
+	return len(expired), nil
 }
 
-// uploadVideoToStorage uploads a video file to S3 and returns the S3 key
-func (s *Service) uploadVideoToStorage(file *multipart.FileHeader, videoID int) (string, error) {
-	// Open the uploaded file
+// uploadVideoToStorage streams a video file to S3 and returns the S3 key
+func (s *Service) uploadVideoToStorage(file *multipart.FileHeader, video *Video) (string, error) {
+	// Open the uploaded file (multipart keeps large files on disk)
 	src, err := file.Open()
 	if err != nil {
 		return "", fmt.Errorf("failed to open uploaded file: %w", err)
 	}
 	defer src.Close()
 
-	// Read file content into buffer
-	fileBuffer, err := io.ReadAll(src)
+	// Generate simple S3 key based on video ID and container
+	s3Key := video.OriginalKey()
+
+	// Upload to S3 using ObjectStorage
+	err = s.storageManager.Upload(context.Background(), s3Key, src, file.Size, originalContentType(video))
 	if err != nil {
-		return "", fmt.Errorf("failed to read file content: %w", err)
+		return "", fmt.Errorf("failed to upload file to S3: %w", err)
 	}
 
-	// Generate simple S3 key based on video ID
-	s3Key := s.generateS3Key(videoID)
+	return s3Key, nil
+}
+
+// uploadLocalFileToStorage streams a video file from local disk to S3 and returns the S3 key
+func (s *Service) uploadLocalFileToStorage(path string, video *Video) (string, error) {
+	// Generate simple S3 key based on video ID and container
+	s3Key := video.OriginalKey()
 
 	// Upload to S3 using ObjectStorage
-	err = s.storageManager.UploadFile(fileBuffer, s3Key)
+	err := s.storageManager.UploadFromFile(context.Background(), s3Key, path, originalContentType(video))
 	if err != nil {
 		return "", fmt.Errorf("failed to upload file to S3: %w", err)
 	}
@@ -117,10 +462,73 @@ func (s *Service) uploadVideoToStorage(file *multipart.FileHeader, videoID int)
 	return s3Key, nil
 }
 
-// generateS3Key creates an S3 key with "original/" prefix based on video ID
-func (s *Service) generateS3Key(videoID int) string {
+// ParseStartOffset parses the optional start_offset of an upload (seconds into the video where
+// the processed clip starts). An empty value lets the worker pick the best window
+func ParseStartOffset(value string) (*float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	offset, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(offset) || math.IsInf(offset, 0) {
+		return nil, fmt.Errorf("start_offset must be a number of seconds")
+	}
+	return &offset, nil
+}
+
+// CheckStartOffset rejects start offsets no upload of the profile could have. Offsets leaving
+// less than a full clip are moved back by the worker
+func CheckStartOffset(startOffset *float64, rules ValidationRules) error {
+	if startOffset != nil && (*startOffset < 0 || *startOffset >= rules.MaxDuration) {
+		return fmt.Errorf("%w: start_offset must be between 0 and %.0f seconds", ErrValidationFailed, rules.MaxDuration)
+	}
+	return nil
+}
+
+// readStoredHeader reads the first bytes of a stored object for container detection
+func (s *Service) readStoredHeader(s3Key string) ([]byte, error) {
+	body, err := s.storageManager.DownloadRange(context.Background(), s3Key, 0, sniffLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+	defer body.Close()
+
+	header, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+	return header, nil
+}
+
+// validateStoredUpload validates a direct-to-storage upload. The container detected from the
+// content must be the one of the extension declared when the upload was created
+func (s *Service) validateStoredUpload(video *Video, url string, header []byte, size int64, rules ValidationRules) (*VideoMetadata, error) {
+	metadata, err := s.validator.ValidateRemote(url, header, size, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	if container, ok := ContainerByName(metadata.Format); !ok || container.Extension != video.OriginalExt {
+		return nil, fmt.Errorf("quick validation failed: %s content does not match the %s extension", metadata.Format, video.OriginalExt)
+	}
+
+	return metadata, nil
+}
+
+// originalContentType returns the content type of the stored original
+func originalContentType(video *Video) string {
+	if container, ok := containerForExtension(video.OriginalExt); ok {
+		return container.ContentType
+	}
+	return "application/octet-stream"
+}
+
+// generateS3Key creates an S3 key with "original/" prefix based on video ID and the extension
+// of the uploaded container
+func generateS3Key(videoID int, ext string) string {
 	// Use "original/" prefix to keep the original file
-	return fmt.Sprintf("original/%d.mp4", videoID)
+	return fmt.Sprintf("original/%d%s", videoID, ext)
 }
 
 // GetVideo retrieves video details and generates presigned URLs (with user validation)
@@ -132,7 +540,7 @@ func (s *Service) GetVideo(videoID int, userID int) (*Video, string, string, err
 	}
 
 	// Generate presigned URLs for original and processed videos
-	originalS3Key := fmt.Sprintf("original/%d.mp4", videoID)
+	originalS3Key := video.OriginalKey()
 	processedS3Key := fmt.Sprintf("processed/%d.mp4", videoID)
 
 	// Get presigned URL for original video
@@ -162,7 +570,7 @@ func (s *Service) GetUserVideos(userID int) ([]*dto.VideoResponse, error) {
 	var responses []*dto.VideoResponse
 	for _, video := range videos {
 		// Generate presigned URLs for original and processed videos
-		originalS3Key := fmt.Sprintf("original/%d.mp4", video.ID)
+		originalS3Key := video.OriginalKey()
 		processedS3Key := fmt.Sprintf("processed/%d.mp4", video.ID)
 
 		// Get presigned URL for original video
@@ -192,6 +600,16 @@ func (s *Service) GetUserVideos(userID int) ([]*dto.VideoResponse, error) {
 			OriginalURL:  originalURL,
 			ProcessedURL: processedURL,
 			Votes:        0, // Default votes value
+
+			FailureReason:        video.FailureReason,
+			Attempts:             video.Attempts,
+			ProcessingStartedAt:  video.ProcessingStartedAt,
+			ProcessingDurationMs: video.ProcessingDurationMs,
+
+			HiddenAt:     video.HiddenAt,
+			HiddenReason: video.HiddenReason,
+
+			VideoImages: s.ImageURLs(video),
 		}
 
 		responses = append(responses, response)
@@ -200,6 +618,54 @@ func (s *Service) GetUserVideos(userID int) ([]*dto.VideoResponse, error) {
 	return responses, nil
 }
 
+// GetVideoMetadata returns the probed metadata of the source and processed files of a video
+// owned by the user
+func (s *Service) GetVideoMetadata(videoID int, userID int) (*dto.VideoMetadataResponse, error) {
+	if _, err := s.repo.GetVideoByID(videoID, userID); err != nil {
+		return nil, ErrVideoNotFound
+	}
+
+	records, err := s.repo.GetMetadata(videoID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.VideoMetadataResponse{VideoID: videoID}
+	for _, record := range records {
+		media := &dto.MediaMetadataResponse{
+			Container:       record.Container,
+			VideoCodec:      record.VideoCodec,
+			AudioCodec:      record.AudioCodec,
+			HasAudio:        record.HasAudio,
+			Width:           record.Width,
+			Height:          record.Height,
+			DurationSeconds: record.Duration,
+			Bitrate:         record.Bitrate,
+			FPS:             record.FPS,
+			Rotation:        record.Rotation,
+			SizeBytes:       record.Size,
+			ChecksumSHA256:  record.Checksum,
+			ProbedAt:        record.ProbedAt,
+		}
+		if record.Trim != nil {
+			media.Trim = &dto.TrimWindowResponse{
+				StartSeconds: record.Trim.Start,
+				EndSeconds:   record.Trim.End,
+				Selection:    record.Trim.Selection,
+			}
+		}
+
+		switch record.Variant {
+		case MetadataSource:
+			response.Source = media
+		case MetadataProcessed:
+			response.Processed = media
+		}
+	}
+
+	return response, nil
+}
+
 // DeleteVideo performs soft delete on a video (only updates deleted_at, doesn't touch S3)
 // Only allows deletion of private videos (is_public = false)
 func (s *Service) DeleteVideo(videoID int, userID int) error {
@@ -225,18 +691,16 @@ func (s *Service) GetVideoDownloadURL(s3Key string) (string, error) {
 	return url, nil
 }
 
-// sendVideoProcessingMessage sends a message to message queue for video processing
-func (s *Service) sendVideoProcessingMessage(s3Key string) error {
-	// Create simple video processing message with just the S3 key
+// enqueueVideoProcessingMessage stores the processing message in the outbox inside the given transaction
+func (s *Service) enqueueVideoProcessingMessage(tx *sql.Tx, videoID int, s3Key string) error {
 	message := &messaging.VideoProcessingMessage{
 		S3Key: s3Key,
 	}
 
-	// Send message to message queue with context timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	return s.messageQueue.SendMessage(ctx, message)
+	if err := s.outbox.Enqueue(tx, videoID, message); err != nil {
+		return fmt.Errorf("failed to enqueue processing message: %w", err)
+	}
+	return nil
 }
 
 // CheckFFProbeInstallation verifies that FFprobe is properly installed
@@ -276,6 +740,8 @@ func (s *Service) GetPublicVideos() ([]*dto.PublicVideoResponse, error) {
 			ProcessedAt:  video.ProcessedAt,
 			ProcessedURL: processedURL,
 			Votes:        0, // Default votes value
+
+			VideoImages: s.ImageURLs(video),
 		}
 
 		responses = append(responses, response)
@@ -284,43 +750,46 @@ func (s *Service) GetPublicVideos() ([]*dto.PublicVideoResponse, error) {
 	return responses, nil
 }
 
-func (s *Service) GetVideoForPublicStream(videoID int) (*dto.PublicVideoResponse, string, string, error) {
-	// Get video (userID = 0 para ignorar validación de usuario)
-	video, err := s.repo.GetVideoByID(videoID, 0)
-	if err != nil {
-		return nil, "", "", err
+// SetHidden hides a video from everyone but its owner, with an optional reason shown to the
+// owner, or restores it when hidden is false
+func (s *Service) SetHidden(videoID int, hidden bool, reason string) error {
+	var hiddenReason *string
+	if reason = strings.TrimSpace(reason); reason != "" {
+		hiddenReason = &reason
 	}
 
-	// Check if video is public
-	if !video.IsPublic {
-		return nil, "", "", fmt.Errorf("video is not public")
+	if err := s.repo.SetHidden(videoID, hidden, hiddenReason); err != nil {
+		if errors.Is(err, ErrVideoMissing) {
+			return err
+		}
+		return fmt.Errorf("failed to update video visibility: %w", err)
 	}
+	return nil
+}
 
-	// Generate presigned URLs
-	originalS3Key := fmt.Sprintf("original/%d.mp4", videoID)
-	processedS3Key := fmt.Sprintf("processed/%d.mp4", videoID)
-
-	originalURL, err := s.storageManager.GetSignedUrl(originalS3Key)
-	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to generate original video URL: %w", err)
+// GetPublicStreamFile returns the storage key and attributes of the processed file of a public
+// video, so the caller can serve it with ranged reads
+func (s *Service) GetPublicStreamFile(videoID int) (string, *providers.FileInfo, error) {
+	video, err := s.repo.GetVideoByID(videoID, 0)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil, fmt.Errorf("video not found")
 	}
-
-	processedURL, err := s.storageManager.GetSignedUrl(processedS3Key)
 	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to generate processed video URL: %w", err)
+		return "", nil, err
+	}
+	if !video.PubliclyVisible() {
+		return "", nil, fmt.Errorf("video is not public")
 	}
 
-	// Build DTO response
-	response := &dto.PublicVideoResponse{
-		VideoID:      video.ID,
-		Title:        video.Title,
-		Status:       video.Status,
-		IsPublic:     video.IsPublic,
-		UploadedAt:   video.UploadedAt,
-		ProcessedAt:  video.ProcessedAt,
-		ProcessedURL: processedURL,
-		Votes:        0,
+	processedS3Key := fmt.Sprintf("processed/%d.mp4", videoID)
+	info, err := s.storageManager.GetFileInfo(processedS3Key)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get processed video info: %w", err)
 	}
+	return processedS3Key, info, nil
+}
 
-	return response, originalURL, processedURL, nil
+// OpenStreamRange opens length bytes of a stored file starting at offset
+func (s *Service) OpenStreamRange(ctx context.Context, s3Key string, offset int64, length int64) (io.ReadCloser, error) {
+	return s.storageManager.DownloadRange(ctx, s3Key, offset, length)
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -29,38 +30,66 @@ func TestDefaultValidationRules(t *testing.T) {
 }
 
 func TestGenerateS3Key(t *testing.T) {
-	service := &Service{}
-
 	tests := []struct {
 		name     string
 		videoID  int
+		ext      string
 		expected string
 	}{
 		{
 			name:     "Single digit ID",
 			videoID:  1,
+			ext:      ".mp4",
 			expected: "original/1.mp4",
 		},
 		{
 			name:     "Multi digit ID",
 			videoID:  123,
+			ext:      ".mp4",
 			expected: "original/123.mp4",
 		},
 		{
 			name:     "Large ID",
 			videoID:  999999,
+			ext:      ".mp4",
 			expected: "original/999999.mp4",
 		},
+		{
+			name:     "QuickTime original",
+			videoID:  42,
+			ext:      ".mov",
+			expected: "original/42.mov",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := service.generateS3Key(tt.videoID)
+			result := generateS3Key(tt.videoID, tt.ext)
 			assert.Equal(t, tt.expected, result)
 		})
 	}
 }
 
+func TestStartOffset(t *testing.T) {
+	offset, err := ParseStartOffset(" 12.5 ")
+	require.NoError(t, err)
+	assert.Equal(t, 12.5, *offset)
+
+	offset, err = ParseStartOffset("")
+	require.NoError(t, err)
+	assert.Nil(t, offset)
+
+	_, err = ParseStartOffset("NaN")
+	assert.Error(t, err)
+
+	rules := DefaultValidationRules()
+	assert.NoError(t, CheckStartOffset(nil, rules))
+	assert.NoError(t, CheckStartOffset(offset, rules))
+	for _, invalid := range []float64{-1, 60} {
+		assert.ErrorContains(t, CheckStartOffset(&invalid, rules), "start_offset must be between 0 and 60 seconds")
+	}
+}
+
 // Run the test suite
 func TestVideoServiceTestSuite(t *testing.T) {
 	suite.Run(t, new(VideoServiceTestSuite))
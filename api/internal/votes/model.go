@@ -11,3 +11,9 @@ type Vote struct {
 	VideoID int       `json:"video_id" db:"video_id"`
 	VotedAt time.Time `json:"voted_at" db:"voted_at"`
 }
+
+// VoterVote is a vote with the email of the voter, listed for moderation
+type VoterVote struct {
+	Vote
+	UserEmail string `json:"user_email" db:"email"`
+}
@@ -54,7 +54,7 @@ func (r *Repository) RemoveVote(userID, videoID int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("vote not found")
+		return ErrVoteNotFound
 	}
 
 	return nil
@@ -115,3 +115,68 @@ func (r *Repository) VideoExists(videoID int) (bool, error) {
 
 	return true, nil
 }
+
+// ListVideoVotes returns the votes of a video with the email of each voter, newest first
+func (r *Repository) ListVideoVotes(videoID int) ([]*VoterVote, error) {
+	query := `
+		SELECT vo.id, vo.user_id, vo.video_id, vo.voted_at, u.email
+		FROM votes vo
+		JOIN users u ON u.id = vo.user_id
+		WHERE vo.video_id = $1
+		ORDER BY vo.voted_at DESC, vo.id DESC
+	`
+
+	rows, err := r.db.Query(query, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list votes: %w", err)
+	}
+	defer rows.Close()
+
+	var votes []*VoterVote
+	for rows.Next() {
+		var vote VoterVote
+		if err := rows.Scan(&vote.ID, &vote.UserID, &vote.VideoID, &vote.VotedAt, &vote.UserEmail); err != nil {
+			return nil, fmt.Errorf("failed to scan vote row: %w", err)
+		}
+		votes = append(votes, &vote)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating vote rows: %w", err)
+	}
+
+	return votes, nil
+}
+
+// DeleteVote removes a vote by its id
+func (r *Repository) DeleteVote(voteID int) error {
+	result, err := r.db.Exec(`DELETE FROM votes WHERE id = $1`, voteID)
+	if err != nil {
+		return fmt.Errorf("failed to remove vote: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrVoteNotFound
+	}
+
+	return nil
+}
+
+// DeleteUserVotes removes every vote cast by a user and returns how many were removed
+func (r *Repository) DeleteUserVotes(userID int) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM votes WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove votes: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
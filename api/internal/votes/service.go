@@ -1,9 +1,17 @@
 package votes
 
 import (
+	"errors"
 	"fmt"
 )
 
+var (
+	// ErrVideoNotFound is returned when the video does not exist or was deleted
+	ErrVideoNotFound = errors.New("video not found or has been deleted")
+	// ErrVoteNotFound is returned when removing a vote that does not exist
+	ErrVoteNotFound = errors.New("vote not found")
+)
+
 type Service struct {
 	repository *Repository
 }
@@ -23,7 +31,7 @@ func (s *Service) VoteForVideo(userID, videoID int) error {
 	}
 
 	if !exists {
-		return fmt.Errorf("video not found or has been deleted")
+		return ErrVideoNotFound
 	}
 
 	// Check if user has already voted for this video
@@ -54,7 +62,7 @@ func (s *Service) RemoveVote(userID, videoID int) error {
 	}
 
 	if !exists {
-		return fmt.Errorf("video not found or has been deleted")
+		return ErrVideoNotFound
 	}
 
 	// Remove the vote
@@ -85,3 +93,45 @@ func (s *Service) HasUserVoted(userID, videoID int) (bool, error) {
 
 	return hasVoted, nil
 }
+
+// ListVideoVotes returns the votes of a video for moderation
+func (s *Service) ListVideoVotes(videoID int) ([]*VoterVote, error) {
+	exists, err := s.repository.VideoExists(videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify video existence: %w", err)
+	}
+
+	if !exists {
+		return nil, ErrVideoNotFound
+	}
+
+	votes, err := s.repository.ListVideoVotes(videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list votes: %w", err)
+	}
+
+	return votes, nil
+}
+
+// RemoveVoteByID removes a fraudulent vote
+func (s *Service) RemoveVoteByID(voteID int) error {
+	err := s.repository.DeleteVote(voteID)
+	if err != nil {
+		if errors.Is(err, ErrVoteNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to remove vote: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveUserVotes removes every vote cast by a fraudulent account and returns how many were removed
+func (s *Service) RemoveUserVotes(userID int) (int64, error) {
+	removed, err := s.repository.DeleteUserVotes(userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove votes: %w", err)
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,10 @@
+-- *******************************
+-- * ADD pending_upload STATUS    *
+-- *******************************
+
+-- Videos uploaded directly to S3 are created before the file exists and stay
+-- in pending_upload until the client calls the completion endpoint
+ALTER TYPE video_status ADD VALUE IF NOT EXISTS 'pending_upload' BEFORE 'uploaded';
+
+-- Update comment for the status column
+COMMENT ON COLUMN videos.status IS 'Video status: pending_upload, uploaded, processed';
@@ -0,0 +1,24 @@
+-- *******************************
+-- * CREATE MESSAGE QUEUE TABLE  *
+-- *******************************
+
+-- Job table used by the postgres messaging provider (MESSAGING_PROVIDER=postgres)
+-- Consumers claim rows with SELECT ... FOR UPDATE SKIP LOCKED and hide them until visible_at
+CREATE TABLE IF NOT EXISTS message_queue (
+    id             BIGSERIAL        PRIMARY KEY,
+    queue_name     TEXT             NOT NULL,
+    body           TEXT             NOT NULL,
+    receipt_handle TEXT,
+    receive_count  INTEGER          NOT NULL DEFAULT 0,
+    visible_at     TIMESTAMPTZ      NOT NULL DEFAULT NOW(),
+    created_at     TIMESTAMPTZ      NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON COLUMN message_queue.receipt_handle IS 'Handle of the latest receive, required to delete the message';
+COMMENT ON COLUMN message_queue.visible_at     IS 'Message is hidden from consumers until this time (visibility timeout)';
+
+-- Index for claiming the next visible messages of a queue
+CREATE INDEX IF NOT EXISTS idx_message_queue_queue_visible ON message_queue(queue_name, visible_at, id);
+
+-- Index for deleting by receipt handle
+CREATE UNIQUE INDEX IF NOT EXISTS idx_message_queue_receipt_handle ON message_queue(receipt_handle);
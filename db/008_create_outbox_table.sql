@@ -0,0 +1,23 @@
+-- *******************************
+-- * CREATE OUTBOX TABLE         *
+-- *******************************
+
+-- Transactional outbox: processing messages are inserted in the same transaction as the
+-- video row and published to the message queue by the API outbox relay
+CREATE TABLE IF NOT EXISTS outbox (
+    id              BIGSERIAL        PRIMARY KEY,
+    video_id        INTEGER          NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+    message_type    TEXT             NOT NULL,
+    payload         TEXT             NOT NULL,
+    attempts        INTEGER          NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    next_attempt_at TIMESTAMPTZ      NOT NULL DEFAULT NOW(),
+    created_at      TIMESTAMPTZ      NOT NULL DEFAULT NOW(),
+    sent_at         TIMESTAMPTZ
+);
+
+COMMENT ON COLUMN outbox.next_attempt_at IS 'Earliest time the relay tries to publish the message again (exponential backoff)';
+COMMENT ON COLUMN outbox.sent_at         IS 'When the message was published, NULL while pending';
+
+-- Partial index for the relay and the stuck entries report (only pending rows)
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(next_attempt_at, id) WHERE sent_at IS NULL;
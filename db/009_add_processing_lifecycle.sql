@@ -0,0 +1,21 @@
+-- *******************************
+-- * ADD PROCESSING LIFECYCLE     *
+-- *******************************
+
+-- Videos move through queued -> processing -> processed | failed once uploaded.
+-- queued is set when the processing message is published (and again while a retry is pending)
+ALTER TYPE video_status ADD VALUE IF NOT EXISTS 'queued' AFTER 'uploaded';
+ALTER TYPE video_status ADD VALUE IF NOT EXISTS 'processing' AFTER 'queued';
+ALTER TYPE video_status ADD VALUE IF NOT EXISTS 'failed' AFTER 'processed';
+
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS failure_reason         TEXT      NULL;
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS attempts               INTEGER   NOT NULL DEFAULT 0;
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS processing_started_at  TIMESTAMP NULL;
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS processing_duration_ms BIGINT    NULL;
+
+-- COLUMN COMMENTS
+COMMENT ON COLUMN videos.status                 IS 'Video status: pending_upload, uploaded, queued, processing, processed, failed';
+COMMENT ON COLUMN videos.failure_reason         IS 'Last processing error (nullable), kept while a retry is queued';
+COMMENT ON COLUMN videos.attempts               IS 'Number of processing attempts started by the worker';
+COMMENT ON COLUMN videos.processing_started_at  IS 'Timestamp when the last processing attempt started (nullable)';
+COMMENT ON COLUMN videos.processing_duration_ms IS 'Duration of the last finished processing attempt in milliseconds (nullable)';
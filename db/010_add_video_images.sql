@@ -0,0 +1,10 @@
+-- *******************************
+-- * ADD VIDEO IMAGES             *
+-- *******************************
+
+-- The worker stores a poster frame, thumbnails and a scrubbing preview (sprite + WebVTT) under
+-- processed/{id}/ and sets has_images once they are all uploaded
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS has_images BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- COLUMN COMMENTS
+COMMENT ON COLUMN videos.has_images IS 'Poster, thumbnails and preview sprite are available in storage';
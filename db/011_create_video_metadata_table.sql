@@ -0,0 +1,33 @@
+-- *******************************
+-- * CREATE VIDEO METADATA TABLE  *
+-- *******************************
+
+-- ffprobe details of each video: the uploaded source (written by the API on upload) and the
+-- processed output (written by the worker after re-probing it). One row per video and variant
+CREATE TABLE IF NOT EXISTS video_metadata (
+    id               SERIAL           PRIMARY KEY,
+    video_id         INTEGER          NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+    variant          VARCHAR(20)      NOT NULL CHECK (variant IN ('source', 'processed')),
+    container        VARCHAR(100)     NOT NULL,
+    video_codec      VARCHAR(50)      NOT NULL,
+    audio_codec      VARCHAR(50),
+    has_audio        BOOLEAN          NOT NULL DEFAULT FALSE,
+    width            INTEGER          NOT NULL,
+    height           INTEGER          NOT NULL,
+    duration_seconds DOUBLE PRECISION NOT NULL,
+    bitrate          BIGINT,
+    fps              DOUBLE PRECISION,
+    rotation         INTEGER          NOT NULL DEFAULT 0,
+    size_bytes       BIGINT           NOT NULL,
+    checksum_sha256  CHAR(64),
+    probed_at        TIMESTAMP        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (video_id, variant)
+);
+
+-- COLUMN COMMENTS
+COMMENT ON COLUMN video_metadata.variant         IS 'source: uploaded file, processed: worker output';
+COMMENT ON COLUMN video_metadata.container       IS 'ffprobe format_name of the file';
+COMMENT ON COLUMN video_metadata.bitrate         IS 'Overall bitrate in bits per second (nullable when unknown)';
+COMMENT ON COLUMN video_metadata.fps             IS 'Average frame rate (nullable when unknown)';
+COMMENT ON COLUMN video_metadata.rotation        IS 'Clockwise display rotation in degrees (0, 90, 180, 270)';
+COMMENT ON COLUMN video_metadata.checksum_sha256 IS 'SHA-256 of the file (nullable, not computed for direct-to-storage uploads)';
@@ -0,0 +1,10 @@
+-- *******************************
+-- * ADD VIDEO PROFILE            *
+-- *******************************
+
+-- Name of the upload profile (PROFILES_FILE) the API validated the video against. The worker
+-- reads it to apply the processing settings of the same profile
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS profile VARCHAR(64) NOT NULL DEFAULT 'standard';
+
+-- COLUMN COMMENTS
+COMMENT ON COLUMN videos.profile IS 'Upload profile used to validate and process the video';
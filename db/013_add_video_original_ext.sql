@@ -0,0 +1,10 @@
+-- *******************************
+-- * ADD VIDEO ORIGINAL EXTENSION *
+-- *******************************
+
+-- Extension of the original upload, from the container detected from its content. The original
+-- is stored as original/{id}{ext}, existing videos were all uploaded as MP4
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS original_ext VARCHAR(8) NOT NULL DEFAULT '.mp4';
+
+-- COLUMN COMMENTS
+COMMENT ON COLUMN videos.original_ext IS 'Extension of the stored original (.mp4, .mov, .webm, .mkv)';
@@ -0,0 +1,19 @@
+-- *******************************
+-- * ADD VIDEO TRIM WINDOW        *
+-- *******************************
+
+-- Start of the processed clip chosen by the user on upload (start_offset). NULL lets the worker
+-- pick the best window of the source
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS start_offset DOUBLE PRECISION;
+
+-- Part of the source kept in the processed video, recorded by the worker on the processed row
+ALTER TABLE video_metadata ADD COLUMN IF NOT EXISTS trim_start_seconds DOUBLE PRECISION;
+ALTER TABLE video_metadata ADD COLUMN IF NOT EXISTS trim_end_seconds DOUBLE PRECISION;
+ALTER TABLE video_metadata ADD COLUMN IF NOT EXISTS trim_selection VARCHAR(10)
+    CHECK (trim_selection IN ('full', 'auto', 'user'));
+
+-- COLUMN COMMENTS
+COMMENT ON COLUMN videos.start_offset                IS 'Start of the processed clip in seconds chosen by the user (nullable, automatic when NULL)';
+COMMENT ON COLUMN video_metadata.trim_start_seconds IS 'Start of the kept source window in seconds (processed variant only)';
+COMMENT ON COLUMN video_metadata.trim_end_seconds   IS 'End of the kept source window in seconds (processed variant only)';
+COMMENT ON COLUMN video_metadata.trim_selection     IS 'full: source fits, auto: best scored window, user: start_offset';
@@ -0,0 +1,25 @@
+-- *******************************
+-- * CREATE REFRESH TOKENS TABLE *
+-- *******************************
+
+-- Refresh tokens issued at login. Each refresh rotates the token: the presented one is marked
+-- used and a new one of the same family is issued. Presenting a used token again means it was
+-- stolen, so the whole family is revoked and both holders must log in again
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id          BIGSERIAL    PRIMARY KEY,
+    user_id     INTEGER      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id   TEXT         NOT NULL,
+    token_hash  TEXT         NOT NULL UNIQUE,
+    expires_at  TIMESTAMPTZ  NOT NULL,
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    used_at     TIMESTAMPTZ,
+    revoked_at  TIMESTAMPTZ
+);
+
+COMMENT ON COLUMN refresh_tokens.family_id  IS 'Shared by the token issued at login and all its rotations';
+COMMENT ON COLUMN refresh_tokens.token_hash IS 'SHA-256 of the opaque token, the token itself is never stored';
+COMMENT ON COLUMN refresh_tokens.used_at    IS 'When the token was rotated, presenting it again revokes the family';
+COMMENT ON COLUMN refresh_tokens.revoked_at IS 'Set on logout or reuse detection for every token of the family';
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id);
@@ -0,0 +1,15 @@
+-- *******************************
+-- * CREATE REVOKED TOKENS TABLE *
+-- *******************************
+
+-- Access tokens revoked by logout, shared by every API instance. Rows are keyed on the token
+-- jti and only needed until the token expires; the API janitor deletes them afterwards
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti         TEXT         PRIMARY KEY,
+    expires_at  TIMESTAMPTZ  NOT NULL,
+    revoked_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON COLUMN revoked_tokens.expires_at IS 'Expiry (exp) of the revoked token, the row is useless afterwards';
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
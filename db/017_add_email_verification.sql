@@ -0,0 +1,37 @@
+-- *******************************
+-- * ADD EMAIL VERIFICATION      *
+-- *******************************
+
+-- Accounts created before email verification existed are considered verified. The column is
+-- added and backfilled together so running the script again does not verify new accounts
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'users' AND column_name = 'email_verified_at'
+    ) THEN
+        ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ NULL;
+        UPDATE users SET email_verified_at = NOW();
+    END IF;
+END
+$$;
+
+COMMENT ON COLUMN users.email_verified_at IS 'When the user confirmed the email address (NULL until verified)';
+
+-- Single-use tokens sent by email to verify the address and to reset the password. Like
+-- refresh tokens, only their hash is stored
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id          BIGSERIAL    PRIMARY KEY,
+    user_id     INTEGER      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose     TEXT         NOT NULL CHECK (purpose IN ('verify_email', 'reset_password')),
+    token_hash  TEXT         NOT NULL UNIQUE,
+    expires_at  TIMESTAMPTZ  NOT NULL,
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    used_at     TIMESTAMPTZ
+);
+
+COMMENT ON COLUMN user_tokens.purpose    IS 'verify_email or reset_password, a token is only accepted for its purpose';
+COMMENT ON COLUMN user_tokens.token_hash IS 'SHA-256 of the opaque token, the token itself is never stored';
+COMMENT ON COLUMN user_tokens.used_at    IS 'When the token was consumed, it cannot be used again';
+
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user_purpose ON user_tokens(user_id, purpose);
@@ -0,0 +1,73 @@
+-- *******************************
+-- * ADD ROLES AND MODERATION    *
+-- *******************************
+
+-- Roles: players upload and vote, moderators hide videos and remove fraudulent votes, admins
+-- also manage accounts. Promote the first admin with:
+--   UPDATE users SET role = 'admin' WHERE email = '...';
+DO $$
+BEGIN
+    CREATE TYPE user_role AS ENUM ('player', 'moderator', 'admin');
+EXCEPTION
+    WHEN duplicate_object THEN NULL;
+END
+$$;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role        user_role    NOT NULL DEFAULT 'player';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMPTZ  NULL;
+
+COMMENT ON COLUMN users.role        IS 'player, moderator or admin, carried in the role claim of access tokens';
+COMMENT ON COLUMN users.disabled_at IS 'When an admin disabled the account (NULL if active), disabled users cannot log in';
+
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role) WHERE role <> 'player';
+
+-- Videos hidden by a moderator are left out of the public listing, playback and rankings,
+-- whatever their is_public flag says, until they are restored
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS hidden_at     TIMESTAMPTZ  NULL;
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS hidden_reason TEXT         NULL;
+
+COMMENT ON COLUMN videos.hidden_at     IS 'When a moderator hid the video (NULL if visible)';
+COMMENT ON COLUMN videos.hidden_reason IS 'Reason given by the moderator, shown to the owner';
+
+-- Rebuild the rankings without hidden videos and disabled users. Only done once, while the
+-- view still has the definition of 005
+DO $$
+BEGIN
+    IF position('hidden_at' IN pg_get_viewdef('player_rankings'::regclass)) = 0 THEN
+        DROP MATERIALIZED VIEW player_rankings;
+
+        CREATE MATERIALIZED VIEW player_rankings AS
+        SELECT
+            u.id AS user_id,
+            u.first_name,
+            u.last_name,
+            u.email,
+            u.city,
+            u.country,
+            COALESCE(vote_stats.total_votes, 0) AS total_votes,
+            ROW_NUMBER() OVER (ORDER BY COALESCE(vote_stats.total_votes, 0) DESC, u.id ASC) AS ranking,
+            NOW() AS last_updated
+        FROM users u
+        LEFT JOIN (
+            SELECT
+                v.user_id,
+                COUNT(vo.id) AS total_votes
+            FROM videos v
+            LEFT JOIN votes vo ON v.id = vo.video_id
+            WHERE v.deleted_at IS NULL -- Only include non-deleted videos
+              AND v.hidden_at IS NULL  -- nor videos hidden by a moderator
+            GROUP BY v.user_id
+        ) vote_stats ON u.id = vote_stats.user_id
+        WHERE u.disabled_at IS NULL
+        ORDER BY total_votes DESC, u.id ASC;
+
+        CREATE UNIQUE INDEX idx_player_rankings_user_id ON player_rankings(user_id);
+        CREATE INDEX idx_player_rankings_total_votes ON player_rankings(total_votes DESC);
+        CREATE INDEX idx_player_rankings_ranking ON player_rankings(ranking);
+        CREATE INDEX idx_player_rankings_country ON player_rankings(country);
+        CREATE INDEX idx_player_rankings_city ON player_rankings(city);
+
+        COMMENT ON MATERIALIZED VIEW player_rankings IS 'Player rankings based on total votes received on their visible videos';
+    END IF;
+END
+$$;
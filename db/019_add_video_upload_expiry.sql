@@ -0,0 +1,14 @@
+-- *******************************
+-- * ADD VIDEO UPLOAD EXPIRY      *
+-- *******************************
+
+-- When the presigned upload URL of a pending_upload video expires. Videos still pending some
+-- time after it (UPLOADS_PENDING_GRACE) are deleted by the API with their stored file; pending
+-- videos without it (direct uploads) expire from uploaded_at
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS upload_expires_at TIMESTAMPTZ NULL;
+
+-- COLUMN COMMENTS
+COMMENT ON COLUMN videos.upload_expires_at IS 'Expiration of the presigned upload URL of a pending_upload video (NULL once uploaded)';
+
+-- INDEXES
+CREATE INDEX IF NOT EXISTS idx_videos_pending_upload ON videos(uploaded_at) WHERE status = 'pending_upload';
@@ -0,0 +1,14 @@
+-- *******************************
+-- * ADD MESSAGE QUEUE DEAD LETTER *
+-- *******************************
+
+-- Messages received MESSAGING_MAX_RECEIVE_COUNT times without being deleted are dead-lettered
+-- instead of being delivered again: they stay in the table for investigation, out of the queue
+ALTER TABLE message_queue ADD COLUMN IF NOT EXISTS dead_lettered_at TIMESTAMPTZ NULL;
+
+COMMENT ON COLUMN message_queue.receive_count    IS 'Times the message was received, it is dead-lettered when the limit is reached';
+COMMENT ON COLUMN message_queue.dead_lettered_at IS 'When the message was moved out of the queue for exceeding its receive count (NULL if live)';
+
+-- Only live messages are claimed
+CREATE INDEX IF NOT EXISTS idx_message_queue_live ON message_queue(queue_name, visible_at, id) WHERE dead_lettered_at IS NULL;
+DROP INDEX IF EXISTS idx_message_queue_queue_visible;
@@ -0,0 +1,47 @@
+-- *****************************************
+-- * EXCLUDE DISABLED VOTERS FROM RANKINGS *
+-- *****************************************
+
+-- The votes cast by disabled accounts stop counting in the rankings, and count again if the
+-- account is re-enabled. Only done once, while the view still has the definition of 018
+DO $$
+BEGIN
+    IF position('voter.disabled_at' IN pg_get_viewdef('player_rankings'::regclass)) = 0 THEN
+        DROP MATERIALIZED VIEW player_rankings;
+
+        CREATE MATERIALIZED VIEW player_rankings AS
+        SELECT
+            u.id AS user_id,
+            u.first_name,
+            u.last_name,
+            u.email,
+            u.city,
+            u.country,
+            COALESCE(vote_stats.total_votes, 0) AS total_votes,
+            ROW_NUMBER() OVER (ORDER BY COALESCE(vote_stats.total_votes, 0) DESC, u.id ASC) AS ranking,
+            NOW() AS last_updated
+        FROM users u
+        LEFT JOIN (
+            SELECT
+                v.user_id,
+                COUNT(vo.id) FILTER (WHERE voter.disabled_at IS NULL) AS total_votes
+            FROM videos v
+            LEFT JOIN votes vo ON v.id = vo.video_id
+            LEFT JOIN users voter ON vo.user_id = voter.id
+            WHERE v.deleted_at IS NULL -- Only include non-deleted videos
+              AND v.hidden_at IS NULL  -- nor videos hidden by a moderator
+            GROUP BY v.user_id
+        ) vote_stats ON u.id = vote_stats.user_id
+        WHERE u.disabled_at IS NULL
+        ORDER BY total_votes DESC, u.id ASC;
+
+        CREATE UNIQUE INDEX idx_player_rankings_user_id ON player_rankings(user_id);
+        CREATE INDEX idx_player_rankings_total_votes ON player_rankings(total_votes DESC);
+        CREATE INDEX idx_player_rankings_ranking ON player_rankings(ranking);
+        CREATE INDEX idx_player_rankings_country ON player_rankings(country);
+        CREATE INDEX idx_player_rankings_city ON player_rankings(city);
+
+        COMMENT ON MATERIALIZED VIEW player_rankings IS 'Player rankings based on total votes received on their visible videos from active accounts';
+    END IF;
+END
+$$;
@@ -8,7 +8,7 @@ class ApiService {
     this.token = localStorage.getItem("access_token");
   }
 
-  async request(endpoint, options = {}) {
+  async request(endpoint, options = {}, retried = false) {
     const url = `${this.baseURL}${endpoint}`;
     const config = {
       headers: {
@@ -29,6 +29,16 @@ class ApiService {
       const response = await fetch(url, config);
       console.log("📡 Response Status:", response.status);
 
+      // Access tokens are short-lived: renew them once with the refresh token and retry
+      if (
+        response.status === 401 &&
+        !retried &&
+        !endpoint.startsWith("/api/auth/") &&
+        (await this.refreshTokens())
+      ) {
+        return this.request(endpoint, options, true);
+      }
+
       if (response.status === 204) {
         return {};
       }
@@ -84,13 +94,62 @@ class ApiService {
     });
 
     if (response.token) {
-      this.token = response.token;
-      localStorage.setItem("access_token", response.token);
+      this.storeTokens(response);
     }
 
     return response;
   }
 
+  storeTokens(response) {
+    this.token = response.token;
+    localStorage.setItem("access_token", response.token);
+    localStorage.setItem("refresh_token", response.refresh_token);
+  }
+
+  // Exchanges the stored refresh token for new tokens, returns whether it succeeded
+  async refreshTokens() {
+    const refreshToken = localStorage.getItem("refresh_token");
+    if (!refreshToken) {
+      return false;
+    }
+
+    try {
+      const response = await this.request("/api/auth/refresh", {
+        method: "POST",
+        body: JSON.stringify({ refresh_token: refreshToken }),
+      });
+      this.storeTokens(response);
+      return true;
+    } catch (error) {
+      console.error("Token refresh failed:", error);
+      this.token = null;
+      localStorage.removeItem("access_token");
+      localStorage.removeItem("refresh_token");
+      return false;
+    }
+  }
+
+  async verifyEmail(token) {
+    return await this.request("/api/auth/verify-email", {
+      method: "POST",
+      body: JSON.stringify({ token }),
+    });
+  }
+
+  async forgotPassword(email) {
+    return await this.request("/api/auth/forgot-password", {
+      method: "POST",
+      body: JSON.stringify({ email }),
+    });
+  }
+
+  async resetPassword(token, password1, password2) {
+    return await this.request("/api/auth/reset-password", {
+      method: "POST",
+      body: JSON.stringify({ token, password1, password2 }),
+    });
+  }
+
   async getProfile() {
     return await this.request("/api/auth/profile");
   }
@@ -192,10 +251,12 @@ class ApiService {
   }
 
   async logout() {
-    if (this.token) {
+    const refreshToken = localStorage.getItem("refresh_token");
+    if (this.token || refreshToken) {
       try {
         await this.request("/api/auth/logout", {
           method: "POST",
+          body: JSON.stringify({ refresh_token: refreshToken || "" }),
         });
       } catch (error) {
         console.error("Server logout failed:", error);
@@ -204,6 +265,7 @@ class ApiService {
 
     this.token = null;
     localStorage.removeItem("access_token");
+    localStorage.removeItem("refresh_token");
   }
 
   isAuthenticated() {
@@ -51,6 +51,19 @@ resource "aws_lambda_function" "video_processor" {
 
       # Assets path
       ASSETS_PATH = "/app/assets"
+
+      # Batch handling: records processed at the same time and time kept free before the
+      # deadline (records that cannot start are reported as batch item failures)
+      LAMBDA_CONCURRENCY        = "2"
+      LAMBDA_MIN_REMAINING_TIME = "2m"
+
+      # Receive that reaches the redrive maxReceiveCount is the last attempt: a failure marks the
+      # video as failed instead of leaving it pending a retry that never comes
+      LAMBDA_MAX_RECEIVE_COUNT = tostring(var.sqs_max_receive_count)
+
+      # Must match the queue: a video in processing for longer than this plus 5 minutes belongs to
+      # an attempt that died and is taken over by the next delivery
+      MESSAGING_VISIBILITY_TIMEOUT = "${aws_sqs_queue.video_processing.visibility_timeout_seconds}s"
     }
   }
 
@@ -82,10 +95,10 @@ resource "aws_lambda_event_source_mapping" "sqs_trigger" {
   function_name    = aws_lambda_function.video_processor.arn
 
   # Batch configuration
-  batch_size                         = 1 # Process one video at a time
+  batch_size                         = 2 # Processed concurrently (LAMBDA_CONCURRENCY), failed records reported individually
   maximum_batching_window_in_seconds = 0 # Invoke immediately when message arrives
 
-  # Error handling
+  # Error handling: the handler returns BatchItemFailures so only failed records are retried
   function_response_types = ["ReportBatchItemFailures"]
 
   # Scaling configuration
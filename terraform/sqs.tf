@@ -18,7 +18,7 @@ resource "aws_sqs_queue" "video_processing" {
   # After 3 failed processing attempts, messages go to DLQ for investigation
   redrive_policy = jsonencode({
     deadLetterTargetArn = aws_sqs_queue.video_processing_dlq.arn
-    maxReceiveCount     = 3
+    maxReceiveCount     = var.sqs_max_receive_count
   })
 
   tags = {
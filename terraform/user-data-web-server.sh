@@ -134,6 +134,9 @@ http {
         server 127.0.0.1:8080;
     }
 
+    # Cache of public video streams in 1 MB slices, revalidated with the ETag of the API
+    proxy_cache_path /var/cache/nginx/streams levels=1:2 keys_zone=video_streams:10m max_size=2g inactive=1h use_temp_path=off;
+
     server {
         listen 80;
         server_name _;
@@ -155,6 +158,25 @@ http {
             add_header Content-Type text/plain;
         }
 
+        # Public video streaming, cached by slice
+        location ~ ^/api/public/videos/[0-9]+/stream$ {
+            slice 1m;
+            proxy_cache video_streams;
+            proxy_cache_key $uri$slice_range;
+            proxy_cache_valid 200 206 1m;
+            proxy_cache_revalidate on;
+            proxy_cache_lock on;
+            add_header X-Cache-Status $upstream_cache_status always;
+
+            proxy_pass http://api;
+            proxy_http_version 1.1;
+            proxy_set_header Host $host;
+            proxy_set_header X-Real-IP $remote_addr;
+            proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+            proxy_set_header X-Forwarded-Proto $scheme;
+            proxy_set_header Range $slice_range;
+        }
+
         # API endpoints
         location /api/ {
             proxy_pass http://api;
@@ -106,6 +106,12 @@ variable "sqs_visibility_timeout" {
   default     = 900 # 15 minutes (enough for video processing)
 }
 
+variable "sqs_max_receive_count" {
+  description = "Receives before a message is moved to the DLQ (also tells the Lambda worker which attempt is the last)"
+  type        = number
+  default     = 3
+}
+
 variable "sqs_message_retention" {
   description = "SQS message retention period in seconds"
   type        = number
@@ -84,6 +84,14 @@ resource "aws_lb_target_group" "web_server" {
   # Graceful shutdown - wait for connections to drain
   deregistration_delay = 30
 
+  # Resumable (tus) uploads keep their partial data on the disk of the instance that created
+  # them, so a client stays on the same instance while its AWSALB cookie is sent back
+  stickiness {
+    enabled         = true
+    type            = "lb_cookie"
+    cookie_duration = 86400 # Matches the UPLOADS_EXPIRATION default of the API
+  }
+
   health_check {
     enabled             = true
     healthy_threshold   = 2
@@ -9,7 +9,6 @@ import (
 
 	"worker/internal"
 	"worker/internal/ObjectStorage"
-	"worker/internal/ObjectStorage/providers"
 	"worker/internal/config"
 	"worker/internal/database"
 	"worker/internal/videos"
@@ -18,8 +17,8 @@ import (
 // Global variables for connection reuse across Lambda invocations
 // These are initialized once during cold start and reused for subsequent invocations
 var (
-	processingService *internal.LambdaProcessingService
-	cfg               *config.Config
+	batchHandler *internal.BatchHandler
+	cfg          *config.Config
 )
 
 // init runs once when Lambda container starts (cold start)
@@ -28,7 +27,11 @@ func init() {
 	log.Println("Lambda cold start - initializing connections...")
 
 	// Load configuration from environment variables
-	cfg = config.Load()
+	var err error
+	cfg, err = config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
 	log.Printf("Starting %s v%s (env: %s)", cfg.App.Name, cfg.App.Version, cfg.App.Env)
 
 	// Initialize database connection (reused across invocations)
@@ -38,57 +41,30 @@ func init() {
 	}
 	log.Println("Database connection initialized")
 
-	// Initialize S3 storage provider
-	s3Provider, err := providers.NewS3Provider(&providers.S3Config{
-		AccessKeyID:     cfg.AWS.AccessKeyID,
-		SecretAccessKey: cfg.AWS.SecretAccessKey,
-		Region:          cfg.AWS.Region,
-		BucketName:      cfg.AWS.S3BucketName,
-		EndpointURL:     cfg.AWS.EndpointURL,
-	})
+	// Initialize file storage (S3, filesystem or memory depending on STORAGE_PROVIDER)
+	storageManager, err := ObjectStorage.NewFileStorageManagerFromConfig(cfg)
 	if err != nil {
-		log.Fatalf("Failed to initialize S3 provider: %v", err)
+		log.Fatalf("Failed to initialize storage: %v", err)
 	}
-	log.Println("S3 storage provider initialized")
+	log.Printf("%s storage provider initialized", cfg.Storage.Provider)
 
 	// Initialize components
-	storageManager := ObjectStorage.NewFileStorageManager(s3Provider)
-	videoRepo := videos.NewRepository(db)
-	processor := internal.NewVideoProcessor()
+	videoRepo := videos.NewRepository(db, cfg.Messaging.VisibilityTimeout)
+	processor := internal.NewVideoProcessor(cfg.Profiles)
+	processor.PrepareBumpers() // Bumpers re-encoded to each profile, cached across restarts
 
-	// Initialize processing service (reused across invocations)
-	processingService = internal.NewLambdaProcessingService(videoRepo, storageManager, processor)
+	// Initialize the processing pipeline (reused across invocations, shared with the queue worker)
+	pipeline := internal.NewPipeline(videoRepo, storageManager, processor, internal.SystemClock{}, processor.TempDir())
+	batchHandler = internal.NewBatchHandler(pipeline, internal.SystemClock{}, &cfg.Lambda)
 
 	log.Println("Lambda initialization complete - ready to process messages")
 }
 
 // handler processes SQS events from Lambda
-// This function is called by AWS Lambda runtime for each invocation
-func handler(ctx context.Context, sqsEvent events.SQSEvent) error {
-	log.Printf("Lambda invoked with %d messages", len(sqsEvent.Records))
-
-	// Process each record in the batch
-	for _, record := range sqsEvent.Records {
-		if err := processRecord(ctx, record); err != nil {
-			log.Printf("Error processing message %s: %v", record.MessageId, err)
-			// Return error to trigger Lambda retry
-			// Lambda will automatically retry failed messages
-			return err
-		}
-	}
-
-	log.Printf("Successfully processed %d messages", len(sqsEvent.Records))
-	return nil
-}
-
-// processRecord processes a single SQS record using the processing service
-func processRecord(ctx context.Context, record events.SQSMessage) error {
-	log.Printf("Processing message ID: %s", record.MessageId)
-
-	// Delegate to processing service
-	// Service returns nil for permanent errors (no retry)
-	// Service returns error for transient errors (Lambda retries)
-	return processingService.ProcessMessage(ctx, record.Body)
+// This function is called by AWS Lambda runtime for each invocation. Only the failed records are
+// reported back (ReportBatchItemFailures), the others are deleted from the queue
+func handler(ctx context.Context, sqsEvent events.SQSEvent) (events.SQSEventResponse, error) {
+	return batchHandler.HandleEvent(ctx, sqsEvent), nil
 }
 
 // main is the entry point for the Lambda function
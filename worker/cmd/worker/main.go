@@ -9,7 +9,6 @@ import (
 
 	"worker/internal"
 	"worker/internal/ObjectStorage"
-	"worker/internal/ObjectStorage/providers"
 	"worker/internal/config"
 	"worker/internal/database"
 	messagingProviders "worker/internal/messaging/providers"
@@ -18,7 +17,10 @@ import (
 
 func main() {
 	// Load configuration from environment variables
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
 
 	log.Printf("Starting %s v%s (env: %s)", cfg.App.Name, cfg.App.Version, cfg.App.Env)
 
@@ -37,23 +39,14 @@ func main() {
 		}
 	}()
 
-	// Initialize S3 storage provider
-	s3Provider, err := providers.NewS3Provider(&providers.S3Config{
-		AccessKeyID:     cfg.AWS.AccessKeyID,
-		SecretAccessKey: cfg.AWS.SecretAccessKey,
-		Region:          cfg.AWS.Region,
-		BucketName:      cfg.AWS.S3BucketName,
-		EndpointURL:     cfg.AWS.EndpointURL,
-	})
+	// Initialize file storage (S3, filesystem or memory depending on STORAGE_PROVIDER)
+	storageManager, err := ObjectStorage.NewFileStorageManagerFromConfig(cfg)
 	if err != nil {
-		log.Fatalf("Failed to initialize S3 provider: %v", err)
+		log.Fatalf("Failed to initialize storage: %v", err)
 	}
 
-	// Initialize file storage manager
-	storageManager := ObjectStorage.NewFileStorageManager(s3Provider)
-
-	// Initialize SQS message queue
-	messageQueue, err := messagingProviders.NewSQSQueue(&cfg.AWS)
+	// Initialize message queue (SQS, Postgres, AMQP or memory depending on MESSAGING_PROVIDER)
+	messageQueue, err := messagingProviders.NewMessageQueue(cfg, db)
 	if err != nil {
 		log.Fatalf("Failed to initialize message queue: %v", err)
 	}
@@ -66,10 +59,15 @@ func main() {
 	}()
 
 	// Initialize video repository
-	videoRepo := videos.NewRepository(db)
+	videoRepo := videos.NewRepository(db, cfg.Messaging.VisibilityTimeout)
+
+	// Initialize the processing pipeline (shared with the Lambda handler)
+	processor := internal.NewVideoProcessor(cfg.Profiles)
+	processor.PrepareBumpers() // Bumpers re-encoded to each profile, cached across restarts
+	pipeline := internal.NewPipeline(videoRepo, storageManager, processor, internal.SystemClock{}, processor.TempDir())
 
 	// Initialize worker service
-	workerService := internal.NewWorkerService(messageQueue, videoRepo, storageManager, &cfg.Retry)
+	workerService := internal.NewWorkerService(messageQueue, pipeline, &cfg.Retry, &cfg.Worker)
 
 	// Ensure worker service is closed on exit
 	defer func() {
@@ -87,7 +85,9 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	// Start worker in a goroutine
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		log.Println("Starting worker message processing...")
 		if err := workerService.ProcessMessages(ctx); err != nil {
 			if err != context.Canceled {
@@ -100,8 +100,9 @@ func main() {
 	sig := <-sigChan
 	log.Printf("Received signal %v, shutting down worker...", sig)
 
-	// Cancel context to stop worker
+	// Cancel context to stop worker and wait for the in-flight messages to stop
 	cancel()
+	<-stopped
 
 	log.Println("Worker shutdown complete")
 }
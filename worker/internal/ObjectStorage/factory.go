@@ -0,0 +1,44 @@
+package ObjectStorage
+
+import (
+	"fmt"
+
+	"worker/internal/ObjectStorage/providers"
+	"worker/internal/config"
+)
+
+// NewFileStorageManagerFromConfig creates a FileStorageManager for the provider selected in configuration
+func NewFileStorageManagerFromConfig(cfg *config.Config) (*FileStorageManager, error) {
+	switch cfg.Storage.Provider {
+	case "s3":
+		s3Provider, err := providers.NewS3Provider(&providers.S3Config{
+			AccessKeyID:     cfg.AWS.AccessKeyID,
+			SecretAccessKey: cfg.AWS.SecretAccessKey,
+			Region:          cfg.AWS.Region,
+			BucketName:      cfg.AWS.S3BucketName,
+			EndpointURL:     cfg.AWS.EndpointURL,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize S3 provider: %w", err)
+		}
+		return NewFileStorageManager(s3Provider), nil
+
+	case "filesystem":
+		fsProvider, err := providers.NewFilesystemProvider(&providers.FilesystemConfig{
+			RootDir:       cfg.Storage.RootDir,
+			BaseURL:       cfg.Storage.PublicBaseURL,
+			SigningKey:    cfg.Storage.SigningKey,
+			URLExpiration: cfg.Storage.URLExpiration,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize filesystem provider: %w", err)
+		}
+		return NewFileStorageManager(fsProvider), nil
+
+	case "memory":
+		return NewFileStorageManager(providers.NewMemoryProvider()), nil
+
+	default:
+		return nil, fmt.Errorf("unknown storage provider %q (expected s3, filesystem or memory)", cfg.Storage.Provider)
+	}
+}
@@ -0,0 +1,95 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// MemoryProvider implements IFileStorageProvider in memory. It is meant for tests and for
+// running a single process without external storage; files are lost on restart
+type MemoryProvider struct {
+	mu    sync.RWMutex
+	files map[string]memoryFile
+}
+
+type memoryFile struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+// NewMemoryProvider creates a new empty in-memory provider
+func NewMemoryProvider() *MemoryProvider {
+	return &MemoryProvider{
+		files: make(map[string]memoryFile),
+	}
+}
+
+// UploadFile stores a copy of the file buffer
+func (m *MemoryProvider) UploadFile(fileBuffer []byte, fileName string) error {
+	return m.Upload(context.TODO(), fileName, bytes.NewReader(fileBuffer), int64(len(fileBuffer)), "")
+}
+
+// Upload reads the whole reader and stores it under the given key
+func (m *MemoryProvider) Upload(ctx context.Context, fileName string, body io.Reader, size int64, contentType string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("failed to read file content: %w", err)
+	}
+	if size >= 0 && int64(len(data)) != size {
+		return fmt.Errorf("expected %d bytes, got %d", size, len(data))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[fileName] = memoryFile{data: data, contentType: contentType, modTime: time.Now()}
+	return nil
+}
+
+// Download returns a reader over the stored content
+func (m *MemoryProvider) Download(ctx context.Context, fileName string) (io.ReadCloser, int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	file, ok := m.files[fileName]
+	if !ok {
+		return nil, 0, ErrFileNotFound
+	}
+	return io.NopCloser(bytes.NewReader(file.data)), int64(len(file.data)), nil
+}
+
+// DownloadFile returns a copy of the stored content
+func (m *MemoryProvider) DownloadFile(fileName string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	file, ok := m.files[fileName]
+	if !ok {
+		return nil, ErrFileNotFound
+	}
+	return bytes.Clone(file.data), nil
+}
+
+// GetSignedUrl returns a memory:// URL identifying the file
+func (m *MemoryProvider) GetSignedUrl(fileName string) (string, error) {
+	return "memory://" + fileName, nil
+}
+
+// DeleteFile removes the file if it exists
+func (m *MemoryProvider) DeleteFile(fileName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, fileName)
+	return nil
+}
+
+// Ensure MemoryProvider implements IFileStorageProvider
+var _ IFileStorageProvider = (*MemoryProvider)(nil)
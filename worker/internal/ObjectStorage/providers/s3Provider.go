@@ -3,13 +3,17 @@ package providers
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 // S3Config holds the configuration for S3 provider
@@ -21,9 +25,14 @@ type S3Config struct {
 	EndpointURL     string // For LocalStack
 }
 
+// multipartPartSize is the part size used for multipart uploads. Objects smaller than one
+// part are sent with a single PutObject by the upload manager
+const multipartPartSize = 8 * 1024 * 1024 // 8MB
+
 // S3Provider implements IFileStorageProvider using AWS S3
 type S3Provider struct {
 	client     *s3.Client
+	uploader   *manager.Uploader
 	bucketName string
 }
 
@@ -65,18 +74,39 @@ func NewS3Provider(cfg *S3Config) (*S3Provider, error) {
 		client = s3.NewFromConfig(awsConfig)
 	}
 
+	// Multipart upload manager streams large objects in parts instead of buffering them
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		u.PartSize = multipartPartSize
+	})
+
 	return &S3Provider{
 		client:     client,
+		uploader:   uploader,
 		bucketName: cfg.BucketName,
 	}, nil
 }
 
 // UploadFile uploads a file buffer to S3
 func (s *S3Provider) UploadFile(fileBuffer []byte, fileName string) error {
-	_, err := s.client.PutObject(context.TODO(), &s3.PutObjectInput{
+	return s.Upload(context.TODO(), fileName, bytes.NewReader(fileBuffer), int64(len(fileBuffer)), "")
+}
+
+// Upload streams the reader to S3 using the multipart upload manager. The size is used to
+// pick a part size that stays within the S3 part limit (-1 if unknown)
+func (s *S3Provider) Upload(ctx context.Context, fileName string, body io.Reader, size int64, contentType string) error {
+	input := &s3.PutObjectInput{
 		Bucket: aws.String(s.bucketName),
 		Key:    aws.String(fileName),
-		Body:   bytes.NewReader(fileBuffer),
+		Body:   body,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	_, err := s.uploader.Upload(ctx, input, func(u *manager.Uploader) {
+		if partSize := size/int64(manager.MaxUploadParts) + 1; partSize > u.PartSize {
+			u.PartSize = partSize
+		}
 	})
 	if err != nil {
 		return fmt.Errorf("failed to upload file to S3: %w", err)
@@ -84,6 +114,23 @@ func (s *S3Provider) UploadFile(fileBuffer []byte, fileName string) error {
 	return nil
 }
 
+// Download opens a stream to the object content. The caller must close the returned reader
+func (s *S3Provider) Download(ctx context.Context, fileName string) (io.ReadCloser, int64, error) {
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(fileName),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, 0, ErrFileNotFound
+		}
+		return nil, 0, fmt.Errorf("failed to download file from S3: %w", err)
+	}
+
+	return result.Body, aws.ToInt64(result.ContentLength), nil
+}
+
 // GetSignedUrl generates a presigned URL for file access
 func (s *S3Provider) GetSignedUrl(fileName string) (string, error) {
 	presignClient := s3.NewPresignClient(s.client)
@@ -116,18 +163,15 @@ func (s *S3Provider) DeleteFile(fileName string) error {
 
 // DownloadFile downloads a file from S3 and returns the file content
 func (s *S3Provider) DownloadFile(fileName string) ([]byte, error) {
-	result, err := s.client.GetObject(context.TODO(), &s3.GetObjectInput{
-		Bucket: aws.String(s.bucketName),
-		Key:    aws.String(fileName),
-	})
+	body, _, err := s.Download(context.TODO(), fileName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to download file from S3: %w", err)
+		return nil, err
 	}
-	defer result.Body.Close()
+	defer body.Close()
 
 	// Read the entire file content
 	var buffer bytes.Buffer
-	_, err = buffer.ReadFrom(result.Body)
+	_, err = buffer.ReadFrom(body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file content: %w", err)
 	}
@@ -135,12 +179,17 @@ func (s *S3Provider) DownloadFile(fileName string) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
+// ErrFileNotFound is returned when the requested file does not exist in storage
+var ErrFileNotFound = errors.New("file not found in storage")
+
 // IFileStorageProvider defines the interface for file storage operations
 type IFileStorageProvider interface {
 	UploadFile(fileBuffer []byte, fileName string) error
+	Upload(ctx context.Context, fileName string, body io.Reader, size int64, contentType string) error
 	GetSignedUrl(fileName string) (string, error)
 	DeleteFile(fileName string) error
 	DownloadFile(fileName string) ([]byte, error)
+	Download(ctx context.Context, fileName string) (io.ReadCloser, int64, error)
 }
 
 // Ensure S3Provider implements IFileStorageProvider
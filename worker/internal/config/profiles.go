@@ -0,0 +1,175 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProfileName is the profile of the built-in defaults, used when no profiles file is configured
+const DefaultProfileName = "standard"
+
+// Fill modes for sources whose aspect ratio differs from the output (portrait or non-16:9)
+const (
+	FillModePad       = "pad"        // Scale to fit and add black bars
+	FillModeBlur      = "blur"       // Scale to fit over a blurred copy of the video scaled to cover
+	FillModeSmartCrop = "smart_crop" // Scale to cover and crop around the area with the most motion
+)
+
+// ProfilesConfig holds the named processing profiles. The API validates uploads with the
+// validation section of the same file and stores the profile name on the video
+type ProfilesConfig struct {
+	File           string                       // YAML or JSON profiles file (empty uses the built-in profile)
+	Default        string                       // Profile applied to videos without one
+	Profiles       map[string]ProcessingProfile // Processing settings by profile name
+	BumperCacheDir string                       // Bumpers normalised to each profile (empty uses <temp dir>/bumpers)
+}
+
+// ProcessingProfile holds the output settings of a profile
+type ProcessingProfile struct {
+	MaxOutputSeconds int    `yaml:"max_output_seconds"` // Content is trimmed to this length
+	TargetWidth      int    `yaml:"target_width"`
+	TargetHeight     int    `yaml:"target_height"`
+	VideoCodec       string `yaml:"video_codec"` // ffmpeg encoder, e.g. "libx264"
+	CRF              int    `yaml:"crf"`         // Constant rate factor, lower is better quality
+	FillMode         string `yaml:"fill_mode"`   // pad, blur or smart_crop
+	FrameRate        int    `yaml:"frame_rate"`  // Output frames per second, shared with the normalised bumpers
+	WatermarkPath    string `yaml:"watermark_path"`
+	IntroPath        string `yaml:"intro_path"`
+	OutroPath        string `yaml:"outro_path"`
+
+	BumperCrossfadeSeconds float64 `yaml:"bumper_crossfade_seconds"` // Crossfade between the bumpers and the video, 0 for hard cuts
+}
+
+// BuiltinProcessingProfile returns the settings of the built-in standard profile. Profiles read
+// from the file start from these values, so they only need the settings they change
+func BuiltinProcessingProfile() ProcessingProfile {
+	return ProcessingProfile{
+		MaxOutputSeconds: 30,
+		TargetWidth:      1280,
+		TargetHeight:     720,
+		VideoCodec:       "libx264",
+		CRF:              23,
+		FillMode:         FillModePad,
+		FrameRate:        30,
+		WatermarkPath:    "/app/assets/watermark.png",
+		IntroPath:        "/app/assets/intro.mp4",
+		OutroPath:        "/app/assets/outro.mp4",
+	}
+}
+
+// BuiltinProfiles returns the profiles used when no profiles file is configured
+func BuiltinProfiles() ProfilesConfig {
+	return ProfilesConfig{
+		Default:  DefaultProfileName,
+		Profiles: map[string]ProcessingProfile{DefaultProfileName: BuiltinProcessingProfile()},
+	}
+}
+
+// profilesFile is the layout of the profiles file shared with the API. Each profile has a
+// validation section (read by the API) and a processing section (read here)
+type profilesFile struct {
+	Default  string               `yaml:"default"`
+	Profiles map[string]yaml.Node `yaml:"profiles"`
+}
+
+// loadProfiles reads the profiles file, if any, and applies the environment overrides
+func loadProfiles(path string, defaultName string) (ProfilesConfig, error) {
+	profiles := BuiltinProfiles()
+	profiles.File = path
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return profiles, fmt.Errorf("failed to read profiles file: %w", err)
+		}
+
+		// YAML is a superset of JSON, so one decoder reads both formats
+		var file profilesFile
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return profiles, fmt.Errorf("failed to parse profiles file %s: %w", path, err)
+		}
+		if len(file.Profiles) == 0 {
+			return profiles, fmt.Errorf("profiles file %s does not define any profile", path)
+		}
+
+		profiles.Profiles = make(map[string]ProcessingProfile, len(file.Profiles))
+		for name, node := range file.Profiles {
+			section := struct {
+				Processing ProcessingProfile `yaml:"processing"`
+			}{Processing: BuiltinProcessingProfile()}
+			if err := node.Decode(&section); err != nil {
+				return profiles, fmt.Errorf("invalid profile %q: %w", name, err)
+			}
+			profiles.Profiles[name] = section.Processing
+		}
+		if file.Default != "" {
+			profiles.Default = file.Default
+		}
+	}
+
+	if defaultName != "" {
+		profiles.Default = defaultName
+	}
+
+	for name, profile := range profiles.Profiles {
+		applyProcessingOverrides(name, &profile)
+		if err := validateProfile(name, profile); err != nil {
+			return profiles, err
+		}
+		profiles.Profiles[name] = profile
+	}
+
+	if _, ok := profiles.Profiles[profiles.Default]; !ok {
+		return profiles, fmt.Errorf("default profile %q is not defined", profiles.Default)
+	}
+
+	return profiles, nil
+}
+
+// applyProcessingOverrides applies the PROFILE_<NAME>_<SETTING> environment variables of a profile
+func applyProcessingOverrides(name string, profile *ProcessingProfile) {
+	prefix := profileEnvPrefix(name)
+
+	profile.MaxOutputSeconds = getEnvInt(prefix+"MAX_OUTPUT_SECONDS", profile.MaxOutputSeconds)
+	profile.TargetWidth = getEnvInt(prefix+"TARGET_WIDTH", profile.TargetWidth)
+	profile.TargetHeight = getEnvInt(prefix+"TARGET_HEIGHT", profile.TargetHeight)
+	profile.VideoCodec = getEnv(prefix+"VIDEO_CODEC", profile.VideoCodec)
+	profile.CRF = getEnvInt(prefix+"CRF", profile.CRF)
+	profile.FillMode = getEnv(prefix+"FILL_MODE", profile.FillMode)
+	profile.FrameRate = getEnvInt(prefix+"FRAME_RATE", profile.FrameRate)
+	profile.WatermarkPath = getEnv(prefix+"WATERMARK_PATH", profile.WatermarkPath)
+	profile.IntroPath = getEnv(prefix+"INTRO_PATH", profile.IntroPath)
+	profile.OutroPath = getEnv(prefix+"OUTRO_PATH", profile.OutroPath)
+	profile.BumperCrossfadeSeconds = getEnvFloat(prefix+"BUMPER_CROSSFADE_SECONDS", profile.BumperCrossfadeSeconds)
+}
+
+// validateProfile rejects settings ffmpeg cannot produce
+func validateProfile(name string, profile ProcessingProfile) error {
+	switch {
+	case profile.MaxOutputSeconds <= 0:
+		return fmt.Errorf("profile %q: max_output_seconds must be greater than zero", name)
+	case profile.TargetWidth <= 0 || profile.TargetHeight <= 0:
+		return fmt.Errorf("profile %q: target_width and target_height must be greater than zero", name)
+	case profile.TargetWidth%2 != 0 || profile.TargetHeight%2 != 0:
+		return fmt.Errorf("profile %q: target_width and target_height must be even", name)
+	case profile.VideoCodec == "":
+		return fmt.Errorf("profile %q: video_codec is empty", name)
+	case profile.CRF < 0 || profile.CRF > 51:
+		return fmt.Errorf("profile %q: crf must be between 0 and 51", name)
+	case profile.FillMode != FillModePad && profile.FillMode != FillModeBlur && profile.FillMode != FillModeSmartCrop:
+		return fmt.Errorf("profile %q: fill_mode must be %s, %s or %s", name, FillModePad, FillModeBlur, FillModeSmartCrop)
+	case profile.FrameRate <= 0:
+		return fmt.Errorf("profile %q: frame_rate must be greater than zero", name)
+	case profile.BumperCrossfadeSeconds < 0:
+		return fmt.Errorf("profile %q: bumper_crossfade_seconds cannot be negative", name)
+	}
+	return nil
+}
+
+// profileEnvPrefix returns the environment variable prefix of a profile ("short-form" -> "PROFILE_SHORT_FORM_")
+func profileEnvPrefix(name string) string {
+	return "PROFILE_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name)) + "_"
+}
@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"worker/internal/config"
+)
+
+// messageProcessor runs one processing attempt for a message body (implemented by Pipeline)
+type messageProcessor interface {
+	ProcessMessage(ctx context.Context, body string, lastAttempt bool) error
+}
+
+// BatchHandler processes the records of an SQS event with the pipeline. Records run concurrently
+// in a bounded pool and only the failed ones are reported back (ReportBatchItemFailures), so
+// Lambda does not redeliver records that already succeeded
+type BatchHandler struct {
+	processor        messageProcessor
+	clock            Clock
+	concurrency      int
+	minRemainingTime time.Duration
+	maxReceiveCount  int
+}
+
+// NewBatchHandler creates a new Lambda batch handler
+func NewBatchHandler(pipeline *Pipeline, clock Clock, lambdaConfig *config.LambdaConfig) *BatchHandler {
+	concurrency := lambdaConfig.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &BatchHandler{
+		processor:        pipeline,
+		clock:            clock,
+		concurrency:      concurrency,
+		minRemainingTime: lambdaConfig.MinRemainingTime,
+		maxReceiveCount:  lambdaConfig.MaxReceiveCount,
+	}
+}
+
+// HandleEvent processes the batch and returns the IDs of the records that must be retried.
+// Records that are not started because the invocation is close to its deadline are reported
+// as failures too, so SQS delivers them again
+func (h *BatchHandler) HandleEvent(ctx context.Context, sqsEvent events.SQSEvent) events.SQSEventResponse {
+	log.Printf("Lambda invoked with %d messages", len(sqsEvent.Records))
+
+	var (
+		mu       sync.Mutex
+		failures []events.SQSBatchItemFailure
+		wg       sync.WaitGroup
+	)
+	fail := func(messageID string) {
+		mu.Lock()
+		defer mu.Unlock()
+		failures = append(failures, events.SQSBatchItemFailure{ItemIdentifier: messageID})
+	}
+
+	slots := make(chan struct{}, h.concurrency)
+	for _, record := range sqsEvent.Records {
+		// Wait for a free slot before checking the deadline, the wait may have used the time left
+		slots <- struct{}{}
+
+		if !h.hasTimeFor(ctx) {
+			<-slots
+			log.Printf("Not enough time left before the Lambda deadline, returning message %s to the queue", record.MessageId)
+			fail(record.MessageId)
+			continue
+		}
+
+		wg.Add(1)
+		go func(record events.SQSMessage) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			log.Printf("Processing message ID: %s", record.MessageId)
+
+			// Pipeline returns nil for permanent errors (no retry)
+			// Pipeline returns error for transient errors (Lambda retries through SQS redelivery)
+			if err := h.processor.ProcessMessage(ctx, record.Body, h.isLastAttempt(record)); err != nil {
+				log.Printf("Error processing message %s: %v", record.MessageId, err)
+				fail(record.MessageId)
+			}
+		}(record)
+	}
+	wg.Wait()
+
+	log.Printf("Processed %d messages, %d failed", len(sqsEvent.Records), len(failures))
+	return events.SQSEventResponse{BatchItemFailures: failures}
+}
+
+// hasTimeFor reports whether a new record can start before the invocation deadline
+func (h *BatchHandler) hasTimeFor(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return true
+	}
+	return deadline.Sub(h.clock.Now()) >= h.minRemainingTime
+}
+
+// isLastAttempt reports whether SQS moves the record to the dead-letter queue instead of
+// delivering it again if this attempt fails, so the pipeline marks the video as failed. Records
+// without a readable receive count are treated as retryable
+func (h *BatchHandler) isLastAttempt(record events.SQSMessage) bool {
+	if h.maxReceiveCount < 1 {
+		return false
+	}
+
+	count, err := strconv.Atoi(record.Attributes["ApproximateReceiveCount"])
+	if err != nil {
+		log.Printf("Warning: message %s has no valid ApproximateReceiveCount: %v", record.MessageId, err)
+		return false
+	}
+	return count >= h.maxReceiveCount
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProcessor fails the bodies listed in failing and records the maximum concurrency
+type fakeProcessor struct {
+	failing   map[string]bool
+	delay     time.Duration
+	running   atomic.Int32
+	maxActive atomic.Int32
+	mu        sync.Mutex
+	processed []string
+	last      []string
+}
+
+func (p *fakeProcessor) ProcessMessage(ctx context.Context, body string, lastAttempt bool) error {
+	active := p.running.Add(1)
+	defer p.running.Add(-1)
+	for {
+		current := p.maxActive.Load()
+		if active <= current || p.maxActive.CompareAndSwap(current, active) {
+			break
+		}
+	}
+	time.Sleep(p.delay)
+
+	p.mu.Lock()
+	p.processed = append(p.processed, body)
+	if lastAttempt {
+		p.last = append(p.last, body)
+	}
+	p.mu.Unlock()
+
+	if p.failing[body] {
+		return errors.New("transient error")
+	}
+	return nil
+}
+
+func sqsEvent(bodies ...string) events.SQSEvent {
+	var event events.SQSEvent
+	for _, body := range bodies {
+		event.Records = append(event.Records, events.SQSMessage{MessageId: "id-" + body, Body: body})
+	}
+	return event
+}
+
+func TestBatchHandler_ReportsOnlyFailedRecords(t *testing.T) {
+	processor := &fakeProcessor{failing: map[string]bool{"b": true, "d": true}, delay: 10 * time.Millisecond}
+	handler := &BatchHandler{processor: processor, clock: SystemClock{}, concurrency: 2}
+
+	response := handler.HandleEvent(context.Background(), sqsEvent("a", "b", "c", "d", "e"))
+
+	var failed []string
+	for _, failure := range response.BatchItemFailures {
+		failed = append(failed, failure.ItemIdentifier)
+	}
+	assert.ElementsMatch(t, []string{"id-b", "id-d"}, failed)
+	assert.Len(t, processor.processed, 5)
+	assert.Equal(t, int32(2), processor.maxActive.Load(), "records run concurrently within the pool size")
+}
+
+func TestBatchHandler_StopsStartingRecordsNearDeadline(t *testing.T) {
+	processor := &fakeProcessor{}
+	handler := &BatchHandler{processor: processor, clock: SystemClock{}, concurrency: 1, minRemainingTime: time.Minute}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	response := handler.HandleEvent(ctx, sqsEvent("a", "b"))
+
+	assert.Empty(t, processor.processed, "no record starts with less than minRemainingTime left")
+	assert.Equal(t, []events.SQSBatchItemFailure{{ItemIdentifier: "id-a"}, {ItemIdentifier: "id-b"}}, response.BatchItemFailures)
+}
+
+func TestBatchHandler_LastAttemptFromReceiveCount(t *testing.T) {
+	processor := &fakeProcessor{}
+	handler := &BatchHandler{processor: processor, clock: SystemClock{}, concurrency: 1, maxReceiveCount: 3}
+
+	event := sqsEvent("first", "third", "fourth", "unknown")
+	event.Records[0].Attributes = map[string]string{"ApproximateReceiveCount": "1"}
+	event.Records[1].Attributes = map[string]string{"ApproximateReceiveCount": "3"}
+	event.Records[2].Attributes = map[string]string{"ApproximateReceiveCount": "4"}
+
+	handler.HandleEvent(context.Background(), event)
+
+	assert.Equal(t, []string{"third", "fourth"}, processor.last, "the receive reaching maxReceiveCount is the last attempt")
+}
@@ -2,6 +2,7 @@ package messaging
 
 import (
 	"context"
+	"time"
 )
 
 // MessageQueue defines the interface for message queue operations
@@ -12,6 +13,10 @@ type MessageQueue interface {
 	// DeleteMessage removes a processed message from the queue
 	DeleteMessage(ctx context.Context, receiptHandle string) error
 
+	// ChangeMessageVisibility hides a received message for the given timeout from now, so it is
+	// not redelivered while it is still being processed
+	ChangeMessageVisibility(ctx context.Context, receiptHandle string, timeout time.Duration) error
+
 	// Close closes the connection to the message queue
 	Close() error
 }
@@ -0,0 +1,295 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"worker/internal/messaging"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// amqpPollInterval is how often an empty queue is checked again during long polling
+const amqpPollInterval = 500 * time.Millisecond
+
+// Delays between reconnection attempts after the broker connection is lost, doubled on each failure
+const (
+	amqpReconnectBaseDelay = 1 * time.Second
+	amqpReconnectMaxDelay  = 30 * time.Second
+)
+
+// AMQPQueue implements the MessageQueue interface using an AMQP 0.9.1 broker (RabbitMQ).
+// AMQP has no visibility timeout, so unacknowledged deliveries are tracked here and requeued
+// (nack with requeue) once their timeout expires, making them visible to consumers again.
+// When the connection or channel closes it reconnects with backoff; the broker requeues the
+// deliveries of the lost channel, so their receipt handles are no longer valid
+type AMQPQueue struct {
+	url               string
+	queueName         string
+	visibilityTimeout time.Duration
+
+	mu       sync.Mutex
+	conn     *amqp.Connection // nil while reconnecting
+	channel  *amqp.Channel
+	inFlight map[string]*amqpDelivery // Keyed by receipt handle
+	done     chan struct{}
+}
+
+type amqpDelivery struct {
+	delivery  amqp.Delivery
+	visibleAt time.Time
+}
+
+// NewAMQPQueue connects to the broker, declares the durable queue and starts requeueing
+// deliveries whose visibility timeout expired
+func NewAMQPQueue(url string, queueName string, visibilityTimeout time.Duration) (*AMQPQueue, error) {
+	q := &AMQPQueue{
+		url:               url,
+		queueName:         queueName,
+		visibilityTimeout: visibilityTimeout,
+		inFlight:          make(map[string]*amqpDelivery),
+		done:              make(chan struct{}),
+	}
+
+	closed, err := q.connect()
+	if err != nil {
+		return nil, err
+	}
+	go q.reconnectOnClose(closed)
+	go q.requeueExpired()
+
+	return q, nil
+}
+
+// connect dials the broker, opens the channel and declares the queue. The returned channel
+// receives a value when the connection or the channel closes
+func (q *AMQPQueue) connect() (<-chan *amqp.Error, error) {
+	conn, err := amqp.Dial(q.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to AMQP broker: %w", err)
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open AMQP channel: %w", err)
+	}
+
+	if _, err := channel.QueueDeclare(q.queueName, true, false, false, false, nil); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to declare AMQP queue: %w", err)
+	}
+
+	// Both notifications are forwarded to one channel, buffered so the library never blocks
+	closed := make(chan *amqp.Error, 2)
+	connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	channelClosed := channel.NotifyClose(make(chan *amqp.Error, 1))
+	go func() {
+		select {
+		case err := <-connClosed:
+			closed <- err
+		case err := <-channelClosed:
+			closed <- err
+		}
+	}()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		conn.Close() // Close was called while connecting
+		return nil, fmt.Errorf("AMQP queue closed")
+	default:
+	}
+	q.conn = conn
+	q.channel = channel
+
+	return closed, nil
+}
+
+// reconnectOnClose waits for the connection to be lost and connects again with exponential
+// backoff, until Close is called
+func (q *AMQPQueue) reconnectOnClose(closed <-chan *amqp.Error) {
+	for {
+		select {
+		case <-q.done:
+			return
+		case reason := <-closed:
+			select {
+			case <-q.done:
+				return // Closed by Close
+			default:
+			}
+			log.Printf("AMQP connection lost: %v - reconnecting", reason)
+		}
+
+		q.mu.Lock()
+		if q.conn != nil {
+			q.conn.Close() // The connection may still be open when only the channel closed
+		}
+		q.conn = nil
+		q.channel = nil
+		q.inFlight = make(map[string]*amqpDelivery) // Requeued by the broker with the channel
+		q.mu.Unlock()
+
+		delay := amqpReconnectBaseDelay
+		for {
+			select {
+			case <-q.done:
+				return
+			case <-time.After(delay):
+			}
+
+			var err error
+			if closed, err = q.connect(); err == nil {
+				log.Printf("Reconnected to AMQP broker")
+				break
+			}
+			delay = min(delay*2, amqpReconnectMaxDelay)
+			log.Printf("Failed to reconnect to AMQP broker, retrying in %v: %v", delay, err)
+		}
+	}
+}
+
+// ReceiveMessages gets up to maxMessages deliveries, polling for up to waitTimeSeconds
+func (q *AMQPQueue) ReceiveMessages(ctx context.Context, maxMessages int32, waitTimeSeconds int32) ([]*messaging.ReceivedMessage, error) {
+	deadline := time.Now().Add(time.Duration(waitTimeSeconds) * time.Second)
+
+	for {
+		messages, err := q.get(maxMessages)
+		if err != nil {
+			return nil, err
+		}
+		if len(messages) > 0 || !time.Now().Add(amqpPollInterval).Before(deadline) {
+			return messages, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(amqpPollInterval):
+		}
+	}
+}
+
+// get fetches the currently available deliveries without waiting
+func (q *AMQPQueue) get(maxMessages int32) ([]*messaging.ReceivedMessage, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.channel == nil {
+		return nil, fmt.Errorf("failed to receive message from AMQP: reconnecting to the broker")
+	}
+
+	var messages []*messaging.ReceivedMessage
+	for int32(len(messages)) < maxMessages {
+		delivery, ok, err := q.channel.Get(q.queueName, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive message from AMQP: %w", err)
+		}
+		if !ok {
+			break // Queue is empty
+		}
+
+		receiptHandle := strconv.FormatUint(delivery.DeliveryTag, 10)
+		q.inFlight[receiptHandle] = &amqpDelivery{
+			delivery:  delivery,
+			visibleAt: time.Now().Add(q.visibilityTimeout),
+		}
+
+		messageID := delivery.MessageId
+		if messageID == "" {
+			messageID = receiptHandle
+		}
+
+		messages = append(messages, &messaging.ReceivedMessage{
+			Body:          string(delivery.Body),
+			ReceiptHandle: receiptHandle,
+			MessageID:     messageID,
+		})
+	}
+
+	return messages, nil
+}
+
+// DeleteMessage acknowledges the delivery so the broker removes it
+func (q *AMQPQueue) DeleteMessage(ctx context.Context, receiptHandle string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	inFlight, ok := q.inFlight[receiptHandle]
+	if !ok {
+		return fmt.Errorf("failed to delete message: receipt handle is no longer valid")
+	}
+	delete(q.inFlight, receiptHandle)
+
+	if err := inFlight.delivery.Ack(false); err != nil {
+		return fmt.Errorf("failed to acknowledge AMQP message: %w", err)
+	}
+	return nil
+}
+
+// ChangeMessageVisibility postpones the requeue of an unacknowledged delivery
+func (q *AMQPQueue) ChangeMessageVisibility(ctx context.Context, receiptHandle string, timeout time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	inFlight, ok := q.inFlight[receiptHandle]
+	if !ok {
+		return fmt.Errorf("failed to change message visibility: receipt handle is no longer valid")
+	}
+	inFlight.visibleAt = time.Now().Add(timeout)
+	return nil
+}
+
+// requeueExpired returns deliveries to the queue once their visibility timeout expires
+func (q *AMQPQueue) requeueExpired() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.done:
+			return
+		case now := <-ticker.C:
+			q.mu.Lock()
+			for receiptHandle, inFlight := range q.inFlight {
+				if now.Before(inFlight.visibleAt) {
+					continue
+				}
+				delete(q.inFlight, receiptHandle)
+				if err := inFlight.delivery.Nack(false, true); err != nil {
+					// The broker requeues it anyway when the channel closes
+					fmt.Printf("Warning: Failed to requeue AMQP message %s: %v\n", receiptHandle, err)
+				}
+			}
+			q.mu.Unlock()
+		}
+	}
+}
+
+// Close stops the requeue and reconnection loops and closes the channel and connection. The
+// broker requeues any delivery that was not acknowledged
+func (q *AMQPQueue) Close() error {
+	close(q.done)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.conn == nil {
+		return nil // Lost and not reconnected
+	}
+	if err := q.channel.Close(); err != nil && err != amqp.ErrClosed {
+		q.conn.Close()
+		return fmt.Errorf("failed to close AMQP channel: %w", err)
+	}
+	if err := q.conn.Close(); err != nil && err != amqp.ErrClosed {
+		return err
+	}
+	return nil
+}
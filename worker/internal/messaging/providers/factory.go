@@ -0,0 +1,37 @@
+package providers
+
+import (
+	"fmt"
+
+	"worker/internal/config"
+	"worker/internal/database"
+	"worker/internal/messaging"
+)
+
+// NewMessageQueue creates the message queue for the provider selected in configuration
+func NewMessageQueue(cfg *config.Config, db *database.DB) (messaging.MessageQueue, error) {
+	switch cfg.Messaging.Provider {
+	case "sqs":
+		messageQueue, err := NewSQSQueue(&cfg.AWS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize SQS queue: %w", err)
+		}
+		return messageQueue, nil
+
+	case "memory":
+		return NewMemoryQueue(cfg.Messaging.VisibilityTimeout), nil
+
+	case "postgres":
+		return NewPostgresQueue(db, cfg.Messaging.QueueName, cfg.Messaging.VisibilityTimeout, cfg.Messaging.MaxReceiveCount), nil
+
+	case "amqp":
+		messageQueue, err := NewAMQPQueue(cfg.Messaging.AMQPURL, cfg.Messaging.QueueName, cfg.Messaging.VisibilityTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize AMQP queue: %w", err)
+		}
+		return messageQueue, nil
+
+	default:
+		return nil, fmt.Errorf("unknown messaging provider %q (expected sqs, memory, postgres or amqp)", cfg.Messaging.Provider)
+	}
+}
@@ -0,0 +1,162 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"worker/internal/messaging"
+)
+
+// MemoryQueue implements the MessageQueue interface in process. Received messages stay hidden
+// for the visibility timeout and become visible again if they are not deleted, like SQS
+type MemoryQueue struct {
+	mu                sync.Mutex
+	messages          []*memoryMessage
+	notify            chan struct{} // Signaled when a message is sent
+	visibilityTimeout time.Duration
+	nextID            int64
+}
+
+type memoryMessage struct {
+	id            string
+	body          string
+	receiptHandle string
+	receiveCount  int
+	visibleAt     time.Time
+}
+
+// NewMemoryQueue creates a new empty in-memory queue
+func NewMemoryQueue(visibilityTimeout time.Duration) *MemoryQueue {
+	return &MemoryQueue{
+		notify:            make(chan struct{}, 1),
+		visibilityTimeout: visibilityTimeout,
+	}
+}
+
+// SendMessage enqueues a message body, immediately visible to consumers
+func (q *MemoryQueue) SendMessage(ctx context.Context, body string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	q.nextID++
+	q.messages = append(q.messages, &memoryMessage{
+		id:        fmt.Sprintf("%d", q.nextID),
+		body:      body,
+		visibleAt: time.Now(),
+	})
+	q.mu.Unlock()
+
+	// Wake up a waiting receiver without blocking
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// ReceiveMessages waits up to waitTimeSeconds for visible messages and hides the returned
+// ones for the visibility timeout
+func (q *MemoryQueue) ReceiveMessages(ctx context.Context, maxMessages int32, waitTimeSeconds int32) ([]*messaging.ReceivedMessage, error) {
+	deadline := time.Now().Add(time.Duration(waitTimeSeconds) * time.Second)
+
+	for {
+		messages, nextVisible := q.claim(maxMessages)
+		if len(messages) > 0 {
+			return messages, nil
+		}
+
+		// Sleep until a message is sent, a hidden message becomes visible or the wait ends
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return nil, nil
+		}
+		if !nextVisible.IsZero() && time.Until(nextVisible) < wait {
+			wait = time.Until(nextVisible)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-q.notify:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// claim hides up to maxMessages visible messages and returns them, along with the time the
+// next hidden message becomes visible (zero if none)
+func (q *MemoryQueue) claim(maxMessages int32) ([]*messaging.ReceivedMessage, time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	var messages []*messaging.ReceivedMessage
+	var nextVisible time.Time
+
+	for _, msg := range q.messages {
+		if msg.visibleAt.After(now) {
+			if nextVisible.IsZero() || msg.visibleAt.Before(nextVisible) {
+				nextVisible = msg.visibleAt
+			}
+			continue
+		}
+		if int32(len(messages)) >= maxMessages {
+			continue
+		}
+
+		msg.receiveCount++
+		msg.receiptHandle = fmt.Sprintf("%s-%d", msg.id, msg.receiveCount)
+		msg.visibleAt = now.Add(q.visibilityTimeout)
+
+		messages = append(messages, &messaging.ReceivedMessage{
+			Body:          msg.body,
+			ReceiptHandle: msg.receiptHandle,
+			MessageID:     msg.id,
+		})
+	}
+
+	return messages, nextVisible
+}
+
+// DeleteMessage removes a message using the receipt handle of its latest receive
+func (q *MemoryQueue) DeleteMessage(ctx context.Context, receiptHandle string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, msg := range q.messages {
+		if msg.receiptHandle == receiptHandle {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("failed to delete message: receipt handle is no longer valid")
+}
+
+// ChangeMessageVisibility hides the message for the given timeout from now
+func (q *MemoryQueue) ChangeMessageVisibility(ctx context.Context, receiptHandle string, timeout time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, msg := range q.messages {
+		if msg.receiptHandle == receiptHandle {
+			msg.visibleAt = time.Now().Add(timeout)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("failed to change message visibility: receipt handle is no longer valid")
+}
+
+// Close closes the connection to the message queue
+func (q *MemoryQueue) Close() error {
+	// Nothing to release, pending messages are simply dropped
+	return nil
+}
@@ -0,0 +1,67 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryQueue_DeleteRemovesMessage(t *testing.T) {
+	queue := NewMemoryQueue(time.Minute)
+	ctx := context.Background()
+
+	require.NoError(t, queue.SendMessage(ctx, `{"s3_key":"original/1.mp4"}`))
+
+	messages, err := queue.ReceiveMessages(ctx, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, `{"s3_key":"original/1.mp4"}`, messages[0].Body)
+
+	// Hidden while in flight
+	messages2, err := queue.ReceiveMessages(ctx, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, messages2)
+
+	require.NoError(t, queue.DeleteMessage(ctx, messages[0].ReceiptHandle))
+	assert.Error(t, queue.DeleteMessage(ctx, messages[0].ReceiptHandle))
+}
+
+func TestMemoryQueue_VisibilityTimeout(t *testing.T) {
+	queue := NewMemoryQueue(50 * time.Millisecond)
+	ctx := context.Background()
+
+	require.NoError(t, queue.SendMessage(ctx, "body"))
+
+	first, err := queue.ReceiveMessages(ctx, 1, 0)
+	require.NoError(t, err)
+	require.Len(t, first, 1)
+
+	// Not deleted: it becomes visible again after the timeout, with a new receipt handle
+	second, err := queue.ReceiveMessages(ctx, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, second, 1)
+	assert.Equal(t, first[0].MessageID, second[0].MessageID)
+	assert.NotEqual(t, first[0].ReceiptHandle, second[0].ReceiptHandle)
+
+	// The stale handle can no longer delete the message
+	assert.Error(t, queue.DeleteMessage(ctx, first[0].ReceiptHandle))
+	assert.NoError(t, queue.DeleteMessage(ctx, second[0].ReceiptHandle))
+}
+
+func TestMemoryQueue_ReceiveWaitsForSend(t *testing.T) {
+	queue := NewMemoryQueue(time.Minute)
+	ctx := context.Background()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		queue.SendMessage(ctx, "late")
+	}()
+
+	messages, err := queue.ReceiveMessages(ctx, 10, 2)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "late", messages[0].Body)
+}
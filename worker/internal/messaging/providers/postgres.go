@@ -0,0 +1,173 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"worker/internal/database"
+	"worker/internal/messaging"
+)
+
+// postgresPollInterval is how often an empty queue is checked again during long polling
+const postgresPollInterval = 1 * time.Second
+
+// PostgresQueue implements the MessageQueue interface on the message_queue table. Messages are
+// claimed with SELECT ... FOR UPDATE SKIP LOCKED so concurrent workers never get the same row,
+// and stay hidden until visible_at (visibility timeout). Like an SQS redrive policy, a message
+// received maxReceiveCount times is dead-lettered instead of being delivered again
+type PostgresQueue struct {
+	db                *database.DB
+	queueName         string
+	visibilityTimeout time.Duration
+	maxReceiveCount   int // 0 delivers messages forever
+}
+
+// NewPostgresQueue creates a new Postgres-backed message queue
+func NewPostgresQueue(db *database.DB, queueName string, visibilityTimeout time.Duration, maxReceiveCount int) *PostgresQueue {
+	return &PostgresQueue{
+		db:                db,
+		queueName:         queueName,
+		visibilityTimeout: visibilityTimeout,
+		maxReceiveCount:   maxReceiveCount,
+	}
+}
+
+// ReceiveMessages claims up to maxMessages visible messages, polling for up to waitTimeSeconds
+func (q *PostgresQueue) ReceiveMessages(ctx context.Context, maxMessages int32, waitTimeSeconds int32) ([]*messaging.ReceivedMessage, error) {
+	deadline := time.Now().Add(time.Duration(waitTimeSeconds) * time.Second)
+
+	for {
+		messages, err := q.claim(ctx, maxMessages)
+		if err != nil {
+			return nil, err
+		}
+		if len(messages) > 0 || !time.Now().Add(postgresPollInterval).Before(deadline) {
+			return messages, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(postgresPollInterval):
+		}
+	}
+}
+
+// claim hides the next visible messages for the visibility timeout and returns them with a new receipt handle
+func (q *PostgresQueue) claim(ctx context.Context, maxMessages int32) ([]*messaging.ReceivedMessage, error) {
+	if err := q.deadLetterExhausted(ctx); err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE message_queue
+		SET receipt_handle = gen_random_uuid()::text,
+		    receive_count = receive_count + 1,
+		    visible_at = NOW() + make_interval(secs => $3)
+		WHERE id IN (
+			SELECT id FROM message_queue
+			WHERE queue_name = $1 AND visible_at <= NOW() AND dead_lettered_at IS NULL
+			ORDER BY id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, body, receipt_handle`
+
+	rows, err := q.db.QueryContext(ctx, query, q.queueName, maxMessages, q.visibilityTimeout.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive messages from Postgres queue: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*messaging.ReceivedMessage
+	for rows.Next() {
+		var id int64
+		msg := &messaging.ReceivedMessage{}
+		if err := rows.Scan(&id, &msg.Body, &msg.ReceiptHandle); err != nil {
+			return nil, fmt.Errorf("failed to scan message row: %w", err)
+		}
+		msg.MessageID = fmt.Sprintf("%d", id)
+		messages = append(messages, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating message rows: %w", err)
+	}
+
+	return messages, nil
+}
+
+// deadLetterExhausted moves the visible messages that already used all their receives out of the
+// queue. They were never deleted, so every attempt failed or the worker died processing them
+func (q *PostgresQueue) deadLetterExhausted(ctx context.Context) error {
+	if q.maxReceiveCount <= 0 {
+		return nil
+	}
+
+	query := `
+		UPDATE message_queue
+		SET dead_lettered_at = NOW()
+		WHERE queue_name = $1 AND visible_at <= NOW() AND dead_lettered_at IS NULL AND receive_count >= $2
+		RETURNING id`
+
+	rows, err := q.db.QueryContext(ctx, query, q.queueName, q.maxReceiveCount)
+	if err != nil {
+		return fmt.Errorf("failed to dead-letter messages in Postgres queue: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("failed to scan dead-lettered message: %w", err)
+		}
+		log.Printf("Message %d was received %d times without being processed, moved to dead letter", id, q.maxReceiveCount)
+	}
+	return rows.Err()
+}
+
+// DeleteMessage removes a message using the receipt handle of its latest receive
+func (q *PostgresQueue) DeleteMessage(ctx context.Context, receiptHandle string) error {
+	result, err := q.db.ExecContext(ctx, `DELETE FROM message_queue WHERE receipt_handle = $1`, receiptHandle)
+	if err != nil {
+		return fmt.Errorf("failed to delete message from Postgres queue: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("failed to delete message: receipt handle is no longer valid")
+	}
+
+	return nil
+}
+
+// ChangeMessageVisibility hides the message for the given timeout from now
+func (q *PostgresQueue) ChangeMessageVisibility(ctx context.Context, receiptHandle string, timeout time.Duration) error {
+	query := `UPDATE message_queue SET visible_at = NOW() + make_interval(secs => $2) WHERE receipt_handle = $1`
+
+	result, err := q.db.ExecContext(ctx, query, receiptHandle, timeout.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to change message visibility in Postgres queue: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("failed to change message visibility: receipt handle is no longer valid")
+	}
+
+	return nil
+}
+
+// Close closes the connection to the message queue
+func (q *PostgresQueue) Close() error {
+	// The database connection is owned by the caller
+	return nil
+}
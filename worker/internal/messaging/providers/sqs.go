@@ -3,6 +3,7 @@ package providers
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"worker/internal/config"
 	"worker/internal/messaging"
@@ -121,6 +122,20 @@ func (s *SQSQueue) DeleteMessage(ctx context.Context, receiptHandle string) erro
 	return nil
 }
 
+// ChangeMessageVisibility extends the visibility timeout of a received message
+func (s *SQSQueue) ChangeMessageVisibility(ctx context.Context, receiptHandle string, timeout time.Duration) error {
+	_, err := s.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(s.queueURL),
+		ReceiptHandle:     aws.String(receiptHandle),
+		VisibilityTimeout: int32(timeout.Seconds()),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to change message visibility in SQS: %w", err)
+	}
+
+	return nil
+}
+
 // Close closes the connection to the message queue
 func (s *SQSQueue) Close() error {
 	// SQS doesn't require explicit connection closing
@@ -0,0 +1,437 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"worker/internal/messaging"
+	"worker/internal/videos"
+)
+
+// VideoRepository is the part of videos.Repository used by the pipeline
+type VideoRepository interface {
+	GetVideoByID(videoID int) (*videos.Video, error)
+	StartProcessing(videoID int, startedAt time.Time) (bool, error)
+	MarkProcessed(videoID int, processedAt time.Time, duration time.Duration) error
+	MarkFailed(videoID int, reason string, duration time.Duration) error
+	MarkRetryPending(videoID int, reason string, duration time.Duration) error
+	MarkImagesGenerated(videoID int) error
+	SaveMetadata(videoID int, variant string, metadata *videos.Metadata) error
+}
+
+// VideoStorage is the part of ObjectStorage.FileStorageManager used by the pipeline
+type VideoStorage interface {
+	DownloadToFile(ctx context.Context, fileName string, path string) (int64, error)
+	UploadFromFile(ctx context.Context, fileName string, path string, contentType string) error
+}
+
+// Processor transforms a video file on disk (implemented by VideoProcessor)
+type Processor interface {
+	WithProfile(name string) (Processor, error)
+	SelectWindow(inputFile string, startOffset *float64) videos.TrimWindow
+	ProcessVideoFile(inputFile string, outputFile string, s3Key string, window videos.TrimWindow) error
+	GenerateHLS(sourceFile string, processedFile string, outputDir string) error
+	GenerateImages(sourceFile string, processedFile string, outputDir string, window videos.TrimWindow) error
+	VerifyOutput(outputFile string, window videos.TrimWindow) (*videos.Metadata, error)
+}
+
+// hlsMasterPlaylist is the HLS entry point, uploaded last so a master in storage means the set is complete
+const hlsMasterPlaylist = "master.m3u8"
+
+// Clock provides the current time, replaced in tests
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock backed by time.Now
+type SystemClock struct{}
+
+// Now returns the current time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Pipeline processes one video processing message: it validates the message and the video
+// status, runs the processor and records the outcome on the video. Both the queue worker and
+// the Lambda handler drive it, so they share the same behaviour
+type Pipeline struct {
+	repo      VideoRepository
+	storage   VideoStorage
+	processor Processor
+	clock     Clock
+	tempDir   string // Where the original and processed files are written while processing
+}
+
+// NewPipeline creates a new processing pipeline
+func NewPipeline(repo VideoRepository, storage VideoStorage, processor Processor, clock Clock, tempDir string) *Pipeline {
+	return &Pipeline{
+		repo:      repo,
+		storage:   storage,
+		processor: processor,
+		clock:     clock,
+		tempDir:   tempDir,
+	}
+}
+
+// ProcessMessage runs one processing attempt for the message body.
+// Returns nil when the message is done and can be deleted: processed, skipped (invalid message,
+// unknown or already processed video) or failed permanently.
+// Returns an error when it should be delivered again. lastAttempt tells the pipeline no retry
+// follows, so a transient failure marks the video as failed instead of queued
+func (p *Pipeline) ProcessMessage(ctx context.Context, body string, lastAttempt bool) error {
+	// Parse the message body
+	var videoMsg messaging.VideoProcessingMessage
+	if err := json.Unmarshal([]byte(body), &videoMsg); err != nil {
+		log.Printf("Failed to unmarshal message: %v - permanent error", err)
+		return nil // Don't retry invalid JSON - permanent error
+	}
+
+	// Extract video ID from S3 key
+	videoID := extractVideoIDFromS3Key(videoMsg.S3Key)
+	if videoID <= 0 {
+		log.Printf("Could not extract valid video ID from S3 key: %s - skipping processing", videoMsg.S3Key)
+		return nil // Skip processing, message will be deleted
+	}
+
+	// Get video from database - MUST exist for processing
+	video, err := p.repo.GetVideoByID(videoID)
+	if errors.Is(err, videos.ErrVideoNotFound) {
+		log.Printf("Video %d not found in database - skipping processing", videoID)
+		return nil // Skip processing if video doesn't exist in database
+	}
+	if err != nil {
+		return err // Retry - database errors are transient
+	}
+
+	// Check video status - only process if uploaded, queued or left in processing by an attempt that died
+	if video.Status == videos.StatusProcessed {
+		log.Printf("Video %d is already processed, skipping processing", videoID)
+		return nil
+	}
+
+	startedAt := p.clock.Now()
+	started, err := p.repo.StartProcessing(videoID, startedAt)
+	if err != nil {
+		return err // Retry - also when another attempt is running (*videos.AttemptRunningError)
+	}
+	if !started {
+		log.Printf("Video %d has status '%s', expected '%s', '%s' or an abandoned '%s' for processing - skipping",
+			videoID, video.Status, videos.StatusUploaded, videos.StatusQueued, videos.StatusProcessing)
+		return nil // Skip processing if failed, processed meanwhile or not uploaded yet
+	}
+
+	// Generate processed key (API sends "original/123.mov", we want "processed/123.mp4")
+	processedKey := generateProcessedS3Key(videoMsg.S3Key)
+	log.Printf("Processing video %d (attempt %d, profile %q): Original S3 key: %s -> Processed S3 key: %s",
+		videoID, video.Attempts+1, video.Profile, videoMsg.S3Key, processedKey)
+
+	// Apply the profile the API validated the video against
+	processor, err := p.processor.WithProfile(video.Profile)
+	if err == nil {
+		err = p.processStoredVideo(ctx, processor, videoID, video.StartOffset, videoMsg.S3Key, processedKey)
+	}
+	finishedAt := p.clock.Now()
+	duration := finishedAt.Sub(startedAt)
+	if err != nil {
+		return p.recordFailure(videoID, err, duration, lastAttempt)
+	}
+
+	// Update video status to processed
+	if err := p.repo.MarkProcessed(videoID, finishedAt, duration); err != nil {
+		log.Printf("Warning: failed to update video status: %v", err)
+		// Don't fail the entire process for DB update failure
+		// The video is processed and uploaded, status update is not critical
+	}
+
+	log.Printf("Successfully processed video %d in %v (Original: %s, Processed: %s)",
+		videoID, duration.Round(time.Millisecond), videoMsg.S3Key, processedKey)
+	log.Printf("Transformations applied (profile %q): best window trim, scale, fill, no audio, ANB watermark, ANB bumpers, HLS ladder, poster and previews", video.Profile)
+	return nil
+}
+
+// recordFailure stores the processing error on the video: failed when the error is permanent or
+// no retry follows (the message is done), queued again otherwise (the error is returned so the
+// message is retried)
+func (p *Pipeline) recordFailure(videoID int, processErr error, duration time.Duration, lastAttempt bool) error {
+	reason := failureReason(processErr)
+
+	if IsPermanentError(processErr) || lastAttempt {
+		log.Printf("Video %d failed, giving up: %v", videoID, processErr)
+		if err := p.repo.MarkFailed(videoID, reason, duration); err != nil {
+			log.Printf("Warning: failed to record processing failure for video %d: %v", videoID, err)
+		}
+		return nil
+	}
+
+	log.Printf("Video %d failed (will retry): %v", videoID, processErr)
+	if err := p.repo.MarkRetryPending(videoID, reason, duration); err != nil {
+		log.Printf("Warning: failed to record processing failure for video %d: %v", videoID, err)
+	}
+	return processErr
+}
+
+// processStoredVideo downloads the original video to a temp file, runs the processor on it and
+// uploads the processed file with its HLS renditions and images. Videos are streamed to and from
+// disk instead of being held in memory
+func (p *Pipeline) processStoredVideo(ctx context.Context, processor Processor, videoID int, startOffset *float64, originalKey string, processedKey string) error {
+	// Every attempt works in its own directory: a redelivered message may be processed while a
+	// previous attempt of the same video is still running, and must not touch its files
+	workDir, err := os.MkdirTemp(p.tempDir, "video-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer removeDir(workDir)
+
+	// The input keeps the extension of the original container (MP4, MOV, WebM or MKV), the
+	// output is always normalised to MP4
+	inputFile := filepath.Join(workDir, "input"+filepath.Ext(originalKey))
+	outputFile := filepath.Join(workDir, "final.mp4")
+
+	log.Printf("Downloading video file: %s", originalKey)
+	size, err := p.storage.DownloadToFile(ctx, originalKey, inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to download video from S3: %w", err)
+	}
+
+	// Pick the part of the source that is kept (the user's start_offset or the best scored window)
+	window := processor.SelectWindow(inputFile, startOffset)
+
+	// Process video with the processor
+	log.Printf("Processing video file (Size: %d bytes) - applying transformations", size)
+	if err := processor.ProcessVideoFile(inputFile, outputFile, originalKey, window); err != nil {
+		return fmt.Errorf("failed to process video: %w", err)
+	}
+
+	// Check what was actually produced before publishing it. A broken output (e.g. a concat with
+	// bumpers in another format) fails the same way on every attempt, so ErrOutputRejected is
+	// permanent; probe and read failures are retried
+	metadata, err := processor.VerifyOutput(outputFile, window)
+	if err != nil {
+		return err
+	}
+
+	// Upload processed video to processed/ location (keeping original in original/)
+	log.Printf("Uploading processed video to: %s", processedKey)
+	if err := p.storage.UploadFromFile(ctx, processedKey, outputFile, "video/mp4"); err != nil {
+		return fmt.Errorf("failed to upload processed video: %w", err)
+	}
+
+	// Record what was actually produced, the metadata is informative so failures only log
+	p.recordProcessedMetadata(videoID, metadata, window)
+
+	// Segment the processed video into the adaptive streaming ladder
+	hlsDir := filepath.Join(workDir, "hls")
+
+	log.Printf("Generating HLS renditions for: %s", processedKey)
+	if err := processor.GenerateHLS(inputFile, outputFile, hlsDir); err != nil {
+		return fmt.Errorf("failed to generate hls renditions: %w", err)
+	}
+
+	// The master playlist goes last, so players never see a master whose renditions are missing
+	if err := p.uploadDirectory(ctx, hlsDir, generateHLSPrefix(processedKey), hlsMasterPlaylist); err != nil {
+		return fmt.Errorf("failed to upload hls renditions: %w", err)
+	}
+
+	// Images are optional: the video is published without them if they cannot be generated
+	imagesDir := filepath.Join(workDir, "images")
+
+	if err := p.publishImages(ctx, processor, videoID, inputFile, outputFile, window, imagesDir, generateAssetPrefix(processedKey)); err != nil {
+		log.Printf("Warning: failed to generate images for video %d: %v", videoID, err)
+	}
+
+	return nil
+}
+
+// recordProcessedMetadata stores the verified metadata of the processed file in video_metadata
+// with the window of the source it was cut from
+func (p *Pipeline) recordProcessedMetadata(videoID int, metadata *videos.Metadata, window videos.TrimWindow) {
+	metadata.Trim = &window
+
+	if err := p.repo.SaveMetadata(videoID, videos.MetadataProcessed, metadata); err != nil {
+		log.Printf("Warning: failed to save processed metadata for video %d: %v", videoID, err)
+	}
+}
+
+// publishImages generates the poster, thumbnails and scrubbing preview, uploads them under prefix
+// and flags the video so the API returns their URLs
+func (p *Pipeline) publishImages(ctx context.Context, processor Processor, videoID int, inputFile string, outputFile string, window videos.TrimWindow, imagesDir string, prefix string) error {
+	if err := processor.GenerateImages(inputFile, outputFile, imagesDir, window); err != nil {
+		return err
+	}
+
+	if err := p.uploadDirectory(ctx, imagesDir, prefix, ""); err != nil {
+		return fmt.Errorf("failed to upload images: %w", err)
+	}
+
+	return p.repo.MarkImagesGenerated(videoID)
+}
+
+// uploadDirectory uploads the files in dir under prefix, keeping their relative paths. lastFile,
+// when set, must exist and is uploaded after every other file
+func (p *Pipeline) uploadDirectory(ctx context.Context, dir string, prefix string, lastFile string) error {
+	lastPath := ""
+	if lastFile != "" {
+		lastPath = filepath.Join(dir, lastFile)
+		if _, err := os.Stat(lastPath); err != nil {
+			return fmt.Errorf("%s not generated: %w", lastFile, err)
+		}
+	}
+
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && path != lastPath {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if lastPath != "" {
+		files = append(files, lastPath)
+	}
+
+	for _, path := range files {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		key := prefix + filepath.ToSlash(rel)
+		if err := p.storage.UploadFromFile(ctx, key, path, contentTypeFor(path)); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	log.Printf("Uploaded %d files to: %s", len(files), prefix)
+	return nil
+}
+
+// contentTypeFor returns the content type of a generated HLS or image file
+func contentTypeFor(path string) string {
+	switch filepath.Ext(path) {
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".ts":
+		return "video/mp2t"
+	case ".jpg":
+		return "image/jpeg"
+	case ".vtt":
+		return "text/vtt"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// removeDir deletes the temporary directory of an attempt with everything written in it
+func removeDir(path string) {
+	if err := os.RemoveAll(path); err != nil {
+		log.Printf("Warning: Failed to cleanup directory %s: %v", path, err)
+	}
+}
+
+// IsPermanentError checks if an error should not be retried
+func IsPermanentError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	errStr := err.Error()
+
+	// Video not found in database - permanent error
+	if strings.Contains(errStr, "not found in database") {
+		return true
+	}
+
+	// Video already processed - permanent error
+	if strings.Contains(errStr, "already processed") {
+		return true
+	}
+
+	// Invalid video format - permanent error
+	if strings.Contains(errStr, "invalid video format") || strings.Contains(errStr, "unsupported format") {
+		return true
+	}
+
+	// Processed output does not meet the profile - permanent error
+	if errors.Is(err, ErrOutputRejected) {
+		return true
+	}
+
+	// Invalid message format - permanent error
+	if strings.Contains(errStr, "failed to unmarshal") {
+		return true
+	}
+
+	// All other errors are considered transient and can be retried
+	return false
+}
+
+// maxFailureReasonLength bounds the error stored on the video (ffmpeg errors include its output)
+const maxFailureReasonLength = 1000
+
+// failureReason converts a processing error into the reason stored on the video
+func failureReason(err error) string {
+	reason := err.Error()
+	if len(reason) > maxFailureReasonLength {
+		reason = reason[:maxFailureReasonLength] + "..."
+	}
+	return strings.ToValidUTF8(reason, "")
+}
+
+// generateProcessedS3Key converts an original S3 key to a processed S3 key. Processed videos
+// are always MP4, whatever the container of the original
+// Example: "original/1.mov" -> "processed/1.mp4"
+func generateProcessedS3Key(originalS3Key string) string {
+	filename := strings.TrimSuffix(originalS3Key, filepath.Ext(originalS3Key)) + ".mp4"
+
+	// Remove "original/" prefix and add "processed/" prefix
+	if filename, found := strings.CutPrefix(filename, "original/"); found {
+		return fmt.Sprintf("processed/%s", filename)
+	}
+
+	// Fallback: if the key doesn't have "original/" prefix, just add "processed/" prefix
+	return fmt.Sprintf("processed/%s", filename)
+}
+
+// generateAssetPrefix returns the storage prefix of the files generated next to a processed video
+// Example: "processed/1.mp4" -> "processed/1/"
+func generateAssetPrefix(processedS3Key string) string {
+	return strings.TrimSuffix(processedS3Key, filepath.Ext(processedS3Key)) + "/"
+}
+
+// generateHLSPrefix returns the storage prefix of the HLS set of a processed video
+// Example: "processed/1.mp4" -> "processed/1/hls/"
+func generateHLSPrefix(processedS3Key string) string {
+	return generateAssetPrefix(processedS3Key) + "hls/"
+}
+
+// extractVideoIDFromS3Key extracts the video ID from an S3 key
+// Example: "original/123.mp4" -> 123
+func extractVideoIDFromS3Key(s3Key string) int {
+	// Remove path prefixes if present (e.g., "original/123.mp4" -> "123.mp4")
+	filename := s3Key
+	if lastSlash := strings.LastIndex(s3Key, "/"); lastSlash >= 0 {
+		filename = s3Key[lastSlash+1:]
+	}
+
+	// Extract ID from filename (e.g., "123.mp4" -> "123")
+	if dotIndex := strings.LastIndex(filename, "."); dotIndex > 0 {
+		idStr := filename[:dotIndex]
+		if videoID, err := strconv.Atoi(idStr); err == nil {
+			return videoID
+		}
+	}
+
+	return 0 // Return 0 if extraction fails
+}
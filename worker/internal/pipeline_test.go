@@ -0,0 +1,560 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"worker/internal/config"
+	"worker/internal/messaging"
+	"worker/internal/videos"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// Mock VideoRepository
+type MockVideoRepository struct {
+	mock.Mock
+}
+
+func (m *MockVideoRepository) GetVideoByID(videoID int) (*videos.Video, error) {
+	args := m.Called(videoID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*videos.Video), args.Error(1)
+}
+
+func (m *MockVideoRepository) StartProcessing(videoID int, startedAt time.Time) (bool, error) {
+	args := m.Called(videoID, startedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockVideoRepository) MarkProcessed(videoID int, processedAt time.Time, duration time.Duration) error {
+	args := m.Called(videoID, processedAt, duration)
+	return args.Error(0)
+}
+
+func (m *MockVideoRepository) MarkFailed(videoID int, reason string, duration time.Duration) error {
+	args := m.Called(videoID, reason, duration)
+	return args.Error(0)
+}
+
+func (m *MockVideoRepository) MarkRetryPending(videoID int, reason string, duration time.Duration) error {
+	args := m.Called(videoID, reason, duration)
+	return args.Error(0)
+}
+
+func (m *MockVideoRepository) MarkImagesGenerated(videoID int) error {
+	args := m.Called(videoID)
+	return args.Error(0)
+}
+
+func (m *MockVideoRepository) SaveMetadata(videoID int, variant string, metadata *videos.Metadata) error {
+	args := m.Called(videoID, variant, metadata)
+	return args.Error(0)
+}
+
+// Mock VideoStorage
+type MockVideoStorage struct {
+	mock.Mock
+}
+
+func (m *MockVideoStorage) DownloadToFile(ctx context.Context, fileName string, path string) (int64, error) {
+	args := m.Called(fileName)
+	return int64(args.Int(0)), args.Error(1)
+}
+
+func (m *MockVideoStorage) UploadFromFile(ctx context.Context, fileName string, path string, contentType string) error {
+	args := m.Called(fileName, contentType)
+	return args.Error(0)
+}
+
+// Mock VideoProcessor
+type MockVideoProcessor struct {
+	mock.Mock
+}
+
+func (m *MockVideoProcessor) WithProfile(name string) (Processor, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(Processor), args.Error(1)
+}
+
+func (m *MockVideoProcessor) SelectWindow(inputFile string, startOffset *float64) videos.TrimWindow {
+	args := m.Called(startOffset)
+	return args.Get(0).(videos.TrimWindow)
+}
+
+func (m *MockVideoProcessor) ProcessVideoFile(inputFile string, outputFile string, s3Key string, window videos.TrimWindow) error {
+	args := m.Called(s3Key, window)
+	return args.Error(0)
+}
+
+func (m *MockVideoProcessor) GenerateHLS(sourceFile string, processedFile string, outputDir string) error {
+	args := m.Called()
+	if err := args.Error(0); err != nil {
+		return err
+	}
+
+	// Write a minimal HLS set like ffmpeg does
+	return writeFiles(outputDir, map[string]string{
+		"360p/index.m3u8":     "#EXTM3U\nsegment_000.ts\n",
+		"360p/segment_000.ts": "ts",
+		"master.m3u8":         "#EXTM3U\n360p/index.m3u8\n",
+	})
+}
+
+func (m *MockVideoProcessor) GenerateImages(sourceFile string, processedFile string, outputDir string, window videos.TrimWindow) error {
+	args := m.Called()
+	if err := args.Error(0); err != nil {
+		return err
+	}
+
+	return writeFiles(outputDir, map[string]string{
+		"poster.jpg":             "jpg",
+		"preview/thumbnails.vtt": "WEBVTT\n",
+	})
+}
+
+func (m *MockVideoProcessor) VerifyOutput(outputFile string, window videos.TrimWindow) (*videos.Metadata, error) {
+	args := m.Called(window)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*videos.Metadata), args.Error(1)
+}
+
+// writeFiles creates the files (relative path -> content) inside dir
+func writeFiles(dir string, files map[string]string) error {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stepClock returns a time that advances by step on each call
+type stepClock struct {
+	now  time.Time
+	step time.Duration
+}
+
+func (c *stepClock) Now() time.Time {
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+// PipelineTestSuite covers the processing behaviour shared by the queue worker and the Lambda handler
+type PipelineTestSuite struct {
+	suite.Suite
+	repo      *MockVideoRepository
+	storage   *MockVideoStorage
+	processor *MockVideoProcessor
+	pipeline  *Pipeline
+	tempDir   string
+	start     time.Time
+}
+
+func (suite *PipelineTestSuite) SetupTest() {
+	suite.repo = new(MockVideoRepository)
+	suite.storage = new(MockVideoStorage)
+	suite.processor = new(MockVideoProcessor)
+	suite.start = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := &stepClock{now: suite.start, step: 1500 * time.Millisecond}
+	suite.tempDir = suite.T().TempDir()
+	suite.pipeline = NewPipeline(suite.repo, suite.storage, suite.processor, clock, suite.tempDir)
+}
+
+func (suite *PipelineTestSuite) TearDownTest() {
+	suite.repo.AssertExpectations(suite.T())
+	suite.storage.AssertExpectations(suite.T())
+	suite.processor.AssertExpectations(suite.T())
+}
+
+const testMessage = `{"s3_key":"original/7.mp4"}`
+
+// testWindow is the part of the 7.mp4 source kept by the processor
+var testWindow = videos.TrimWindow{Start: 12, End: 42, Selection: videos.TrimAuto}
+
+func (suite *PipelineTestSuite) expectStart(status string) {
+	suite.repo.On("GetVideoByID", 7).Return(&videos.Video{ID: 7, Status: status, Profile: "standard"}, nil).Once()
+	suite.repo.On("StartProcessing", 7, suite.start).Return(true, nil).Once()
+	suite.processor.On("WithProfile", "standard").Return(suite.processor, nil).Once()
+	suite.storage.On("DownloadToFile", "original/7.mp4").Return(1024, nil).Once()
+	suite.processor.On("SelectWindow", (*float64)(nil)).Return(testWindow).Once()
+}
+
+// expectProcessedUpload expects the processor to succeed and the processed file to be verified and
+// uploaded, returning the metadata saved for it
+func (suite *PipelineTestSuite) expectProcessedUpload() *videos.Metadata {
+	metadata := &videos.Metadata{Container: "mov,mp4,m4a,3gp,3g2,mj2", VideoCodec: "h264", Width: 1280, Height: 720}
+	suite.processor.On("ProcessVideoFile", "original/7.mp4", testWindow).Return(nil).Once()
+	suite.processor.On("VerifyOutput", testWindow).Return(metadata, nil).Once()
+	suite.storage.On("UploadFromFile", "processed/7.mp4", "video/mp4").Return(nil).Once()
+	suite.repo.On("SaveMetadata", 7, videos.MetadataProcessed, metadata).Return(nil).Once()
+	return metadata
+}
+
+func (suite *PipelineTestSuite) TestProcessesQueuedVideo() {
+	suite.expectStart(videos.StatusQueued)
+	metadata := suite.expectProcessedUpload()
+	suite.processor.On("GenerateHLS").Return(nil).Once()
+	suite.storage.On("UploadFromFile", "processed/7/hls/360p/index.m3u8", "application/vnd.apple.mpegurl").Return(nil).Once()
+	suite.storage.On("UploadFromFile", "processed/7/hls/360p/segment_000.ts", "video/mp2t").Return(nil).Once()
+	suite.storage.On("UploadFromFile", "processed/7/hls/master.m3u8", "application/vnd.apple.mpegurl").Return(nil).Once()
+	suite.processor.On("GenerateImages").Return(nil).Once()
+	suite.storage.On("UploadFromFile", "processed/7/poster.jpg", "image/jpeg").Return(nil).Once()
+	suite.storage.On("UploadFromFile", "processed/7/preview/thumbnails.vtt", "text/vtt").Return(nil).Once()
+	suite.repo.On("MarkImagesGenerated", 7).Return(nil).Once()
+	suite.repo.On("MarkProcessed", 7, suite.start.Add(1500*time.Millisecond), 1500*time.Millisecond).Return(nil).Once()
+
+	suite.NoError(suite.pipeline.ProcessMessage(context.Background(), testMessage, false))
+
+	// The master playlist is uploaded once the whole HLS set is in storage
+	var uploads []string
+	for _, call := range suite.storage.Calls {
+		if call.Method == "UploadFromFile" {
+			uploads = append(uploads, call.Arguments.String(0))
+		}
+	}
+	suite.Equal("processed/7/hls/master.m3u8", uploads[3])
+
+	// The processed metadata records the window of the source that was kept
+	suite.Equal(&testWindow, metadata.Trim)
+
+	// The directory of the attempt is removed with everything written in it
+	entries, err := os.ReadDir(suite.tempDir)
+	suite.NoError(err)
+	suite.Empty(entries)
+}
+
+func (suite *PipelineTestSuite) TestStartOffsetSelectsWindow() {
+	startOffset := 50.0
+	userWindow := videos.TrimWindow{Start: 30, End: 60, Selection: videos.TrimUser}
+	suite.repo.On("GetVideoByID", 7).Return(&videos.Video{ID: 7, Status: videos.StatusQueued, Profile: "standard", StartOffset: &startOffset}, nil).Once()
+	suite.repo.On("StartProcessing", 7, suite.start).Return(true, nil).Once()
+	suite.processor.On("WithProfile", "standard").Return(suite.processor, nil).Once()
+	suite.storage.On("DownloadToFile", "original/7.mp4").Return(1024, nil).Once()
+	suite.processor.On("SelectWindow", &startOffset).Return(userWindow).Once()
+	suite.processor.On("ProcessVideoFile", "original/7.mp4", userWindow).Return(errors.New("invalid video format")).Once()
+	suite.repo.On("MarkFailed", 7, "failed to process video: invalid video format", 1500*time.Millisecond).Return(nil).Once()
+
+	suite.NoError(suite.pipeline.ProcessMessage(context.Background(), testMessage, false))
+}
+
+func (suite *PipelineTestSuite) TestImageFailureStillProcessesVideo() {
+	suite.expectStart(videos.StatusQueued)
+	suite.expectProcessedUpload()
+	suite.processor.On("GenerateHLS").Return(nil).Once()
+	suite.storage.On("UploadFromFile", mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "processed/7/hls/")
+	}), mock.Anything).Return(nil).Times(3)
+	suite.processor.On("GenerateImages").Return(errors.New("poster generation failed: exit status 1")).Once()
+	suite.repo.On("MarkProcessed", 7, suite.start.Add(1500*time.Millisecond), 1500*time.Millisecond).Return(nil).Once()
+
+	suite.NoError(suite.pipeline.ProcessMessage(context.Background(), testMessage, false))
+	suite.repo.AssertNotCalled(suite.T(), "MarkImagesGenerated", 7)
+}
+
+func (suite *PipelineTestSuite) TestHLSFailureQueuesRetry() {
+	suite.expectStart(videos.StatusQueued)
+	suite.expectProcessedUpload()
+	suite.processor.On("GenerateHLS").Return(errors.New("exit status 1")).Once()
+	suite.repo.On("MarkRetryPending", 7, "failed to generate hls renditions: exit status 1", 1500*time.Millisecond).Return(nil).Once()
+
+	suite.Error(suite.pipeline.ProcessMessage(context.Background(), testMessage, false))
+}
+
+func (suite *PipelineTestSuite) TestUnknownProfileQueuesRetry() {
+	// The worker has not loaded the profiles file the API used, a redeploy can still fix it
+	suite.repo.On("GetVideoByID", 7).Return(&videos.Video{ID: 7, Status: videos.StatusQueued, Profile: "short-form"}, nil).Once()
+	suite.repo.On("StartProcessing", 7, suite.start).Return(true, nil).Once()
+	suite.processor.On("WithProfile", "short-form").Return(nil, errors.New(`unknown processing profile "short-form"`)).Once()
+	suite.repo.On("MarkRetryPending", 7, `unknown processing profile "short-form"`, 1500*time.Millisecond).Return(nil).Once()
+
+	suite.Error(suite.pipeline.ProcessMessage(context.Background(), testMessage, false))
+	suite.storage.AssertNotCalled(suite.T(), "DownloadToFile", "original/7.mp4")
+}
+
+func (suite *PipelineTestSuite) TestSkipsMessagesThatCannotBeProcessed() {
+	// Invalid JSON and keys without a video ID are permanent, the message is dropped
+	suite.NoError(suite.pipeline.ProcessMessage(context.Background(), "not json", false))
+	suite.NoError(suite.pipeline.ProcessMessage(context.Background(), `{"s3_key":"original/abc.mp4"}`, false))
+
+	suite.repo.On("GetVideoByID", 7).Return(nil, fmt.Errorf("failed to get video: %w", videos.ErrVideoNotFound)).Once()
+	suite.NoError(suite.pipeline.ProcessMessage(context.Background(), testMessage, false))
+
+	suite.repo.On("GetVideoByID", 7).Return(&videos.Video{ID: 7, Status: videos.StatusProcessed}, nil).Once()
+	suite.NoError(suite.pipeline.ProcessMessage(context.Background(), testMessage, false))
+
+	suite.repo.On("GetVideoByID", 7).Return(&videos.Video{ID: 7, Status: videos.StatusFailed}, nil).Once()
+	suite.repo.On("StartProcessing", 7, suite.start).Return(false, nil).Once()
+	suite.NoError(suite.pipeline.ProcessMessage(context.Background(), testMessage, false))
+}
+
+func (suite *PipelineTestSuite) TestDatabaseErrorIsRetried() {
+	suite.repo.On("GetVideoByID", 7).Return(nil, errors.New("connection refused")).Once()
+
+	suite.Error(suite.pipeline.ProcessMessage(context.Background(), testMessage, false))
+}
+
+func (suite *PipelineTestSuite) TestTransientFailureQueuesRetry() {
+	suite.expectStart(videos.StatusUploaded)
+	suite.processor.On("ProcessVideoFile", "original/7.mp4", testWindow).Return(errors.New("ffmpeg processing failed: signal: killed")).Once()
+	suite.repo.On("MarkRetryPending", 7, "failed to process video: ffmpeg processing failed: signal: killed", 1500*time.Millisecond).Return(nil).Once()
+
+	suite.Error(suite.pipeline.ProcessMessage(context.Background(), testMessage, false))
+}
+
+func (suite *PipelineTestSuite) TestTransientFailureOnLastAttemptFails() {
+	suite.expectStart(videos.StatusProcessing)
+	suite.processor.On("ProcessVideoFile", "original/7.mp4", testWindow).Return(nil).Once()
+	suite.processor.On("VerifyOutput", testWindow).Return(&videos.Metadata{}, nil).Once()
+	suite.storage.On("UploadFromFile", "processed/7.mp4", "video/mp4").Return(errors.New("timeout")).Once()
+	suite.repo.On("MarkFailed", 7, "failed to upload processed video: timeout", 1500*time.Millisecond).Return(nil).Once()
+
+	suite.NoError(suite.pipeline.ProcessMessage(context.Background(), testMessage, true))
+}
+
+func (suite *PipelineTestSuite) TestPermanentFailureIsNotRetried() {
+	suite.expectStart(videos.StatusQueued)
+	suite.processor.On("ProcessVideoFile", "original/7.mp4", testWindow).Return(errors.New("invalid video format")).Once()
+	suite.repo.On("MarkFailed", 7, "failed to process video: invalid video format", 1500*time.Millisecond).Return(nil).Once()
+
+	suite.NoError(suite.pipeline.ProcessMessage(context.Background(), testMessage, false))
+}
+
+func (suite *PipelineTestSuite) TestBrokenOutputIsNotPublished() {
+	suite.expectStart(videos.StatusQueued)
+	suite.processor.On("ProcessVideoFile", "original/7.mp4", testWindow).Return(nil).Once()
+	suite.processor.On("VerifyOutput", testWindow).Return(nil, rejectOutput("output codec is mpeg4, expected h264")).Once()
+	suite.repo.On("MarkFailed", 7, "output verification failed: output codec is mpeg4, expected h264", 1500*time.Millisecond).Return(nil).Once()
+
+	suite.NoError(suite.pipeline.ProcessMessage(context.Background(), testMessage, false))
+	suite.storage.AssertNotCalled(suite.T(), "UploadFromFile", "processed/7.mp4", "video/mp4")
+}
+
+func (suite *PipelineTestSuite) TestOutputProbeFailureQueuesRetry() {
+	suite.expectStart(videos.StatusQueued)
+	suite.processor.On("ProcessVideoFile", "original/7.mp4", testWindow).Return(nil).Once()
+	suite.processor.On("VerifyOutput", testWindow).Return(nil, errors.New("failed to probe output: signal: killed")).Once()
+	suite.repo.On("MarkRetryPending", 7, "failed to probe output: signal: killed", 1500*time.Millisecond).Return(nil).Once()
+
+	suite.Error(suite.pipeline.ProcessMessage(context.Background(), testMessage, false))
+	suite.storage.AssertNotCalled(suite.T(), "UploadFromFile", "processed/7.mp4", "video/mp4")
+}
+
+func (suite *PipelineTestSuite) TestWorkerRetriesThenGivesUp() {
+	service := NewWorkerService(nil, suite.pipeline, &config.RetryConfig{MaxRetries: 1, EnableBackoff: true}, &config.WorkerConfig{})
+
+	// First attempt is queued for retry, the second (last) one marks the video as failed
+	suite.repo.On("GetVideoByID", 7).Return(&videos.Video{ID: 7, Status: videos.StatusQueued}, nil).Twice()
+	suite.repo.On("StartProcessing", 7, mock.Anything).Return(true, nil).Twice()
+	suite.processor.On("WithProfile", "").Return(suite.processor, nil).Twice() // Videos created before profiles existed
+	suite.storage.On("DownloadToFile", "original/7.mp4").Return(0, errors.New("connection reset")).Twice()
+	suite.repo.On("MarkRetryPending", 7, "failed to download video from S3: connection reset", mock.Anything).Return(nil).Once()
+	suite.repo.On("MarkFailed", 7, "failed to download video from S3: connection reset", mock.Anything).Return(nil).Once()
+
+	msg := &messaging.ReceivedMessage{MessageID: "1", Body: testMessage}
+	suite.NoError(service.processMessageWithRetry(context.Background(), msg))
+}
+
+// Run the test suite
+func TestPipelineTestSuite(t *testing.T) {
+	suite.Run(t, new(PipelineTestSuite))
+}
+
+// TestIsPermanentError tests the error classification logic
+func TestIsPermanentError(t *testing.T) {
+	tests := []struct {
+		name     string
+		error    error
+		expected bool
+	}{
+		{
+			name:     "Video not found in database",
+			error:    errors.New("video not found in database"),
+			expected: true,
+		},
+		{
+			name:     "Already processed",
+			error:    errors.New("video already processed"),
+			expected: true,
+		},
+		{
+			name:     "Invalid video format",
+			error:    errors.New("invalid video format"),
+			expected: true,
+		},
+		{
+			name:     "Output verification",
+			error:    rejectOutput("output has an audio stream (aac)"),
+			expected: true,
+		},
+		{
+			name:     "Output probe error (temporary)",
+			error:    errors.New("failed to probe output: signal: killed"),
+			expected: false,
+		},
+		{
+			name:     "Network error (temporary)",
+			error:    errors.New("network timeout"),
+			expected: false,
+		},
+		{
+			name:     "S3 error (temporary)",
+			error:    errors.New("failed to upload to S3"),
+			expected: false,
+		},
+		{
+			name:     "Processing error (temporary)",
+			error:    errors.New("ffmpeg processing failed"),
+			expected: false,
+		},
+		{
+			name:     "Nil error",
+			error:    nil,
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := IsPermanentError(tt.error)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+// TestExtractVideoIDFromS3Key tests the S3 key parsing logic
+func TestExtractVideoIDFromS3Key(t *testing.T) {
+	tests := []struct {
+		name     string
+		s3Key    string
+		expected int
+	}{
+		{
+			name:     "Original path with ID",
+			s3Key:    "original/123.mp4",
+			expected: 123,
+		},
+		{
+			name:     "Processed path with ID",
+			s3Key:    "processed/456.mp4",
+			expected: 456,
+		},
+		{
+			name:     "Simple filename",
+			s3Key:    "789.mp4",
+			expected: 789,
+		},
+		{
+			name:     "Single digit ID",
+			s3Key:    "original/1.mp4",
+			expected: 1,
+		},
+		{
+			name:     "Large ID",
+			s3Key:    "original/999999.mp4",
+			expected: 999999,
+		},
+		{
+			name:     "Invalid format - no extension",
+			s3Key:    "original/123",
+			expected: 0,
+		},
+		{
+			name:     "Invalid format - no ID",
+			s3Key:    "original/video.mp4",
+			expected: 0,
+		},
+		{
+			name:     "Empty key",
+			s3Key:    "",
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := extractVideoIDFromS3Key(tt.s3Key)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+// TestGenerateProcessedS3Key tests the S3 key transformation logic
+func TestGenerateProcessedS3Key(t *testing.T) {
+	tests := []struct {
+		name        string
+		originalKey string
+		expected    string
+	}{
+		{
+			name:        "Original prefix",
+			originalKey: "original/123.mp4",
+			expected:    "processed/123.mp4",
+		},
+		{
+			name:        "No original prefix",
+			originalKey: "123.mp4",
+			expected:    "processed/123.mp4",
+		},
+		{
+			name:        "Complex filename",
+			originalKey: "original/video_123_final.mp4",
+			expected:    "processed/video_123_final.mp4",
+		},
+		{
+			name:        "Single digit",
+			originalKey: "original/1.mp4",
+			expected:    "processed/1.mp4",
+		},
+		{
+			name:        "QuickTime original",
+			originalKey: "original/7.mov",
+			expected:    "processed/7.mp4",
+		},
+		{
+			name:        "Matroska original",
+			originalKey: "original/7.mkv",
+			expected:    "processed/7.mp4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := generateProcessedS3Key(tt.originalKey)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestGenerateHLSPrefix(t *testing.T) {
+	assert.Equal(t, "processed/1/hls/", generateHLSPrefix("processed/1.mp4"))
+	assert.Equal(t, "processed/123/hls/", generateHLSPrefix("processed/123.mp4"))
+}
+
+func TestFailureReason(t *testing.T) {
+	assert.Equal(t, "failed to process video: exit status 1", failureReason(errors.New("failed to process video: exit status 1")))
+
+	long := failureReason(errors.New(strings.Repeat("x", maxFailureReasonLength+50)))
+	assert.Len(t, long, maxFailureReasonLength+3)
+	assert.True(t, strings.HasSuffix(long, "..."))
+}
@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"worker/internal/videos"
+)
+
+// probeOutput is the part of the ffprobe JSON output read by Probe
+type probeOutput struct {
+	Streams []probeStream `json:"streams"`
+	Format  struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+type probeStream struct {
+	CodecType    string `json:"codec_type"`
+	CodecName    string `json:"codec_name"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	AvgFrameRate string `json:"avg_frame_rate"`
+	RFrameRate   string `json:"r_frame_rate"`
+	Tags         struct {
+		Rotate string `json:"rotate"` // Older ffmpeg versions report rotation as a tag
+	} `json:"tags"`
+	SideDataList []struct {
+		Rotation int `json:"rotation"` // Display matrix rotation, counter-clockwise degrees
+	} `json:"side_data_list"`
+}
+
+// Probe reads the container, stream details and checksum of a video file with ffprobe
+func (vp *VideoProcessor) Probe(file string) (*videos.Metadata, error) {
+	output, err := exec.Command(vp.config.FFprobePath,
+		"-v", "quiet",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		file,
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var probe probeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	metadata, err := metadataFromProbe(&probe)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata.Size, metadata.Checksum, err = fileSizeAndChecksum(file)
+	if err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// metadataFromProbe extracts the metadata of the first video and audio streams
+func metadataFromProbe(probe *probeOutput) (*videos.Metadata, error) {
+	metadata := &videos.Metadata{Container: probe.Format.FormatName}
+
+	var videoStream *probeStream
+	for i := range probe.Streams {
+		stream := &probe.Streams[i]
+		switch {
+		case stream.CodecType == "video" && videoStream == nil:
+			videoStream = stream
+		case stream.CodecType == "audio" && !metadata.HasAudio:
+			metadata.HasAudio = true
+			metadata.AudioCodec = stream.CodecName
+		}
+	}
+	if videoStream == nil {
+		return nil, fmt.Errorf("no video stream found in file")
+	}
+
+	metadata.VideoCodec = videoStream.CodecName
+	metadata.Width = videoStream.Width
+	metadata.Height = videoStream.Height
+	metadata.Rotation = streamRotation(videoStream)
+	metadata.FPS = parseFrameRate(videoStream.AvgFrameRate)
+	if metadata.FPS == 0 {
+		metadata.FPS = parseFrameRate(videoStream.RFrameRate)
+	}
+	metadata.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	metadata.Bitrate, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+
+	return metadata, nil
+}
+
+// parseFrameRate converts an ffprobe rational frame rate ("30000/1001") to frames per second
+func parseFrameRate(rate string) float64 {
+	num, den, found := strings.Cut(rate, "/")
+	numerator, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !found {
+		return numerator
+	}
+
+	denominator, err := strconv.ParseFloat(den, 64)
+	if err != nil || denominator == 0 {
+		return 0
+	}
+	return numerator / denominator
+}
+
+// streamRotation returns the clockwise rotation players apply to the stream (0, 90, 180 or 270)
+func streamRotation(stream *probeStream) int {
+	rotation := 0
+	if stream.Tags.Rotate != "" {
+		rotation, _ = strconv.Atoi(stream.Tags.Rotate)
+	} else {
+		for _, sideData := range stream.SideDataList {
+			if sideData.Rotation != 0 {
+				rotation = -sideData.Rotation // The display matrix is counter-clockwise
+				break
+			}
+		}
+	}
+	return ((rotation % 360) + 360) % 360
+}
+
+// fileSizeAndChecksum returns the size and SHA-256 checksum of a file
+func fileSizeAndChecksum(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to compute checksum: %w", err)
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// sourceGeometry is the coded size and display rotation of the first video stream
+type sourceGeometry struct {
+	Width    int
+	Height   int
+	Rotation int // Clockwise display rotation (0, 90, 180, 270)
+}
+
+// displaySize returns the size the video is shown at. ffmpeg applies the rotation when decoding
+// (autorotate), so the filters see frames of this size
+func (g sourceGeometry) displaySize() (int, int) {
+	if g.Rotation == 90 || g.Rotation == 270 {
+		return g.Height, g.Width
+	}
+	return g.Width, g.Height
+}
+
+// probeGeometry reads the size and rotation of the first video stream with ffprobe
+func (vp *VideoProcessor) probeGeometry(file string) (sourceGeometry, error) {
+	output, err := exec.Command(vp.config.FFprobePath,
+		"-v", "quiet",
+		"-print_format", "json",
+		"-select_streams", "v:0",
+		"-show_streams",
+		file,
+	).Output()
+	if err != nil {
+		return sourceGeometry{}, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var probe probeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return sourceGeometry{}, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	if len(probe.Streams) == 0 {
+		return sourceGeometry{}, fmt.Errorf("no video stream found in file")
+	}
+
+	stream := &probe.Streams[0]
+	return sourceGeometry{Width: stream.Width, Height: stream.Height, Rotation: streamRotation(stream)}, nil
+}
@@ -0,0 +1,290 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// outputTimescale is the MP4 track timescale of the processed videos and the normalised bumpers.
+// 90000 is a multiple of the common frame rates, so every frame lands on an exact tick
+const outputTimescale = "90000"
+
+// bumperPair holds the intro and outro used for a profile
+type bumperPair struct {
+	Intro string
+	Outro string
+}
+
+// PrepareBumpers re-encodes the intro and outro of every profile to its output settings (size,
+// codec, CRF, frame rate, timebase, no audio), so they can be joined to the processed videos
+// without re-encoding. The results are cached in BumperCacheDir under a name derived from the
+// asset content and the settings, so restarts reuse them and a changed asset or profile gets a
+// new file. Profiles whose bumpers cannot be normalised keep the original assets, which
+// addBumpers re-encodes together with each video. Call it once at startup, before processing
+func (vp *VideoProcessor) PrepareBumpers() {
+	if err := os.MkdirAll(vp.config.BumperCacheDir, 0755); err != nil {
+		log.Printf("Warning: failed to create bumper cache directory: %v - using the original bumpers", err)
+		return
+	}
+
+	names := make([]string, 0, len(vp.profiles.Profiles))
+	for name := range vp.profiles.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	prepared := make(map[string]bumperPair, len(names))
+	for _, name := range names {
+		processor := *vp
+		processor.bumpers = nil
+		processor.applyProfile(name, vp.profiles.Profiles[name])
+		if !processor.bumpersExist() {
+			continue
+		}
+
+		intro, err := processor.normalizeBumper(processor.config.IntroPath)
+		if err != nil {
+			log.Printf("Warning: failed to normalise intro of profile %s: %v - using the original bumpers", name, err)
+			continue
+		}
+		outro, err := processor.normalizeBumper(processor.config.OutroPath)
+		if err != nil {
+			log.Printf("Warning: failed to normalise outro of profile %s: %v - using the original bumpers", name, err)
+			continue
+		}
+		prepared[name] = bumperPair{Intro: intro, Outro: outro}
+	}
+
+	vp.bumpers = prepared
+	vp.applyProfile(vp.profile, vp.profiles.Profiles[vp.profile])
+	log.Printf("Bumpers normalised for %d of %d profiles in %s", len(prepared), len(names), vp.config.BumperCacheDir)
+}
+
+// normalizeBumper returns the cached copy of a bumper encoded with the output settings of the
+// profile, encoding it first when it is not cached yet
+func (vp *VideoProcessor) normalizeBumper(source string) (string, error) {
+	_, checksum, err := fileSizeAndChecksum(source)
+	if err != nil {
+		return "", err
+	}
+
+	cached := filepath.Join(vp.config.BumperCacheDir, vp.bumperCacheName(source, checksum))
+	if vp.fileExists(cached) {
+		log.Printf("Using cached bumper %s", cached)
+		return cached, nil
+	}
+
+	// Encode to a temporary file and rename it, so a crash or a concurrent worker never leaves a
+	// partial file under the cached name
+	temp, err := os.CreateTemp(vp.config.BumperCacheDir, "bumper_*.mp4")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	temp.Close()
+	defer vp.cleanupFile(temp.Name())
+
+	args := []string{
+		"-i", source,
+		"-vf", vp.normalizeFilter(),
+		"-an", // Processed videos have no audio
+		"-c:v", vp.config.VideoCodec,
+		"-crf", vp.config.VideoQuality,
+		"-preset", "medium",
+		"-pix_fmt", "yuv420p",
+		"-video_track_timescale", outputTimescale,
+		"-movflags", "+faststart",
+		"-y",
+		temp.Name(),
+	}
+
+	log.Printf("Normalising bumper %s: %s", source, strings.Join(args, " "))
+
+	cmd := exec.Command(vp.config.FFmpegPath, args...)
+	cmd.Stderr = os.Stderr
+
+	if err := vp.executeWithTimeout(cmd, 2*time.Minute); err != nil {
+		return "", fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	if err := os.Rename(temp.Name(), cached); err != nil {
+		return "", fmt.Errorf("failed to store normalised bumper: %w", err)
+	}
+	return cached, nil
+}
+
+// bumperCacheName names the normalised copy of a bumper after the asset and a hash of its
+// content and of the output settings it is encoded with ("intro_3f2a9c81d04e6b57.mp4")
+func (vp *VideoProcessor) bumperCacheName(source string, checksum string) string {
+	settings := fmt.Sprintf("%s|%dx%d|%s|%s|%d|%s", checksum, vp.config.TargetWidth, vp.config.TargetHeight,
+		vp.config.VideoCodec, vp.config.VideoQuality, vp.config.FrameRate, outputTimescale)
+	hash := sha256.Sum256([]byte(settings))
+
+	name := strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+	return fmt.Sprintf("%s_%s.mp4", name, hex.EncodeToString(hash[:8]))
+}
+
+// normalizeFilter fits a video into the output frame with black bars and converts it to the
+// output frame rate and pixel format
+func (vp *VideoProcessor) normalizeFilter() string {
+	width, height := vp.config.TargetWidth, vp.config.TargetHeight
+	return fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2:black,setsar=1,fps=%d,format=yuv420p",
+		width, height, width, height, vp.config.FrameRate)
+}
+
+// addBumpers joins intro + processed video + outro into outputFile. Bumpers whose streams match
+// the video (the ones normalised by PrepareBumpers) are joined without re-encoding; otherwise,
+// or when the profile crossfades, the three inputs are re-encoded with the concat filter
+func (vp *VideoProcessor) addBumpers(processedVideoFile, outputFile string) error {
+	log.Printf("Concatenating: intro + video + outro")
+
+	if vp.config.BumperCrossfade == 0 {
+		match, err := vp.streamsMatch(vp.config.IntroPath, processedVideoFile, vp.config.OutroPath)
+		switch {
+		case err != nil:
+			log.Printf("Warning: could not compare bumper streams: %v - re-encoding", err)
+		case !match:
+			log.Printf("Bumper streams differ from the video - re-encoding with the concat filter")
+		default:
+			err := vp.concatCopy(processedVideoFile, outputFile)
+			if err == nil {
+				return nil
+			}
+			log.Printf("Warning: stream copy concatenation failed: %v - re-encoding", err)
+		}
+	}
+
+	return vp.concatReencode(processedVideoFile, outputFile)
+}
+
+// streamsMatch reports whether the files have the same streams (codec, profile, size, pixel
+// format, frame rate and timebase), which the concat demuxer needs to copy them
+func (vp *VideoProcessor) streamsMatch(files ...string) (bool, error) {
+	var first string
+	for i, file := range files {
+		signature, err := vp.probe(file, "-show_entries",
+			"stream=codec_type,codec_name,profile,width,height,pix_fmt,r_frame_rate,time_base")
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", file, err)
+		}
+		if i == 0 {
+			first = signature
+		} else if signature != first {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// concatCopy joins the bumpers and the video with the concat demuxer, copying the streams
+func (vp *VideoProcessor) concatCopy(processedVideoFile, outputFile string) error {
+	// Create concat list file for FFmpeg, next to the output so it belongs to the directory of
+	// this attempt
+	concatFile := filepath.Join(filepath.Dir(outputFile), "concat_"+filepath.Base(outputFile)+".txt")
+	defer vp.cleanupFile(concatFile)
+
+	concatContent := fmt.Sprintf("file '%s'\nfile '%s'\nfile '%s'\n",
+		vp.config.IntroPath, processedVideoFile, vp.config.OutroPath)
+
+	if err := os.WriteFile(concatFile, []byte(concatContent), 0644); err != nil {
+		return fmt.Errorf("failed to create concat file: %w", err)
+	}
+
+	// Execute FFmpeg concat command
+	args := []string{
+		"-f", "concat",
+		"-safe", "0",
+		"-i", concatFile,
+		"-c", "copy", // Copy streams without re-encoding for speed
+		"-movflags", "+faststart",
+		"-y",
+		outputFile,
+	}
+
+	log.Printf("Executing FFmpeg concatenation: %s %s", vp.config.FFmpegPath, strings.Join(args, " "))
+
+	cmd := exec.Command(vp.config.FFmpegPath, args...)
+	cmd.Stderr = os.Stderr
+
+	return vp.executeWithTimeout(cmd, 2*time.Minute)
+}
+
+// concatReencode joins the bumpers and the video with the concat filter (or xfade when the
+// profile crossfades), normalising each input to the output settings first
+func (vp *VideoProcessor) concatReencode(processedVideoFile, outputFile string) error {
+	inputs := []string{vp.config.IntroPath, processedVideoFile, vp.config.OutroPath}
+
+	crossfade := vp.config.BumperCrossfade
+	var durations []float64
+	if crossfade > 0 {
+		for _, input := range inputs {
+			duration, err := vp.probeDuration(input)
+			if err != nil {
+				log.Printf("Warning: could not read duration of %s: %v - joining without crossfade", input, err)
+				crossfade = 0
+				break
+			}
+			durations = append(durations, duration)
+		}
+		if crossfade > 0 && crossfade >= slices.Min(durations) {
+			log.Printf("Warning: crossfade of %.1fs is not shorter than every input (%v) - joining without crossfade", crossfade, durations)
+			crossfade = 0
+		}
+	}
+
+	args := []string{
+		"-i", inputs[0],
+		"-i", inputs[1],
+		"-i", inputs[2],
+		"-filter_complex", vp.buildBumperFilter(durations, crossfade),
+		"-map", "[joined]",
+		"-an", // Bumper audio is dropped like the video's
+		"-c:v", vp.config.VideoCodec,
+		"-crf", vp.config.VideoQuality,
+		"-preset", "medium",
+		"-pix_fmt", "yuv420p",
+		"-video_track_timescale", outputTimescale,
+		"-movflags", "+faststart",
+		"-y",
+		outputFile,
+	}
+
+	log.Printf("Executing FFmpeg concat filter: %s %s", vp.config.FFmpegPath, strings.Join(args, " "))
+
+	cmd := exec.Command(vp.config.FFmpegPath, args...)
+	cmd.Stderr = os.Stderr
+
+	return vp.executeWithTimeout(cmd, 5*time.Minute)
+}
+
+// buildBumperFilter builds the filter joining inputs 0 (intro), 1 (video) and 2 (outro) into
+// [joined]. With a crossfade each join overlaps the inputs by that many seconds, which needs
+// the durations of the inputs; the output is 2 crossfades shorter than the inputs together
+func (vp *VideoProcessor) buildBumperFilter(durations []float64, crossfade float64) string {
+	var parts []string
+	for i := 0; i < 3; i++ {
+		// settb gives the three inputs the same timebase, which xfade requires
+		parts = append(parts, fmt.Sprintf("[%d:v]%s,settb=AVTB[v%d]", i, vp.normalizeFilter(), i))
+	}
+
+	if crossfade == 0 {
+		parts = append(parts, "[v0][v1][v2]concat=n=3:v=1:a=0[joined]")
+		return strings.Join(parts, ";")
+	}
+
+	introEnd := durations[0] - crossfade
+	videoEnd := durations[0] + durations[1] - 2*crossfade
+	fade := strconv.FormatFloat(crossfade, 'f', 3, 64)
+	parts = append(parts,
+		fmt.Sprintf("[v0][v1]xfade=transition=fade:duration=%s:offset=%.3f[intro]", fade, introEnd),
+		fmt.Sprintf("[intro][v2]xfade=transition=fade:duration=%s:offset=%.3f[joined]", fade, videoEnd),
+	)
+	return strings.Join(parts, ";")
+}
@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"worker/internal/config"
+	"worker/internal/videos"
+)
+
+// Motion analysis used by the smart_crop fill mode: the source is sampled at focusSampleFPS
+// frames per second, downscaled to a focusGridSize x focusGridSize grayscale grid
+const (
+	focusSampleFPS = 2
+	focusGridSize  = 64
+	focusMinDiff   = 8 // Luma difference below this is treated as noise
+)
+
+// aspectTolerance is how far the source aspect ratio can be from the output one before the
+// fill mode matters (a 1920x1088 source is still 16:9)
+const aspectTolerance = 0.01
+
+// focusPoint is the centre of interest of a video as fractions of the frame (0.5, 0.5 is the centre)
+type focusPoint struct {
+	X float64
+	Y float64
+}
+
+// centreFocus is used when no motion is detected or the analysis fails
+var centreFocus = focusPoint{X: 0.5, Y: 0.5}
+
+// buildFillFilter returns the filter chain that places the source into the output frame, from
+// [0:v] to [scaled]. Sources with the output aspect ratio are scaled, the others are filled
+// with the fill mode of the profile. Rotation is applied by ffmpeg before the filters run
+func (vp *VideoProcessor) buildFillFilter(inputFile string, window videos.TrimWindow) string {
+	mode := vp.config.FillMode
+	geometry, err := vp.probeGeometry(inputFile)
+	if err != nil {
+		log.Printf("Warning: could not read source geometry: %v - using %s fill", err, config.FillModePad)
+		return vp.fillFilter(config.FillModePad, centreFocus)
+	}
+
+	width, height := geometry.displaySize()
+	if vp.matchesOutputAspect(width, height) {
+		mode = config.FillModePad // Nothing to fill, scaling is enough
+	}
+	log.Printf("Source %dx%d (rotation %d, displayed %dx%d), fill mode: %s",
+		geometry.Width, geometry.Height, geometry.Rotation, width, height, mode)
+
+	focus := centreFocus
+	if mode == config.FillModeSmartCrop {
+		if focus, err = vp.detectFocus(inputFile, window); err != nil {
+			log.Printf("Warning: motion analysis failed: %v - cropping around the centre", err)
+			focus = centreFocus
+		}
+	}
+	return vp.fillFilter(mode, focus)
+}
+
+// matchesOutputAspect reports whether a display size has the aspect ratio of the output
+func (vp *VideoProcessor) matchesOutputAspect(width int, height int) bool {
+	if width <= 0 || height <= 0 {
+		return true
+	}
+	source := float64(width) / float64(height)
+	target := float64(vp.config.TargetWidth) / float64(vp.config.TargetHeight)
+	return math.Abs(source-target)/target <= aspectTolerance
+}
+
+// fillFilter builds the filter chain of a fill mode, from [0:v] to [scaled]
+func (vp *VideoProcessor) fillFilter(mode string, focus focusPoint) string {
+	width, height := vp.config.TargetWidth, vp.config.TargetHeight
+
+	switch mode {
+	case config.FillModeBlur:
+		// The video scaled to fit, centred over a blurred copy scaled to cover the whole frame
+		return fmt.Sprintf(
+			"[0:v]split=2[background][foreground];"+
+				"[background]scale=%d:%d:force_original_aspect_ratio=increase,crop=%d:%d,boxblur=20:2[blurred];"+
+				"[foreground]scale=%d:%d:force_original_aspect_ratio=decrease[front];"+
+				"[blurred][front]overlay=(W-w)/2:(H-h)/2,setsar=1[scaled]",
+			width, height, width, height, width, height)
+
+	case config.FillModeSmartCrop:
+		// Scaled to cover the frame and cropped around the focus point, kept inside the picture
+		return fmt.Sprintf(
+			"[0:v]scale=%d:%d:force_original_aspect_ratio=increase,"+
+				"crop=%d:%d:'min(max(iw*%.3f-ow/2,0),iw-ow)':'min(max(ih*%.3f-oh/2,0),ih-oh)',setsar=1[scaled]",
+			width, height, width, height, focus.X, focus.Y)
+
+	default:
+		// NO cropping - keeps all content with black bars if necessary
+		return fmt.Sprintf(
+			"[0:v]scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2:black[scaled]",
+			width, height, width, height)
+	}
+}
+
+// detectFocus samples the part of the source that is kept and returns the centre of its motion
+func (vp *VideoProcessor) detectFocus(inputFile string, window videos.TrimWindow) (focusPoint, error) {
+	args := []string{
+		"-v", "error",
+		"-ss", fmt.Sprintf("%.3f", window.Start),
+		"-t", fmt.Sprintf("%.3f", window.Duration()),
+		"-i", inputFile,
+		"-vf", fmt.Sprintf("fps=%d,scale=%d:%d,format=gray", focusSampleFPS, focusGridSize, focusGridSize),
+		"-f", "rawvideo",
+		"-",
+	}
+	log.Printf("Executing FFmpeg motion analysis: %s", strings.Join(args, " "))
+
+	var frames bytes.Buffer
+	cmd := exec.Command(vp.config.FFmpegPath, args...)
+	cmd.Stdout = &frames
+	cmd.Stderr = os.Stderr
+
+	if err := vp.executeWithTimeout(cmd, 2*time.Minute); err != nil {
+		return focusPoint{}, err
+	}
+	return motionFocus(frames.Bytes(), focusGridSize), nil
+}
+
+// motionFocus returns the centre of the luma changes between consecutive size x size grayscale
+// frames, weighted by how much each pixel changed. Static videos get the centre of the frame
+func motionFocus(frames []byte, size int) focusPoint {
+	frameLength := size * size
+	columns := make([]float64, size)
+	rows := make([]float64, size)
+	var total float64
+
+	for start := frameLength; start+frameLength <= len(frames); start += frameLength {
+		previous, current := frames[start-frameLength:start], frames[start:start+frameLength]
+		for i := range current {
+			diff := math.Abs(float64(current[i]) - float64(previous[i]))
+			if diff < focusMinDiff {
+				continue
+			}
+			columns[i%size] += diff
+			rows[i/size] += diff
+			total += diff
+		}
+	}
+	if total == 0 {
+		return centreFocus
+	}
+
+	centre := func(weights []float64) float64 {
+		var sum float64
+		for i, weight := range weights {
+			sum += weight * (float64(i) + 0.5)
+		}
+		return sum / total / float64(size)
+	}
+	return focusPoint{X: centre(columns), Y: centre(rows)}
+}
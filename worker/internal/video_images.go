@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"worker/internal/videos"
+)
+
+// Layout of the generated images inside the output directory (and under processed/{id}/ in storage)
+const (
+	posterFile        = "poster.jpg"
+	thumbnailsDir     = "thumbnails"
+	previewDir        = "preview"
+	previewSpriteFile = "sprite.jpg"
+	previewVTTFile    = "thumbnails.vtt"
+)
+
+// minPosterLuma is the average luma (0-255) a frame needs to be considered for the poster,
+// black and fade frames stay below it
+const minPosterLuma = 32
+
+// GenerateImages writes the poster frame, the thumbnails and the scrubbing preview (sprite sheet
+// and WebVTT) of a video into outputDir. The poster and thumbnails are taken from the window of
+// the source that was kept, so bumpers never show up in them; the preview follows the processed
+// video timeline the player scrubs
+func (vp *VideoProcessor) GenerateImages(sourceFile string, processedFile string, outputDir string, window videos.TrimWindow) error {
+	for _, dir := range []string{outputDir, filepath.Join(outputDir, thumbnailsDir), filepath.Join(outputDir, previewDir)} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create images directory: %w", err)
+		}
+	}
+
+	if err := vp.generatePoster(sourceFile, filepath.Join(outputDir, posterFile), window); err != nil {
+		return err
+	}
+
+	for i, offset := range vp.config.ThumbnailOffsets {
+		thumbnail := filepath.Join(outputDir, thumbnailsDir, thumbnailFileName(i))
+		if err := vp.generateThumbnail(sourceFile, thumbnail, window.Start+offset*window.Duration()); err != nil {
+			return err
+		}
+	}
+
+	return vp.generatePreview(processedFile, filepath.Join(outputDir, previewDir))
+}
+
+// thumbnailFileName returns the file name of the i-th thumbnail (0 based)
+func thumbnailFileName(i int) string {
+	return fmt.Sprintf("thumb_%d.jpg", i+1)
+}
+
+// generatePoster picks a representative frame of the content: frames darker than minPosterLuma
+// are dropped and the thumbnail filter chooses among the rest. Videos that are dark throughout
+// fall back to the representative frame without the luma filter
+func (vp *VideoProcessor) generatePoster(sourceFile string, posterPath string, window videos.TrimWindow) error {
+	scale := vp.letterbox(vp.config.TargetWidth, vp.config.TargetHeight)
+	brightFrames := fmt.Sprintf("signalstats,metadata=mode=select:key=lavfi.signalstats.YAVG:value=%d:function=greater,", minPosterLuma)
+
+	for _, filter := range []string{brightFrames + "thumbnail=60," + scale, "thumbnail=60," + scale} {
+		args := []string{
+			"-ss", fmt.Sprintf("%.3f", window.Start),
+			"-t", fmt.Sprintf("%.3f", window.Duration()),
+			"-i", sourceFile,
+			"-vf", filter,
+			"-frames:v", "1",
+			"-q:v", "3",
+			"-y", posterPath,
+		}
+		if err := vp.runImageCommand(args); err != nil {
+			return fmt.Errorf("poster generation failed: %w", err)
+		}
+
+		// ffmpeg succeeds without writing anything when the filter drops every frame
+		if info, err := os.Stat(posterPath); err == nil && info.Size() > 0 {
+			return nil
+		}
+		log.Printf("No frame above luma %d for poster, falling back to any representative frame", minPosterLuma)
+	}
+	return fmt.Errorf("poster generation produced no image")
+}
+
+// generateThumbnail extracts the frame at the given second
+func (vp *VideoProcessor) generateThumbnail(sourceFile string, thumbnailPath string, second float64) error {
+	args := []string{
+		"-ss", fmt.Sprintf("%.3f", second), // Seek before the input for a fast keyframe seek
+		"-i", sourceFile,
+		"-vf", vp.letterbox(vp.config.ThumbnailWidth, vp.config.ThumbnailHeight),
+		"-frames:v", "1",
+		"-q:v", "4",
+		"-y", thumbnailPath,
+	}
+	if err := vp.runImageCommand(args); err != nil {
+		return fmt.Errorf("thumbnail generation failed: %w", err)
+	}
+	return nil
+}
+
+// generatePreview writes a sprite sheet with one tile every SpriteInterval seconds and the WebVTT
+// file mapping each interval to its tile
+func (vp *VideoProcessor) generatePreview(processedFile string, previewPath string) error {
+	duration, err := vp.probeDuration(processedFile)
+	if err != nil {
+		return fmt.Errorf("failed to read processed duration: %w", err)
+	}
+
+	tiles := int(math.Ceil(duration / vp.config.SpriteInterval))
+	if tiles < 1 {
+		tiles = 1
+	}
+	rows := (tiles + vp.config.SpriteColumns - 1) / vp.config.SpriteColumns
+
+	args := []string{
+		"-i", processedFile,
+		"-vf", fmt.Sprintf("fps=1/%g,%s,tile=%dx%d",
+			vp.config.SpriteInterval,
+			vp.letterbox(vp.config.SpriteTileWidth, vp.config.SpriteTileHeight),
+			vp.config.SpriteColumns, rows),
+		"-frames:v", "1",
+		"-q:v", "5",
+		"-y", filepath.Join(previewPath, previewSpriteFile),
+	}
+	if err := vp.runImageCommand(args); err != nil {
+		return fmt.Errorf("preview sprite generation failed: %w", err)
+	}
+
+	vtt := buildPreviewVTT(duration, vp.config.SpriteInterval, vp.config.SpriteColumns,
+		vp.config.SpriteTileWidth, vp.config.SpriteTileHeight)
+	if err := os.WriteFile(filepath.Join(previewPath, previewVTTFile), []byte(vtt), 0644); err != nil {
+		return fmt.Errorf("failed to write preview vtt: %w", err)
+	}
+	return nil
+}
+
+// buildPreviewVTT maps each interval of the video to its sprite tile with a media fragment
+// (sprite.jpg#xywh=x,y,w,h). The sprite is referenced relative to the VTT file
+func buildPreviewVTT(duration float64, interval float64, columns int, tileWidth int, tileHeight int) string {
+	var vtt strings.Builder
+	vtt.WriteString("WEBVTT\n")
+
+	for i := 0; float64(i)*interval < duration; i++ {
+		start := float64(i) * interval
+		end := math.Min(start+interval, duration)
+		x := (i % columns) * tileWidth
+		y := (i / columns) * tileHeight
+
+		fmt.Fprintf(&vtt, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			vttTimestamp(start), vttTimestamp(end), previewSpriteFile, x, y, tileWidth, tileHeight)
+	}
+	return vtt.String()
+}
+
+// vttTimestamp formats seconds as a WebVTT timestamp (HH:MM:SS.mmm)
+func vttTimestamp(seconds float64) string {
+	d := time.Duration(math.Round(seconds*1000)) * time.Millisecond
+	return fmt.Sprintf("%02d:%02d:%02d.%03d",
+		int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, d.Milliseconds()%1000)
+}
+
+// letterbox scales into width x height without cropping, padding with black bars
+func (vp *VideoProcessor) letterbox(width int, height int) string {
+	return fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2:black",
+		width, height, width, height)
+}
+
+// runImageCommand runs an ffmpeg image extraction command
+func (vp *VideoProcessor) runImageCommand(args []string) error {
+	log.Printf("Executing FFmpeg image extraction: %s", strings.Join(args, " "))
+
+	cmd := exec.Command(vp.config.FFmpegPath, args...)
+	cmd.Stderr = os.Stderr
+
+	return vp.executeWithTimeout(cmd, 2*time.Minute)
+}
@@ -6,106 +6,325 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+
+	"worker/internal/config"
+	"worker/internal/videos"
 )
 
 // VideoProcessor handles all video processing operations
 type VideoProcessor struct {
-	config VideoProcessingConfig
+	config   VideoProcessingConfig
+	profile  string                // Name of the profile applied to config
+	profiles config.ProfilesConfig // Profiles selectable with WithProfile
+	bumpers  map[string]bumperPair // Bumpers normalised by PrepareBumpers, by profile name
 }
 
-// VideoProcessingConfig defines configuration for video processing
+// VideoProcessingConfig defines configuration for video processing. The output settings come
+// from the processing profile of the video
 type VideoProcessingConfig struct {
-	MaxDuration   int    // 30 seconds maximum
-	TargetWidth   int    // 1280 (for 720p 16:9)
-	TargetHeight  int    // 720
-	WatermarkPath string // "/app/assets/watermark.png"
-	IntroPath     string // "/app/assets/intro.mp4"
-	OutroPath     string // "/app/assets/outro.mp4"
+	MaxDuration   int    // 30 seconds maximum (profile)
+	TargetWidth   int    // 1280 for 720p 16:9 (profile)
+	TargetHeight  int    // 720 (profile)
+	WatermarkPath string // "/app/assets/watermark.png" (profile)
+	IntroPath     string // "/app/assets/intro.mp4" (profile)
+	OutroPath     string // "/app/assets/outro.mp4" (profile)
 	TempDir       string // "/tmp"
-	VideoCodec    string // "libx264"
-	VideoQuality  string // "23" (CRF value for optimal quality)
+	VideoCodec    string // "libx264" (profile)
+	VideoQuality  string // "23", CRF value for optimal quality (profile)
+	FillMode      string // "pad", "blur" or "smart_crop" for portrait and non-16:9 sources (profile)
+	FrameRate     int    // 30 frames per second (profile)
 	FFmpegPath    string // "ffmpeg"
+	FFprobePath   string // "ffprobe"
+
+	BumperCacheDir  string  // Directory of the normalised bumpers, kept across restarts
+	BumperCrossfade float64 // Seconds of crossfade into and out of the video, 0 for hard cuts (profile)
+
+	HLSSegmentSeconds int            // 6 seconds per HLS segment
+	HLSLadder         []HLSRendition // Adaptive streaming renditions, lowest first
+
+	ThumbnailOffsets []float64 // Thumbnail positions as fractions of the content duration
+	ThumbnailWidth   int       // 320 (16:9 thumbnails)
+	ThumbnailHeight  int       // 180
+	SpriteInterval   float64   // Seconds between scrubbing preview tiles
+	SpriteColumns    int       // Tiles per sprite sheet row
+	SpriteTileWidth  int       // 160
+	SpriteTileHeight int       // 90
+}
+
+// HLSRendition is one quality level of the adaptive streaming ladder
+type HLSRendition struct {
+	Name    string // Directory and variant name, e.g. "720p"
+	Height  int    // Output height, width keeps the 16:9 aspect ratio
+	Bitrate string // Target video bitrate, e.g. "2800k"
+	MaxRate string // Peak bitrate allowed by the encoder
+	BufSize string // Rate control buffer size
 }
 
-// NewVideoProcessor creates a new video processor with default configuration
-func NewVideoProcessor() *VideoProcessor {
-	return &VideoProcessor{
+// NewVideoProcessor creates a new video processor with the default profile applied
+func NewVideoProcessor(profiles config.ProfilesConfig) *VideoProcessor {
+	vp := &VideoProcessor{
+		profiles: profiles,
 		config: VideoProcessingConfig{
-			MaxDuration:   30,   // Trim to 30 seconds
-			TargetWidth:   1280, // 720p 16:9 resolution
-			TargetHeight:  720,
-			WatermarkPath: "/app/assets/watermark.png", // ANB watermark
-			IntroPath:     "/app/assets/intro.mp4",     // Opening bumper
-			OutroPath:     "/app/assets/outro.mp4",     // Closing bumper
-			TempDir:       "/tmp",
-			VideoCodec:    "libx264", // H.264 for compatibility
-			VideoQuality:  "23",      // CRF 23 = high quality
-			FFmpegPath:    "ffmpeg",  // Assumes ffmpeg is in PATH
+			TempDir:        "/tmp",
+			FFmpegPath:     "ffmpeg",  // Assumes ffmpeg is in PATH
+			FFprobePath:    "ffprobe", // Assumes ffprobe is in PATH
+			BumperCacheDir: profiles.BumperCacheDir,
+
+			HLSSegmentSeconds: 6,
+			// The ladder is cut from the processed video (watermark and bumpers included), so it
+			// stops at the 720p target height of the standard profile
+			HLSLadder: []HLSRendition{
+				{Name: "360p", Height: 360, Bitrate: "800k", MaxRate: "856k", BufSize: "1200k"},
+				{Name: "480p", Height: 480, Bitrate: "1400k", MaxRate: "1498k", BufSize: "2100k"},
+				{Name: "720p", Height: 720, Bitrate: "2800k", MaxRate: "2996k", BufSize: "4200k"},
+			},
+
+			ThumbnailOffsets: []float64{0.1, 0.3, 0.5, 0.7, 0.9},
+			ThumbnailWidth:   320,
+			ThumbnailHeight:  180,
+			SpriteInterval:   1,
+			SpriteColumns:    10,
+			SpriteTileWidth:  160,
+			SpriteTileHeight: 90,
 		},
 	}
+	if vp.config.BumperCacheDir == "" {
+		vp.config.BumperCacheDir = filepath.Join(vp.config.TempDir, "bumpers")
+	}
+	vp.applyProfile(profiles.Default, profiles.Profiles[profiles.Default])
+	return vp
 }
 
-// ProcessVideoByS3Key applies all required transformations using no cropping
-func (vp *VideoProcessor) ProcessVideoByS3Key(inputData []byte, s3Key string) ([]byte, error) {
-	log.Printf("Starting video processing for S3 key: %s with no cropping", s3Key)
-	log.Printf("Requirements: ≤30s, 1280x720, 16:9, no audio, ANB watermark, ANB bumpers, no content cropping")
+// WithProfile returns a processor applying the named profile, or the default profile when the
+// name is empty. Unknown names are an error: the API and the worker disagree on the profiles file
+func (vp *VideoProcessor) WithProfile(name string) (Processor, error) {
+	if name == "" {
+		name = vp.profiles.Default
+	}
+
+	profile, ok := vp.profiles.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown processing profile %q", name)
+	}
 
-	// Generate unique filename from S3 key for temp files
-	safeFilename := strings.ReplaceAll(strings.ReplaceAll(s3Key, "/", "_"), ".", "_")
+	processor := *vp
+	processor.applyProfile(name, profile)
+	return &processor, nil
+}
 
-	// 1. Create temporary input file
-	inputFile, err := vp.createTempFile(inputData, fmt.Sprintf("input_%s.mp4", safeFilename))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create input temp file: %w", err)
+// applyProfile copies the output settings of a profile into the processing configuration. The
+// bumpers normalised for the profile by PrepareBumpers replace the original assets
+func (vp *VideoProcessor) applyProfile(name string, profile config.ProcessingProfile) {
+	vp.profile = name
+	vp.config.MaxDuration = profile.MaxOutputSeconds
+	vp.config.TargetWidth = profile.TargetWidth
+	vp.config.TargetHeight = profile.TargetHeight
+	vp.config.VideoCodec = profile.VideoCodec
+	vp.config.VideoQuality = strconv.Itoa(profile.CRF)
+	vp.config.FillMode = profile.FillMode
+	vp.config.FrameRate = profile.FrameRate
+	vp.config.WatermarkPath = profile.WatermarkPath
+	vp.config.IntroPath = profile.IntroPath
+	vp.config.OutroPath = profile.OutroPath
+	vp.config.BumperCrossfade = profile.BumperCrossfadeSeconds
+
+	if bumpers, ok := vp.bumpers[name]; ok {
+		vp.config.IntroPath = bumpers.Intro
+		vp.config.OutroPath = bumpers.Outro
 	}
-	defer vp.cleanupFile(inputFile)
+}
+
+// TempDir returns the directory used for temporary files
+func (vp *VideoProcessor) TempDir() string {
+	return vp.config.TempDir
+}
 
-	// 2. Create temporary output file
-	outputFile := filepath.Join(vp.config.TempDir, fmt.Sprintf("processed_%s.mp4", safeFilename))
-	defer vp.cleanupFile(outputFile)
+// ProcessVideoFile applies all required transformations to the window of the source chosen by
+// SelectWindow. It reads the input file from disk and writes the final video to outputFile, so
+// the video is never held in memory
+func (vp *VideoProcessor) ProcessVideoFile(inputFile string, outputFile string, s3Key string, window videos.TrimWindow) error {
+	log.Printf("Starting video processing for S3 key: %s with %s fill (profile: %s)", s3Key, vp.config.FillMode, vp.profile)
+	log.Printf("Requirements: ≤%ds, %dx%d, no audio, ANB watermark, ANB bumpers",
+		vp.config.MaxDuration, vp.config.TargetWidth, vp.config.TargetHeight)
 
-	// 3. Validate required assets before processing
+	// 1. Create temporary file for the transformed video (before bumpers), next to the output so
+	// it belongs to the directory of this attempt
+	transformedFile := filepath.Join(filepath.Dir(outputFile), "processed_"+filepath.Base(outputFile))
+	defer vp.cleanupFile(transformedFile)
+
+	// 2. Validate required assets before processing
 	if err := vp.validateRequiredAssets(); err != nil {
 		log.Printf("Warning: %v - continuing without watermark", err)
 		// Continue without watermark rather than failing completely
 	}
 
+	// 3. Place the (rotated) source into the output frame with the fill mode of the profile
+	fill := vp.buildFillFilter(inputFile, window)
+
 	// 4. Execute FFmpeg processing
-	if err := vp.executeFFmpegCommand(inputFile, outputFile); err != nil {
-		return nil, fmt.Errorf("ffmpeg processing failed: %w", err)
+	if err := vp.executeFFmpegCommand(inputFile, transformedFile, window, fill); err != nil {
+		return fmt.Errorf("ffmpeg processing failed: %w", err)
 	}
 
-	// 5. Add bumpers (intro/outro) if available
-	finalOutputFile := outputFile
+	// 5. Add bumpers (intro/outro) if available, writing straight to the output file
 	if vp.bumpersExist() {
 		log.Printf("Bumpers found, adding intro and outro to video")
-		finalOutputFile = filepath.Join(vp.config.TempDir, fmt.Sprintf("final_%s.mp4", safeFilename))
-		defer vp.cleanupFile(finalOutputFile)
-
-		if err := vp.addBumpers(outputFile, finalOutputFile); err != nil {
-			log.Printf("Warning: Failed to add bumpers: %v - using video without bumpers", err)
-			finalOutputFile = outputFile
-		} else {
-			log.Printf("Successfully added ANB bumpers (intro + video + outro)")
+
+		// Both the stream copy and the re-encode failed: retried rather than published without bumpers
+		if err := vp.addBumpers(transformedFile, outputFile); err != nil {
+			return fmt.Errorf("failed to add bumpers: %w", err)
 		}
+		log.Printf("Successfully added ANB bumpers (intro + video + outro)")
+		return vp.logProcessedSize(inputFile, outputFile, s3Key)
+	}
+
+	// 6. No bumpers: the transformed video is the final output
+	if err := os.Rename(transformedFile, outputFile); err != nil {
+		return fmt.Errorf("failed to move processed file: %w", err)
+	}
+
+	return vp.logProcessedSize(inputFile, outputFile, s3Key)
+}
+
+// GenerateHLS segments the processed video into the HLS ladder inside outputDir: one directory
+// per rendition with its index.m3u8 and segments, and master.m3u8 referencing them. Renditions
+// taller than the processed video (the target height) or the original source are skipped, as
+// they would only be upscaled
+func (vp *VideoProcessor) GenerateHLS(sourceFile string, processedFile string, outputDir string) error {
+	maxHeight := vp.config.TargetHeight
+	if geometry, err := vp.probeGeometry(sourceFile); err != nil {
+		log.Printf("Warning: could not read source height: %v - using target height %d", err, maxHeight)
+	} else if _, sourceHeight := geometry.displaySize(); sourceHeight < maxHeight {
+		maxHeight = sourceHeight
+	}
+
+	renditions := vp.hlsRenditions(maxHeight)
+	for _, rendition := range renditions {
+		if err := os.MkdirAll(filepath.Join(outputDir, rendition.Name), 0755); err != nil {
+			return fmt.Errorf("failed to create HLS directory: %w", err)
+		}
+	}
+
+	args := vp.buildHLSArgs(processedFile, outputDir, renditions)
+	log.Printf("Executing FFmpeg HLS segmentation: %s", strings.Join(args, " "))
+
+	cmd := exec.Command(vp.config.FFmpegPath, args...)
+	cmd.Stderr = os.Stderr
+
+	if err := vp.executeWithTimeout(cmd, 5*time.Minute); err != nil {
+		return fmt.Errorf("ffmpeg hls segmentation failed: %w", err)
+	}
+	return nil
+}
+
+// hlsRenditions returns the ladder entries up to maxHeight, always keeping the lowest one
+func (vp *VideoProcessor) hlsRenditions(maxHeight int) []HLSRendition {
+	var renditions []HLSRendition
+	for _, rendition := range vp.config.HLSLadder {
+		if rendition.Height <= maxHeight || len(renditions) == 0 {
+			renditions = append(renditions, rendition)
+		}
+	}
+	return renditions
+}
+
+// buildHLSArgs builds a single FFmpeg command that scales the input to every rendition and
+// writes the segments, the media playlists and the master playlist
+func (vp *VideoProcessor) buildHLSArgs(inputFile string, outputDir string, renditions []HLSRendition) []string {
+	// split the decoded video once and scale each copy to its rendition height
+	filter := fmt.Sprintf("[0:v]split=%d", len(renditions))
+	for i := range renditions {
+		filter += fmt.Sprintf("[v%d]", i)
+	}
+	for i, rendition := range renditions {
+		filter += fmt.Sprintf(";[v%d]scale=-2:%d[v%dout]", i, rendition.Height, i)
+	}
+
+	args := []string{
+		"-i", inputFile,
+		"-filter_complex", filter,
 	}
 
-	// 6. Read final processed video data
-	processedData, err := os.ReadFile(finalOutputFile)
+	streamMap := make([]string, 0, len(renditions))
+	for i, rendition := range renditions {
+		args = append(args,
+			"-map", fmt.Sprintf("[v%dout]", i),
+			fmt.Sprintf("-b:v:%d", i), rendition.Bitrate,
+			fmt.Sprintf("-maxrate:v:%d", i), rendition.MaxRate,
+			fmt.Sprintf("-bufsize:v:%d", i), rendition.BufSize,
+		)
+		streamMap = append(streamMap, fmt.Sprintf("v:%d,name:%s", i, rendition.Name))
+	}
+
+	segment := vp.config.HLSSegmentSeconds
+	args = append(args,
+		"-an",                        // Processed videos have no audio
+		"-c:v", vp.config.VideoCodec, // Codec H.264
+		"-preset", "medium",
+		"-pix_fmt", "yuv420p",
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", segment), // Aligned segment boundaries across renditions
+		"-f", "hls",
+		"-hls_time", fmt.Sprintf("%d", segment),
+		"-hls_playlist_type", "vod",
+		"-hls_segment_filename", filepath.Join(outputDir, "%v", "segment_%03d.ts"),
+		"-master_pl_name", "master.m3u8",
+		"-var_stream_map", strings.Join(streamMap, " "),
+		"-y",
+		filepath.Join(outputDir, "%v", "index.m3u8"),
+	)
+	return args
+}
+
+// probeDuration reads the container duration in seconds with ffprobe
+func (vp *VideoProcessor) probeDuration(file string) (float64, error) {
+	value, err := vp.probe(file, "-show_entries", "format=duration")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read processed file: %w", err)
+		return 0, err
+	}
+
+	duration, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ffprobe duration %q: %w", value, err)
+	}
+	return duration, nil
+}
+
+// probe runs ffprobe with the given entry selection and returns the single value printed
+func (vp *VideoProcessor) probe(file string, selection ...string) (string, error) {
+	args := append([]string{"-v", "error"}, selection...)
+	args = append(args, "-of", "csv=p=0", file)
+
+	output, err := exec.Command(vp.config.FFprobePath, args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("ffprobe failed: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// logProcessedSize logs the original and processed file sizes, failing if the output is missing
+func (vp *VideoProcessor) logProcessedSize(inputFile string, outputFile string, s3Key string) error {
+	outputInfo, err := os.Stat(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to read processed file: %w", err)
+	}
+
+	var inputSize int64
+	if inputInfo, err := os.Stat(inputFile); err == nil {
+		inputSize = inputInfo.Size()
 	}
 
 	log.Printf("Video processing completed for S3 key: %s. Original: %d bytes, Processed: %d bytes",
-		s3Key, len(inputData), len(processedData))
+		s3Key, inputSize, outputInfo.Size())
 
-	return processedData, nil
+	return nil
 }
 
-// executeFFmpegCommand constructs and executes the optimized FFmpeg command
-func (vp *VideoProcessor) executeFFmpegCommand(inputFile, outputFile string) error {
+// executeFFmpegCommand constructs and executes the optimized FFmpeg command on the window of the
+// source. fill is the filter chain placing the source into the output frame (see buildFillFilter)
+func (vp *VideoProcessor) executeFFmpegCommand(inputFile, outputFile string, window videos.TrimWindow, fill string) error {
 	// Check if watermark exists to decide on filter complexity
 	hasWatermark := vp.watermarkExists()
 
@@ -114,15 +333,18 @@ func (vp *VideoProcessor) executeFFmpegCommand(inputFile, outputFile string) err
 	if hasWatermark {
 		// Full command with watermark
 		args = []string{
+			"-ss", fmt.Sprintf("%.3f", window.Start), // Start of the selected window
 			"-i", inputFile, // Input video
 			"-i", vp.config.WatermarkPath, // ANB watermark
-			"-t", fmt.Sprintf("%d", vp.config.MaxDuration), // Maximum 30 seconds
-			"-filter_complex", vp.buildVideoFilterWithWatermark(), // Video filter with watermark
+			"-t", fmt.Sprintf("%.3f", window.Duration()), // Maximum 30 seconds
+			"-filter_complex", vp.buildVideoFilterWithWatermark(fill), // Video filter with watermark
 			"-an",                        // Remove audio completely
 			"-c:v", vp.config.VideoCodec, // Codec H.264
 			"-crf", vp.config.VideoQuality, // Quality CRF 23
 			"-preset", "medium", // Balance speed/quality
 			"-pix_fmt", "yuv420p", // Compatible pixel format
+			"-r", strconv.Itoa(vp.config.FrameRate), // Same frame rate as the normalised bumpers
+			"-video_track_timescale", outputTimescale, // Same timebase as the normalised bumpers
 			"-movflags", "+faststart", // Optimization for streaming
 			"-y", // Overwrite output if exists
 			outputFile,
@@ -130,21 +352,25 @@ func (vp *VideoProcessor) executeFFmpegCommand(inputFile, outputFile string) err
 	} else {
 		// Command without watermark
 		args = []string{
+			"-ss", fmt.Sprintf("%.3f", window.Start), // Start of the selected window
 			"-i", inputFile, // Input video
-			"-t", fmt.Sprintf("%d", vp.config.MaxDuration), // Maximum 30 seconds
-			"-vf", vp.buildVideoFilterWithoutWatermark(), // Video filter without watermark
+			"-t", fmt.Sprintf("%.3f", window.Duration()), // Maximum 30 seconds
+			"-filter_complex", fill, // Video filter without watermark
+			"-map", "[scaled]",
 			"-an",                        // Remove audio completely
 			"-c:v", vp.config.VideoCodec, // Codec H.264
 			"-crf", vp.config.VideoQuality, // Quality CRF 23
 			"-preset", "medium", // Balance speed/quality
 			"-pix_fmt", "yuv420p", // Compatible pixel format
+			"-r", strconv.Itoa(vp.config.FrameRate), // Same frame rate as the normalised bumpers
+			"-video_track_timescale", outputTimescale, // Same timebase as the normalised bumpers
 			"-movflags", "+faststart", // Optimization for streaming
 			"-y", // Overwrite output if exists
 			outputFile,
 		}
 	}
 
-	log.Printf("Executing FFmpeg with no cropping: %s", strings.Join(args, " "))
+	log.Printf("Executing FFmpeg with %s fill: %s", vp.config.FillMode, strings.Join(args, " "))
 
 	cmd := exec.Command(vp.config.FFmpegPath, args...)
 	cmd.Stderr = os.Stderr // Show FFmpeg errors in logs
@@ -153,43 +379,11 @@ func (vp *VideoProcessor) executeFFmpegCommand(inputFile, outputFile string) err
 }
 
 // buildVideoFilterWithWatermark builds the complex video filter with watermark
-func (vp *VideoProcessor) buildVideoFilterWithWatermark() string {
-	// NO cropping - keeps all content with black bars if necessary
-	// 1. scale with force_original_aspect_ratio=decrease: Video fits INSIDE 1280x720
-	// 2. pad=1280:720: Adds centered black bars to complete 1280x720
-	// 3. scale watermark: Scales watermark to maximum 150x60 pixels
-	// 4. overlay: Places scaled ANB watermark in top right corner
-
-	return fmt.Sprintf(
-		"[0:v]scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2:black[scaled];[1:v]scale=150:60:force_original_aspect_ratio=decrease[watermark];[scaled][watermark]overlay=main_w-overlay_w-10:10",
-		vp.config.TargetWidth,  // 1280
-		vp.config.TargetHeight, // 720
-		vp.config.TargetWidth,  // 1280
-		vp.config.TargetHeight, // 720
-	)
-}
-
-// buildVideoFilterWithoutWatermark builds video filter without watermark
-func (vp *VideoProcessor) buildVideoFilterWithoutWatermark() string {
-	// Same scaling and padding but without watermark overlay
-	return fmt.Sprintf(
-		"scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2:black",
-		vp.config.TargetWidth,  // 1280
-		vp.config.TargetHeight, // 720
-		vp.config.TargetWidth,  // 1280
-		vp.config.TargetHeight, // 720
-	)
-}
-
-// createTempFile writes data to a temporary file
-func (vp *VideoProcessor) createTempFile(data []byte, filename string) (string, error) {
-	tempFile := filepath.Join(vp.config.TempDir, filename)
-
-	if err := os.WriteFile(tempFile, data, 0644); err != nil {
-		return "", fmt.Errorf("failed to write temp file %s: %w", tempFile, err)
-	}
-
-	return tempFile, nil
+func (vp *VideoProcessor) buildVideoFilterWithWatermark(fill string) string {
+	// 1. fill: Source placed into 1280x720 as [scaled] (black bars, blurred background or crop)
+	// 2. scale watermark: Scales watermark to maximum 150x60 pixels
+	// 3. overlay: Places scaled ANB watermark in top right corner
+	return fill + ";[1:v]scale=150:60:force_original_aspect_ratio=decrease[watermark];[scaled][watermark]overlay=main_w-overlay_w-10:10"
 }
 
 // executeWithTimeout executes a command with a safety timeout
@@ -252,36 +446,3 @@ func (vp *VideoProcessor) fileExists(filepath string) bool {
 	_, err := os.Stat(filepath)
 	return err == nil
 }
-
-// addBumpers concatenates intro + processed video + outro using FFmpeg
-func (vp *VideoProcessor) addBumpers(processedVideoFile, outputFile string) error {
-	log.Printf("Concatenating: intro + video + outro")
-
-	// Create concat list file for FFmpeg
-	concatFile := filepath.Join(vp.config.TempDir, fmt.Sprintf("concat_%d.txt", time.Now().Unix()))
-	defer vp.cleanupFile(concatFile)
-
-	concatContent := fmt.Sprintf("file '%s'\nfile '%s'\nfile '%s'\n",
-		vp.config.IntroPath, processedVideoFile, vp.config.OutroPath)
-
-	if err := os.WriteFile(concatFile, []byte(concatContent), 0644); err != nil {
-		return fmt.Errorf("failed to create concat file: %w", err)
-	}
-
-	// Execute FFmpeg concat command
-	args := []string{
-		"-f", "concat",
-		"-safe", "0",
-		"-i", concatFile,
-		"-c", "copy", // Copy streams without re-encoding for speed
-		"-y",
-		outputFile,
-	}
-
-	log.Printf("Executing FFmpeg concatenation: %s %s", vp.config.FFmpegPath, strings.Join(args, " "))
-
-	cmd := exec.Command(vp.config.FFmpegPath, args...)
-	cmd.Stderr = os.Stderr
-
-	return vp.executeWithTimeout(cmd, 2*time.Minute)
-}
@@ -1,9 +1,19 @@
 package internal
 
 import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"worker/internal/config"
+	"worker/internal/videos"
+
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -14,12 +24,12 @@ type VideoProcessorTestSuite struct {
 }
 
 func (suite *VideoProcessorTestSuite) SetupTest() {
-	suite.processor = NewVideoProcessor()
+	suite.processor = NewVideoProcessor(config.BuiltinProfiles())
 }
 
 func (suite *VideoProcessorTestSuite) TestNewVideoProcessor() {
 	// Test that NewVideoProcessor creates a valid instance
-	processor := NewVideoProcessor()
+	processor := NewVideoProcessor(config.BuiltinProfiles())
 
 	assert.NotNil(suite.T(), processor)
 	assert.Equal(suite.T(), "ffmpeg", processor.config.FFmpegPath)
@@ -27,8 +37,210 @@ func (suite *VideoProcessorTestSuite) TestNewVideoProcessor() {
 	assert.Equal(suite.T(), "/app/assets/watermark.png", processor.config.WatermarkPath)
 }
 
-// TestExtractVideoIDFromS3Key is skipped because this method is on WorkerService, not VideoProcessor
-// This test is covered in service_test.go
+func (suite *VideoProcessorTestSuite) TestHLSRenditionsSkipUpscaling() {
+	names := func(renditions []HLSRendition) []string {
+		var result []string
+		for _, rendition := range renditions {
+			result = append(result, rendition.Name)
+		}
+		return result
+	}
+
+	assert.Equal(suite.T(), []string{"360p", "480p", "720p"}, names(suite.processor.hlsRenditions(720)))
+	assert.Equal(suite.T(), []string{"360p", "480p", "720p"}, names(suite.processor.hlsRenditions(2160)))
+	assert.Equal(suite.T(), []string{"360p", "480p"}, names(suite.processor.hlsRenditions(600)))
+	// Sources below the lowest rendition still get one
+	assert.Equal(suite.T(), []string{"360p"}, names(suite.processor.hlsRenditions(240)))
+}
+
+func (suite *VideoProcessorTestSuite) TestBuildHLSArgs() {
+	renditions := suite.processor.hlsRenditions(480)
+	args := strings.Join(suite.processor.buildHLSArgs("in.mp4", "/tmp/hls", renditions), " ")
+
+	assert.Contains(suite.T(), args, "[0:v]split=2[v0][v1];[v0]scale=-2:360[v0out];[v1]scale=-2:480[v1out]")
+	assert.Contains(suite.T(), args, "-b:v:1 1400k")
+	assert.Contains(suite.T(), args, "-var_stream_map v:0,name:360p v:1,name:480p")
+	assert.Contains(suite.T(), args, "-hls_segment_filename /tmp/hls/%v/segment_%03d.ts")
+	assert.True(suite.T(), strings.HasSuffix(args, "/tmp/hls/%v/index.m3u8"))
+}
+
+func (suite *VideoProcessorTestSuite) TestWithProfile() {
+	profiles := config.BuiltinProfiles()
+	short := config.BuiltinProcessingProfile()
+	short.MaxOutputSeconds, short.TargetWidth, short.TargetHeight, short.CRF = 15, 720, 1280, 28
+	profiles.Profiles["short-form"] = short
+	processor := NewVideoProcessor(profiles)
+
+	selected, err := processor.WithProfile("short-form")
+	suite.Require().NoError(err)
+	vp := selected.(*VideoProcessor)
+	suite.Equal(15, vp.config.MaxDuration)
+	suite.Equal(720, vp.config.TargetWidth)
+	suite.Equal("28", vp.config.VideoQuality)
+	suite.Equal(processor.config.HLSLadder, vp.config.HLSLadder)
+
+	// The base processor keeps the default profile
+	suite.Equal(1280, processor.config.TargetWidth)
+	defaulted, err := processor.WithProfile("")
+	suite.Require().NoError(err)
+	suite.Equal("23", defaulted.(*VideoProcessor).config.VideoQuality)
+
+	_, err = processor.WithProfile("archive")
+	suite.ErrorContains(err, `unknown processing profile "archive"`)
+}
+
+func (suite *VideoProcessorTestSuite) TestFillFilter() {
+	pad := suite.processor.fillFilter(config.FillModePad, centreFocus)
+	suite.Equal("[0:v]scale=1280:720:force_original_aspect_ratio=decrease,pad=1280:720:(ow-iw)/2:(oh-ih)/2:black[scaled]", pad)
+
+	blur := suite.processor.fillFilter(config.FillModeBlur, centreFocus)
+	suite.Contains(blur, "[background]scale=1280:720:force_original_aspect_ratio=increase,crop=1280:720,boxblur=20:2[blurred]")
+	suite.Contains(blur, "[blurred][front]overlay=(W-w)/2:(H-h)/2,setsar=1[scaled]")
+
+	crop := suite.processor.fillFilter(config.FillModeSmartCrop, focusPoint{X: 0.25, Y: 0.5})
+	suite.Contains(crop, "crop=1280:720:'min(max(iw*0.250-ow/2,0),iw-ow)':'min(max(ih*0.500-oh/2,0),ih-oh)'")
+
+	// Portrait phone clips are stored landscape with a rotation
+	width, height := sourceGeometry{Width: 1920, Height: 1080, Rotation: 90}.displaySize()
+	suite.Equal([]int{1080, 1920}, []int{width, height})
+	suite.False(suite.processor.matchesOutputAspect(width, height))
+	suite.True(suite.processor.matchesOutputAspect(1920, 1088))
+}
+
+func (suite *VideoProcessorTestSuite) TestBuildBumperFilter() {
+	concat := suite.processor.buildBumperFilter(nil, 0)
+	suite.Contains(concat, "[1:v]scale=1280:720:force_original_aspect_ratio=decrease,pad=1280:720:(ow-iw)/2:(oh-ih)/2:black,setsar=1,fps=30,format=yuv420p,settb=AVTB[v1]")
+	suite.True(strings.HasSuffix(concat, ";[v0][v1][v2]concat=n=3:v=1:a=0[joined]"))
+
+	// 3s intro, 30s video and 2s outro with 0.5s crossfades
+	crossfade := suite.processor.buildBumperFilter([]float64{3, 30, 2}, 0.5)
+	suite.Contains(crossfade, ";[v0][v1]xfade=transition=fade:duration=0.500:offset=2.500[intro]")
+	suite.Contains(crossfade, ";[intro][v2]xfade=transition=fade:duration=0.500:offset=32.000[joined]")
+	suite.NotContains(crossfade, "concat")
+}
+
+func (suite *VideoProcessorTestSuite) TestBumperCacheName() {
+	name := suite.processor.bumperCacheName("/app/assets/intro.mp4", "abc")
+	suite.Regexp(`^intro_[0-9a-f]{16}\.mp4$`, name)
+	suite.Equal(name, suite.processor.bumperCacheName("/app/assets/intro.mp4", "abc"))
+
+	// A changed asset or profile gets a new cache entry
+	suite.NotEqual(name, suite.processor.bumperCacheName("/app/assets/intro.mp4", "abd"))
+	profiles := config.BuiltinProfiles()
+	profile := profiles.Profiles[config.DefaultProfileName]
+	profile.FrameRate = 25
+	profiles.Profiles[config.DefaultProfileName] = profile
+	suite.NotEqual(name, NewVideoProcessor(profiles).bumperCacheName("/app/assets/intro.mp4", "abc"))
+}
+
+func TestMotionFocus(t *testing.T) {
+	const size = 4
+	frame := func(changed ...int) []byte {
+		pixels := make([]byte, size*size)
+		for _, i := range changed {
+			pixels[i] = 200
+		}
+		return pixels
+	}
+
+	// Motion in the right column, top and bottom rows
+	frames := append(append(frame(), frame(3, 15)...), frame()...)
+	focus := motionFocus(frames, size)
+	assert.InDelta(t, 0.875, focus.X, 0.001)
+	assert.InDelta(t, 0.5, focus.Y, 0.001)
+
+	assert.Equal(t, centreFocus, motionFocus(append(frame(5), frame(5)...), size))
+}
+
+func TestReadMetadataSeries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenes.txt")
+	require.NoError(t, os.WriteFile(path, []byte(
+		"frame:0    pts:0       pts_time:0\n"+
+			"lavfi.scene_score=0.000000\n"+
+			"frame:1    pts:3072    pts_time:0.25\n"+
+			"lavfi.scene_score=0.412000\n"+
+			"frame:2    pts:6144    pts_time:0.5\n"+
+			"lavfi.astats.Overall.RMS_level=-inf\n"), 0644))
+
+	series, err := readMetadataSeries(path, sceneScoreKey)
+	require.NoError(t, err)
+	assert.Equal(t, []timedValue{{Time: 0, Value: 0}, {Time: 0.25, Value: 0.412}}, series)
+}
+
+func TestSecondScores(t *testing.T) {
+	scenes := []timedValue{{Time: 0.5, Value: 0.1}, {Time: 2.25, Value: 0.8}, {Time: 2.5, Value: 0.2}}
+	audio := []timedValue{{Time: 0, Value: math.Inf(-1)}, {Time: 1, Value: -6}, {Time: 2, Value: -20}}
+
+	scores := secondScores(scenes, audio, 3)
+	assert.InDelta(t, motionWeight*0.2, scores[0], 0.001) // Some motion, silence
+	assert.InDelta(t, audioWeight, scores[1], 0.001)      // Loudest second only
+	assert.Greater(t, scores[2], scores[1])               // Cut and most motion
+}
+
+func TestBestWindowStart(t *testing.T) {
+	// The highlight is at the end of the video
+	scores := []float64{0.1, 0.1, 0.1, 0.2, 0.9, 0.8}
+	assert.Equal(t, 3, bestWindowStart(scores, 3))
+
+	// Ties keep the earliest window, short sources start at 0
+	assert.Equal(t, 0, bestWindowStart([]float64{0, 0, 0, 0}, 2))
+	assert.Equal(t, 0, bestWindowStart([]float64{1, 1}, 3))
+}
+
+func TestCheckOutput(t *testing.T) {
+	expected := outputExpectation{Width: 1280, Height: 720, VideoCodec: "h264", MinDuration: 29, MaxDuration: 41}
+	output := func() *videos.Metadata {
+		return &videos.Metadata{Width: 1280, Height: 720, VideoCodec: "h264", Duration: 36}
+	}
+	assert.NoError(t, checkOutput(output(), expected))
+
+	broken := output()
+	broken.Height = 718
+	assert.ErrorContains(t, checkOutput(broken, expected), "output is 1280x718")
+
+	broken = output()
+	broken.VideoCodec = "mpeg4"
+	assert.ErrorContains(t, checkOutput(broken, expected), "output codec is mpeg4")
+
+	broken = output()
+	broken.Duration = 4
+	assert.ErrorContains(t, checkOutput(broken, expected), "output duration 4.0s is outside 29.0-41.0s")
+
+	broken = output()
+	broken.HasAudio, broken.AudioCodec = true, "aac"
+	assert.ErrorContains(t, checkOutput(broken, expected), "output has an audio stream")
+}
+
+func TestCheckMoovAtFront(t *testing.T) {
+	box := func(boxType string, payload int) []byte {
+		data := make([]byte, 8+payload)
+		binary.BigEndian.PutUint32(data, uint32(len(data)))
+		copy(data[4:], boxType)
+		return data
+	}
+	write := func(boxes ...[]byte) string {
+		path := filepath.Join(t.TempDir(), "output.mp4")
+		require.NoError(t, os.WriteFile(path, bytes.Join(boxes, nil), 0644))
+		return path
+	}
+
+	assert.NoError(t, checkMoovAtFront(write(box("ftyp", 16), box("moov", 32), box("mdat", 64))))
+	assert.ErrorContains(t, checkMoovAtFront(write(box("ftyp", 16), box("mdat", 64), box("moov", 32))), "not faststart")
+	assert.ErrorContains(t, checkMoovAtFront(write(box("ftyp", 16), box("free", 8))), "no moov atom")
+}
+
+func TestBuildPreviewVTT(t *testing.T) {
+	vtt := buildPreviewVTT(2.5, 1, 2, 160, 90)
+
+	assert.Equal(t, "WEBVTT\n"+
+		"\n00:00:00.000 --> 00:00:01.000\nsprite.jpg#xywh=0,0,160,90\n"+
+		"\n00:00:01.000 --> 00:00:02.000\nsprite.jpg#xywh=160,0,160,90\n"+
+		"\n00:00:02.000 --> 00:00:02.500\nsprite.jpg#xywh=0,90,160,90\n", vtt)
+	assert.Equal(t, "01:02:03.450", vttTimestamp(3723.45))
+}
+
+// TestExtractVideoIDFromS3Key is skipped because it is a pipeline helper, not a VideoProcessor method
+// This test is covered in pipeline_test.go
 
 // TestBuildFFmpegCommand is skipped because buildFFmpegCommand is not a public method
 // Video processing logic is tested through the public ProcessVideoByS3Key method
@@ -86,7 +298,7 @@ func TestVideoDataValidation(t *testing.T) {
 
 // Test processor configuration
 func TestProcessorConfiguration(t *testing.T) {
-	processor := NewVideoProcessor()
+	processor := NewVideoProcessor(config.BuiltinProfiles())
 
 	// Test default configuration
 	assert.Equal(t, "ffmpeg", processor.config.FFmpegPath)
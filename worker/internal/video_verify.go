@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"worker/internal/videos"
+)
+
+// outputDurationTolerance absorbs the frame and keyframe rounding of the trim and the concat
+const outputDurationTolerance = 1.0
+
+// encoderCodecs maps the ffmpeg encoders a profile can use to the codec ffprobe reports
+var encoderCodecs = map[string]string{
+	"libx264":    "h264",
+	"h264_nvenc": "h264",
+	"libx265":    "hevc",
+	"libvpx-vp9": "vp9",
+	"libaom-av1": "av1",
+	"libsvtav1":  "av1",
+}
+
+// ErrOutputRejected is returned by VerifyOutput when the processed video does not meet the
+// profile, which fails the same way on every attempt. Failing to probe or read the output is
+// not wrapped with it, so it is retried
+var ErrOutputRejected = errors.New("output verification failed")
+
+// rejectOutput returns an ErrOutputRejected with the reason the output does not meet the profile
+func rejectOutput(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrOutputRejected, fmt.Sprintf(format, args...))
+}
+
+// outputExpectation is what a processed video must look like for the profile
+type outputExpectation struct {
+	Width       int
+	Height      int
+	VideoCodec  string  // Empty when the encoder is not in encoderCodecs
+	MinDuration float64 // Seconds
+	MaxDuration float64 // Seconds, 0 when unknown
+}
+
+// VerifyOutput probes the processed video and checks it against the profile: resolution, codec,
+// duration (the selected window plus the bumpers), no audio and the moov atom before the media
+// data so playback starts before the whole file is downloaded. The probed metadata is returned
+// so it can be stored without probing again
+func (vp *VideoProcessor) VerifyOutput(outputFile string, window videos.TrimWindow) (*videos.Metadata, error) {
+	metadata, err := vp.Probe(outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe output: %w", err)
+	}
+
+	if err := checkOutput(metadata, vp.outputExpectation(window)); err != nil {
+		return nil, err
+	}
+	if err := checkMoovAtFront(outputFile); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Output verified: %dx%d %s, %.1fs, %d bytes", metadata.Width, metadata.Height,
+		metadata.VideoCodec, metadata.Duration, metadata.Size)
+	return metadata, nil
+}
+
+// outputExpectation builds the expectation of the profile for a window of the source. When the
+// bumpers exist the output always has them (the attempt is retried when they cannot be added),
+// so the duration is the window plus both bumpers, less the crossfades into and out of the video
+func (vp *VideoProcessor) outputExpectation(window videos.TrimWindow) outputExpectation {
+	expectation := outputExpectation{
+		Width:       vp.config.TargetWidth,
+		Height:      vp.config.TargetHeight,
+		VideoCodec:  encoderCodecs[vp.config.VideoCodec],
+		MinDuration: window.Duration() - outputDurationTolerance,
+		MaxDuration: window.Duration() + outputDurationTolerance,
+	}
+
+	if vp.bumpersExist() {
+		intro, introErr := vp.probeDuration(vp.config.IntroPath)
+		outro, outroErr := vp.probeDuration(vp.config.OutroPath)
+		if introErr != nil || outroErr != nil {
+			log.Printf("Warning: could not read bumper durations (%v, %v) - not checking the maximum duration", introErr, outroErr)
+			expectation.MaxDuration = 0
+		} else {
+			expectation.MinDuration += intro + outro - 2*vp.config.BumperCrossfade
+			expectation.MaxDuration += intro + outro
+		}
+	}
+	return expectation
+}
+
+// checkOutput compares the probed output with the expectation
+func checkOutput(metadata *videos.Metadata, expected outputExpectation) error {
+	if metadata.Width != expected.Width || metadata.Height != expected.Height || metadata.Rotation != 0 {
+		return rejectOutput("output is %dx%d (rotation %d), expected %dx%d",
+			metadata.Width, metadata.Height, metadata.Rotation, expected.Width, expected.Height)
+	}
+	if expected.VideoCodec != "" && metadata.VideoCodec != expected.VideoCodec {
+		return rejectOutput("output codec is %s, expected %s", metadata.VideoCodec, expected.VideoCodec)
+	}
+	if metadata.Duration < expected.MinDuration || (expected.MaxDuration > 0 && metadata.Duration > expected.MaxDuration) {
+		return rejectOutput("output duration %.1fs is outside %.1f-%.1fs",
+			metadata.Duration, expected.MinDuration, expected.MaxDuration)
+	}
+	if metadata.HasAudio {
+		return rejectOutput("output has an audio stream (%s)", metadata.AudioCodec)
+	}
+	return nil
+}
+
+// checkMoovAtFront walks the top-level MP4 boxes and fails unless moov comes before mdat
+// (ffmpeg -movflags +faststart)
+func checkMoovAtFront(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open output: %w", err)
+	}
+	defer file.Close()
+
+	var offset int64
+	header := make([]byte, 16)
+	for {
+		if _, err := file.ReadAt(header[:8], offset); err != nil {
+			if errors.Is(err, io.EOF) {
+				return rejectOutput("output has no moov atom")
+			}
+			return fmt.Errorf("failed to read output: %w", err)
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		switch boxType := string(header[4:8]); boxType {
+		case "moov":
+			return nil
+		case "mdat":
+			return rejectOutput("output moov atom is after the media data (not faststart)")
+		}
+
+		switch size {
+		case 0: // Box runs to the end of the file
+			return rejectOutput("output has no moov atom")
+		case 1: // 64-bit size after the type
+			if _, err := file.ReadAt(header[8:16], offset+8); err != nil {
+				return fmt.Errorf("failed to read output: %w", err)
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+		}
+		if size < 8 {
+			return rejectOutput("output has an invalid box at offset %d", offset)
+		}
+		offset += size
+	}
+}
@@ -0,0 +1,238 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"worker/internal/videos"
+)
+
+// Window scoring: every second of the source gets a score from its scene changes, its motion and
+// its audio energy (each normalised to the busiest second), and the window with the highest sum wins
+const (
+	sceneWeight  = 0.4
+	motionWeight = 0.3
+	audioWeight  = 0.3
+
+	sceneThreshold   = 0.3 // Scene score above which a frame counts as a cut
+	windowSampleFPS  = 4   // Frames per second analysed for scene changes and motion
+	audioSampleRate  = 8000
+	sceneScoreKey    = "lavfi.scene_score"
+	audioRMSLevelKey = "lavfi.astats.Overall.RMS_level"
+)
+
+// timedValue is one value printed by the ffmpeg metadata filters
+type timedValue struct {
+	Time  float64 // pts_time in seconds
+	Value float64
+}
+
+// SelectWindow returns the part of the source kept in the processed video. Sources that fit are
+// kept whole, a start_offset chosen by the user is honoured (moved back when less than a full
+// window is left) and otherwise the best scored window is picked. Analysis failures keep the
+// start of the video, as before windows were selected
+func (vp *VideoProcessor) SelectWindow(inputFile string, startOffset *float64) videos.TrimWindow {
+	length := float64(vp.config.MaxDuration)
+
+	duration, err := vp.probeDuration(inputFile)
+	if err != nil {
+		log.Printf("Warning: could not read source duration: %v - keeping the first %ds", err, vp.config.MaxDuration)
+		return videos.TrimWindow{Start: 0, End: length, Selection: videos.TrimAuto}
+	}
+	if duration <= length {
+		return videos.TrimWindow{Start: 0, End: duration, Selection: videos.TrimFull}
+	}
+
+	if startOffset != nil {
+		start := math.Min(math.Max(*startOffset, 0), duration-length)
+		log.Printf("Using the requested window %.1fs-%.1fs (start_offset %.1f)", start, start+length, *startOffset)
+		return videos.TrimWindow{Start: start, End: start + length, Selection: videos.TrimUser}
+	}
+
+	scores, err := vp.scoreSeconds(inputFile, duration)
+	if err != nil {
+		log.Printf("Warning: window analysis failed: %v - keeping the first %ds", err, vp.config.MaxDuration)
+		return videos.TrimWindow{Start: 0, End: length, Selection: videos.TrimAuto}
+	}
+
+	start := float64(bestWindowStart(scores, vp.config.MaxDuration))
+	log.Printf("Selected window %.0fs-%.0fs of %.1fs", start, start+length, duration)
+	return videos.TrimWindow{Start: start, End: start + length, Selection: videos.TrimAuto}
+}
+
+// scoreSeconds analyses the whole source in one ffmpeg pass (before the audio is stripped) and
+// returns the score of each full second
+func (vp *VideoProcessor) scoreSeconds(inputFile string, duration float64) ([]float64, error) {
+	id := time.Now().UnixNano()
+	scenesFile := filepath.Join(filepath.Dir(inputFile), fmt.Sprintf("scenes_%d.txt", id))
+	audioFile := filepath.Join(filepath.Dir(inputFile), fmt.Sprintf("audio_%d.txt", id))
+	defer vp.cleanupFile(scenesFile)
+	defer vp.cleanupFile(audioFile)
+
+	args := []string{
+		"-v", "error",
+		"-i", inputFile,
+		"-vf", fmt.Sprintf("fps=%d,scale=160:-2,select='gte(scene,0)',metadata=mode=print:key=%s:file=%s",
+			windowSampleFPS, sceneScoreKey, scenesFile),
+	}
+
+	hasAudio := vp.hasAudio(inputFile)
+	if hasAudio {
+		// One astats frame per second of audio
+		args = append(args, "-af", fmt.Sprintf(
+			"aresample=%d,asetnsamples=n=%d:p=0,astats=metadata=1:reset=1,ametadata=mode=print:key=%s:file=%s",
+			audioSampleRate, audioSampleRate, audioRMSLevelKey, audioFile))
+	}
+	args = append(args, "-f", "null", "-")
+
+	log.Printf("Executing FFmpeg window analysis: %s", strings.Join(args, " "))
+	cmd := exec.Command(vp.config.FFmpegPath, args...)
+	cmd.Stderr = os.Stderr
+	if err := vp.executeWithTimeout(cmd, 5*time.Minute); err != nil {
+		return nil, err
+	}
+
+	scenes, err := readMetadataSeries(scenesFile, sceneScoreKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var audio []timedValue
+	if hasAudio {
+		if audio, err = readMetadataSeries(audioFile, audioRMSLevelKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return secondScores(scenes, audio, int(duration)), nil
+}
+
+// hasAudio reports whether the file has an audio stream
+func (vp *VideoProcessor) hasAudio(file string) bool {
+	codecType, err := vp.probe(file, "-select_streams", "a:0", "-show_entries", "stream=codec_type")
+	return err == nil && codecType == "audio"
+}
+
+// readMetadataSeries reads the values of key written by the ffmpeg metadata filter:
+//
+//	frame:12   pts:3072    pts_time:3
+//	lavfi.scene_score=0.412000
+func readMetadataSeries(path string, key string) ([]timedValue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read analysis output: %w", err)
+	}
+
+	var (
+		series []timedValue
+		at     float64
+	)
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "frame:") {
+			for _, field := range strings.Fields(line) {
+				if value, found := strings.CutPrefix(field, "pts_time:"); found {
+					at, _ = strconv.ParseFloat(value, 64)
+				}
+			}
+			continue
+		}
+		if value, found := strings.CutPrefix(strings.TrimSpace(line), key+"="); found {
+			parsed, err := strconv.ParseFloat(value, 64) // -inf for silence
+			if err != nil {
+				continue
+			}
+			series = append(series, timedValue{Time: at, Value: parsed})
+		}
+	}
+	return series, nil
+}
+
+// secondScores combines the scene scores and the audio RMS levels (dBFS) into one score per second
+func secondScores(scenes []timedValue, audio []timedValue, seconds int) []float64 {
+	cuts := make([]float64, seconds)
+	motion := make([]float64, seconds)
+	frames := make([]float64, seconds)
+	loudness := make([]float64, seconds)
+	samples := make([]float64, seconds)
+
+	for _, scene := range scenes {
+		i := int(scene.Time)
+		if i < 0 || i >= seconds {
+			continue
+		}
+		motion[i] += scene.Value // The scene score is the difference with the previous frame
+		frames[i]++
+		if scene.Value > sceneThreshold {
+			cuts[i]++
+		}
+	}
+	for _, level := range audio {
+		i := int(level.Time)
+		if i < 0 || i >= seconds {
+			continue
+		}
+		loudness[i] += math.Pow(10, level.Value/20) // dBFS to linear amplitude, silence is 0
+		samples[i]++
+	}
+
+	for i := range motion {
+		if frames[i] > 0 {
+			motion[i] /= frames[i]
+		}
+		if samples[i] > 0 {
+			loudness[i] /= samples[i]
+		}
+	}
+	normalize(cuts)
+	normalize(motion)
+	normalize(loudness)
+
+	scores := make([]float64, seconds)
+	for i := range scores {
+		scores[i] = sceneWeight*cuts[i] + motionWeight*motion[i] + audioWeight*loudness[i]
+	}
+	return scores
+}
+
+// normalize scales the values so the largest one is 1
+func normalize(values []float64) {
+	highest := 0.0
+	for _, value := range values {
+		highest = math.Max(highest, value)
+	}
+	if highest == 0 {
+		return
+	}
+	for i := range values {
+		values[i] /= highest
+	}
+}
+
+// bestWindowStart returns the start second of the length-second window with the highest total
+// score. Ties keep the earliest window
+func bestWindowStart(scores []float64, length int) int {
+	if len(scores) <= length {
+		return 0
+	}
+
+	sum := 0.0
+	for _, score := range scores[:length] {
+		sum += score
+	}
+
+	best, bestSum := 0, sum
+	for start := 1; start+length <= len(scores); start++ {
+		sum += scores[start+length-1] - scores[start-1]
+		if sum > bestSum+1e-9 {
+			best, bestSum = start, sum
+		}
+	}
+	return best
+}
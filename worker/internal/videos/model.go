@@ -13,10 +13,68 @@ type Video struct {
 	ProcessedAt *time.Time `json:"processed_at,omitempty" db:"processed_at"`
 	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 	UserID      int        `json:"user_id" db:"user_id"`
+
+	// Processing lifecycle
+	FailureReason        *string    `json:"failure_reason,omitempty" db:"failure_reason"`
+	Attempts             int        `json:"attempts" db:"attempts"`
+	ProcessingStartedAt  *time.Time `json:"processing_started_at,omitempty" db:"processing_started_at"`
+	ProcessingDurationMs *int64     `json:"processing_duration_ms,omitempty" db:"processing_duration_ms"`
+
+	// Upload profile the API validated the video against, selects the processing settings
+	Profile string `json:"profile" db:"profile"`
+
+	// Start of the processed clip chosen by the user, nil lets the worker pick the best window
+	StartOffset *float64 `json:"start_offset,omitempty" db:"start_offset"`
 }
 
 // VideoStatus constants
 const (
-	StatusUploaded  = "uploaded"
-	StatusProcessed = "processed"
+	StatusUploaded   = "uploaded"
+	StatusQueued     = "queued"     // Processing message published, waiting for a worker (or for a retry)
+	StatusProcessing = "processing" // A worker is processing the video
+	StatusProcessed  = "processed"
+	StatusFailed     = "failed" // Processing gave up, see FailureReason
+)
+
+// Video metadata variants (video_metadata.variant)
+const (
+	MetadataSource    = "source"    // Uploaded file, written by the API
+	MetadataProcessed = "processed" // Worker output
+)
+
+// Metadata holds the ffprobe details stored in video_metadata
+type Metadata struct {
+	Container  string  // ffprobe format_name
+	VideoCodec string  // e.g. "h264"
+	AudioCodec string  // Empty without audio
+	HasAudio   bool    // Whether the file has an audio stream
+	Width      int     // Coded width
+	Height     int     // Coded height
+	Duration   float64 // Seconds
+	Bitrate    int64   // Overall bitrate in bits per second, 0 if unknown
+	FPS        float64 // Average frame rate, 0 if unknown
+	Rotation   int     // Clockwise display rotation (0, 90, 180, 270)
+	Size       int64   // File size in bytes
+	Checksum   string  // SHA-256 hex of the file
+
+	Trim *TrimWindow // Processed variant: part of the source kept in the output
+}
+
+// Trim window selections (video_metadata.trim_selection)
+const (
+	TrimFull = "full" // The source fits in the output, nothing was trimmed
+	TrimAuto = "auto" // Best scored window of the source
+	TrimUser = "user" // Window starting at the start_offset chosen by the user
 )
+
+// TrimWindow is the part of the source kept in the processed video
+type TrimWindow struct {
+	Start     float64 // Seconds into the source
+	End       float64
+	Selection string // TrimFull, TrimAuto or TrimUser
+}
+
+// Duration returns the length of the window in seconds
+func (w TrimWindow) Duration() float64 {
+	return w.End - w.Start
+}
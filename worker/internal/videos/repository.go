@@ -1,26 +1,60 @@
 package videos
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"worker/internal/database"
 )
 
+// ErrVideoNotFound is returned when the video does not exist in the database
+var ErrVideoNotFound = errors.New("video not found in database")
+
+// AttemptRunningError is returned by StartProcessing when the video is in processing by an
+// attempt that is not stale yet. The message must come back after RetryAfter: either that
+// attempt finished and the video is skipped, or it died and the video is taken over
+type AttemptRunningError struct {
+	VideoID    int
+	RetryAfter time.Duration
+}
+
+func (e *AttemptRunningError) Error() string {
+	return fmt.Sprintf("video %d is being processed by another attempt, retry after %v", e.VideoID, e.RetryAfter)
+}
+
+// takeoverMargin is added to the visibility timeout before an attempt left in processing is
+// considered abandoned, covering clock skew and the last heartbeat
+const takeoverMargin = 5 * time.Minute
+
 type Repository struct {
 	db *database.DB
+
+	// staleAfter is how long a video may stay in processing before another attempt takes it over
+	staleAfter time.Duration
 }
 
-func NewRepository(db *database.DB) *Repository {
-	return &Repository{db: db}
+// NewRepository creates the video repository. visibilityTimeout is the time a received message
+// stays hidden: a video in processing for longer (plus a margin) belongs to an attempt that died
+func NewRepository(db *database.DB, visibilityTimeout time.Duration) *Repository {
+	return &Repository{db: db, staleAfter: visibilityTimeout + takeoverMargin}
 }
 
 // GetVideoByID retrieves a video by its ID
 func (r *Repository) GetVideoByID(videoID int) (*Video, error) {
 	var video Video
-	query := `SELECT id, title, status, uploaded_at, processed_at, deleted_at, user_id FROM videos WHERE id = $1`
+	query := `
+		SELECT id, title, status, uploaded_at, processed_at, deleted_at, user_id,
+			failure_reason, attempts, processing_started_at, processing_duration_ms, profile, start_offset
+		FROM videos WHERE id = $1`
 
 	row := r.db.QueryRow(query, videoID)
-	err := row.Scan(&video.ID, &video.Title, &video.Status, &video.UploadedAt, &video.ProcessedAt, &video.DeletedAt, &video.UserID)
+	err := row.Scan(&video.ID, &video.Title, &video.Status, &video.UploadedAt, &video.ProcessedAt, &video.DeletedAt, &video.UserID,
+		&video.FailureReason, &video.Attempts, &video.ProcessingStartedAt, &video.ProcessingDurationMs, &video.Profile, &video.StartOffset)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get video by ID %d: %w", videoID, ErrVideoNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get video by ID %d: %w", videoID, err)
 	}
@@ -28,27 +62,82 @@ func (r *Repository) GetVideoByID(videoID int) (*Video, error) {
 	return &video, nil
 }
 
-// UpdateVideoStatus updates the status of a video record
-func (r *Repository) UpdateVideoStatus(videoID int, status string) error {
-	var query string
-	var args []interface{}
-
-	if status == StatusProcessed {
-		// Set processed_at timestamp when status is processed
-		query = `
-			UPDATE videos 
-			SET status = $1, processed_at = CURRENT_TIMESTAMP
-			WHERE id = $2`
-		args = []interface{}{status, videoID}
-	} else {
-		// Update status without setting processed_at
-		query = `
-			UPDATE videos 
-			SET status = $1
-			WHERE id = $2`
-		args = []interface{}{status, videoID}
+// StartProcessing moves a video to processing and counts the attempt. A video already in
+// processing is only taken over when its attempt started longer ago than the visibility timeout
+// plus a margin, so a duplicate delivery does not restart an attempt that is still running;
+// an *AttemptRunningError is returned instead so the message is delivered again once the
+// attempt can be taken over. Returns false when the video is in any other status (already
+// processed, failed or still waiting for its upload)
+func (r *Repository) StartProcessing(videoID int, startedAt time.Time) (bool, error) {
+	query := `
+		UPDATE videos
+		SET status = $1, attempts = attempts + 1, processing_started_at = $5, processing_duration_ms = NULL
+		WHERE id = $2 AND (status IN ($3, $4) OR
+			(status = $1 AND (processing_started_at IS NULL OR processing_started_at < $6)))`
+
+	result, err := r.db.Exec(query, StatusProcessing, videoID, StatusUploaded, StatusQueued, startedAt, startedAt.Add(-r.staleAfter))
+	if err != nil {
+		return false, fmt.Errorf("failed to start video processing: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected > 0 {
+		return true, nil
+	}
+
+	// Dropping the message of a running attempt is only safe while that attempt is alive: if it
+	// died, this message is the only one left to finish the video
+	var processingStartedAt time.Time
+	query = `SELECT processing_started_at FROM videos WHERE id = $1 AND status = $2 AND processing_started_at IS NOT NULL`
+	err = r.db.QueryRow(query, videoID, StatusProcessing).Scan(&processingStartedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
 	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check video processing: %w", err)
+	}
+
+	retryAfter := max(processingStartedAt.Add(r.staleAfter).Sub(startedAt), 0)
+	return false, &AttemptRunningError{VideoID: videoID, RetryAfter: retryAfter}
+}
+
+// MarkProcessed records a successful attempt and how long it took
+func (r *Repository) MarkProcessed(videoID int, processedAt time.Time, duration time.Duration) error {
+	query := `
+		UPDATE videos
+		SET status = $1, processed_at = $2, failure_reason = NULL, processing_duration_ms = $3
+		WHERE id = $4`
 
+	return r.finishProcessing(query, videoID, StatusProcessed, processedAt, duration.Milliseconds(), videoID)
+}
+
+// MarkFailed records an attempt the worker gave up on. failed is final: the video is not
+// processed again
+func (r *Repository) MarkFailed(videoID int, reason string, duration time.Duration) error {
+	return r.finishWithFailure(videoID, StatusFailed, reason, duration)
+}
+
+// MarkRetryPending records a failed attempt that will be retried, moving the video back to
+// queued with the reason so users can see why it is taking longer
+func (r *Repository) MarkRetryPending(videoID int, reason string, duration time.Duration) error {
+	return r.finishWithFailure(videoID, StatusQueued, reason, duration)
+}
+
+// finishWithFailure ends the current attempt with the given status. Only videos in processing
+// are updated, so a failure before the attempt started does not change the status
+func (r *Repository) finishWithFailure(videoID int, status string, reason string, duration time.Duration) error {
+	query := `
+		UPDATE videos
+		SET status = $1, failure_reason = $2, processing_duration_ms = $3
+		WHERE id = $4 AND status = $5`
+
+	return r.finishProcessing(query, videoID, status, reason, duration.Milliseconds(), videoID, StatusProcessing)
+}
+
+func (r *Repository) finishProcessing(query string, videoID int, args ...any) error {
 	result, err := r.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update video status: %w", err)
@@ -60,8 +149,56 @@ func (r *Repository) UpdateVideoStatus(videoID int, status string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("video with ID %d not found", videoID)
+		return fmt.Errorf("video with ID %d not found or not processing", videoID)
+	}
+
+	return nil
+}
+
+// MarkImagesGenerated records that the poster, thumbnails and scrubbing preview are in storage
+func (r *Repository) MarkImagesGenerated(videoID int) error {
+	_, err := r.db.Exec(`UPDATE videos SET has_images = TRUE WHERE id = $1`, videoID)
+	if err != nil {
+		return fmt.Errorf("failed to mark video images: %w", err)
 	}
+	return nil
+}
 
+// SaveMetadata stores the probed metadata of a video variant, replacing a previous probe
+func (r *Repository) SaveMetadata(videoID int, variant string, metadata *Metadata) error {
+	query := `
+		INSERT INTO video_metadata (video_id, variant, container, video_codec, audio_codec, has_audio,
+			width, height, duration_seconds, bitrate, fps, rotation, size_bytes, checksum_sha256, probed_at,
+			trim_start_seconds, trim_end_seconds, trim_selection)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NOW(), $15, $16, $17)
+		ON CONFLICT (video_id, variant) DO UPDATE SET
+			container = EXCLUDED.container, video_codec = EXCLUDED.video_codec,
+			audio_codec = EXCLUDED.audio_codec, has_audio = EXCLUDED.has_audio,
+			width = EXCLUDED.width, height = EXCLUDED.height,
+			duration_seconds = EXCLUDED.duration_seconds, bitrate = EXCLUDED.bitrate,
+			fps = EXCLUDED.fps, rotation = EXCLUDED.rotation, size_bytes = EXCLUDED.size_bytes,
+			checksum_sha256 = EXCLUDED.checksum_sha256, probed_at = EXCLUDED.probed_at,
+			trim_start_seconds = EXCLUDED.trim_start_seconds, trim_end_seconds = EXCLUDED.trim_end_seconds,
+			trim_selection = EXCLUDED.trim_selection`
+
+	var trimStart, trimEnd sql.NullFloat64
+	var trimSelection sql.NullString
+	if metadata.Trim != nil {
+		trimStart = sql.NullFloat64{Float64: metadata.Trim.Start, Valid: true}
+		trimEnd = sql.NullFloat64{Float64: metadata.Trim.End, Valid: true}
+		trimSelection = sql.NullString{String: metadata.Trim.Selection, Valid: true}
+	}
+
+	_, err := r.db.Exec(query, videoID, variant, metadata.Container, metadata.VideoCodec,
+		sql.NullString{String: metadata.AudioCodec, Valid: metadata.AudioCodec != ""}, metadata.HasAudio,
+		metadata.Width, metadata.Height, metadata.Duration,
+		sql.NullInt64{Int64: metadata.Bitrate, Valid: metadata.Bitrate > 0},
+		sql.NullFloat64{Float64: metadata.FPS, Valid: metadata.FPS > 0},
+		metadata.Rotation, metadata.Size,
+		sql.NullString{String: metadata.Checksum, Valid: metadata.Checksum != ""},
+		trimStart, trimEnd, trimSelection)
+	if err != nil {
+		return fmt.Errorf("failed to save video metadata: %w", err)
+	}
 	return nil
 }